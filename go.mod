@@ -2,7 +2,10 @@ module github.com/Sameersah/GrafanaConnect
 
 go 1.21
 
-require github.com/grafana/grafana-plugin-sdk-go v0.194.0
+require (
+	github.com/grafana/grafana-plugin-sdk-go v0.194.0
+	golang.org/x/oauth2 v0.14.0
+)
 
 require (
 	github.com/BurntSushi/toml v1.3.2 // indirect
@@ -80,6 +83,7 @@ require (
 	golang.org/x/text v0.14.0 // indirect
 	golang.org/x/tools v0.6.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
+	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20231002182017-d307bd883b97 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20231002182017-d307bd883b97 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
@@ -21,6 +21,7 @@ func main() {
 		QueryDataHandler:    handler,
 		CheckHealthHandler:  handler,
 		CallResourceHandler: handler,
+		StreamHandler:       handler,
 	}); err != nil {
 		log.DefaultLogger.Error("Error starting plugin", "error", err)
 		os.Exit(1)
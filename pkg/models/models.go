@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // QueryType represents the type of data source query
 type QueryType string
 
@@ -9,20 +14,315 @@ const (
 	QueryTypeREST       QueryType = "rest"
 )
 
+// ResponseShape selects how a REST response body is converted to frames
+type ResponseShape string
+
+const (
+	// ResponseShapeAuto infers the conversion from the JSON structure
+	ResponseShapeAuto ResponseShape = ""
+	// ResponseShapeObjectSeries treats each key as a series of [timestamp, value] pairs
+	ResponseShapeObjectSeries ResponseShape = "objectSeries"
+)
+
+// SampleMode selects which rows are kept when a REST array exceeds MaxRows
+type SampleMode string
+
+const (
+	SampleModeHead SampleMode = "head"
+	SampleModeTail SampleMode = "tail"
+	SampleModeEven SampleMode = "even"
+)
+
+// TimestampPolicy selects how duplicate or out-of-order Prometheus samples
+// are corrected; all policies sort by time
+type TimestampPolicy string
+
+const (
+	// TimestampPolicyDedupLast keeps the last sample of a duplicate timestamp (default)
+	TimestampPolicyDedupLast TimestampPolicy = "dedupLast"
+	// TimestampPolicyDropDuplicates keeps the first sample of a duplicate timestamp
+	TimestampPolicyDropDuplicates TimestampPolicy = "dropDuplicates"
+	// TimestampPolicySort only sorts, keeping duplicates
+	TimestampPolicySort TimestampPolicy = "sort"
+)
+
+// HealthMode selects how per-source health probes are combined
+type HealthMode string
+
+const (
+	// HealthModeAll requires every probed source to be healthy (default)
+	HealthModeAll HealthMode = "all"
+	// HealthModeAny requires at least one probed source to be healthy
+	HealthModeAny HealthMode = "any"
+)
+
+// MaxRangeMode selects what happens to queries over a source's maximum range
+type MaxRangeMode string
+
+const (
+	// MaxRangeModeReject fails over-limit queries (default)
+	MaxRangeModeReject MaxRangeMode = "reject"
+	// MaxRangeModeClamp shortens the range to the limit, ending at To
+	MaxRangeModeClamp MaxRangeMode = "clamp"
+)
+
+// Format selects how Prometheus series are arranged into frames
+type Format string
+
+const (
+	// FormatTimeSeries returns one frame per series (default)
+	FormatTimeSeries Format = "time_series"
+	// FormatHeatmap arranges le-bucketed histogram series into heatmap frames
+	FormatHeatmap Format = "heatmap"
+)
+
+// AuthMode selects how backend requests are authenticated
+type AuthMode string
+
+const (
+	// AuthModeAuto uses the first configured of bearer token, API key and
+	// basic auth (default)
+	AuthModeAuto   AuthMode = ""
+	AuthModeNone   AuthMode = "none"
+	AuthModeAPIKey AuthMode = "apiKey"
+	AuthModeBasic  AuthMode = "basic"
+	AuthModeBearer AuthMode = "bearer"
+	AuthModeOAuth2 AuthMode = "oauth2"
+)
+
+// QueryKind selects instant or range evaluation of Prometheus and Loki
+// queries
+type QueryKind string
+
+const (
+	QueryKindRange   QueryKind = "range"
+	QueryKindInstant QueryKind = "instant"
+)
+
+// Direction is the order Loki returns log lines in
+type Direction string
+
+const (
+	// DirectionBackward returns the newest lines first (default)
+	DirectionBackward Direction = "backward"
+	DirectionForward  Direction = "forward"
+)
+
+// ResponseFormat selects how a REST response body is parsed
+type ResponseFormat string
+
+const (
+	// ResponseFormatAuto infers the format from the Content-Type (default)
+	ResponseFormatAuto   ResponseFormat = ""
+	ResponseFormatJSON   ResponseFormat = "json"
+	ResponseFormatNDJSON ResponseFormat = "ndjson"
+	ResponseFormatCSV    ResponseFormat = "csv"
+	ResponseFormatXML    ResponseFormat = "xml"
+	// ResponseFormatRaw returns the body as a single string value
+	ResponseFormatRaw ResponseFormat = "raw"
+)
+
+// ValueType selects the type REST value fields are coerced to
+type ValueType string
+
+const (
+	ValueTypeAuto   ValueType = ""
+	ValueTypeString ValueType = "string"
+	ValueTypeNumber ValueType = "number"
+	ValueTypeBool   ValueType = "bool"
+)
+
 // DataSourceConfig holds the configuration for the data source
 type DataSourceConfig struct {
 	PrometheusURL string `json:"prometheusUrl"`
 	LokiURL       string `json:"lokiUrl"`
 	RESTURL       string `json:"restUrl"`
 	
+	// AuthMode selects the authentication method; the matching credentials
+	// are checked by the health check
+	AuthMode AuthMode `json:"authMode"`
+
 	// Authentication
 	APIKey        string `json:"apiKey"`
 	BasicAuthUser string `json:"basicAuthUser"`
 	BasicAuthPass string `json:"basicAuthPass"`
 	BearerToken   string `json:"bearerToken"`
 	
+	// OAuth2 client-credentials authentication, enabled by OAuth2TokenURL;
+	// the token replaces any other Authorization header
+	OAuth2TokenURL     string   `json:"oauth2TokenUrl"`
+	OAuth2ClientID     string   `json:"oauth2ClientId"`
+	OAuth2ClientSecret string   `json:"oauth2ClientSecret"`
+	OAuth2Scopes       []string `json:"oauth2Scopes"`
+
+	// Headers sent with every backend request; the per-source maps are
+	// applied first, then the global Headers, then authentication
+	Headers           map[string]string `json:"headers"`
+	PrometheusHeaders map[string]string `json:"prometheusHeaders"`
+	LokiHeaders       map[string]string `json:"lokiHeaders"`
+
 	// REST API specific
 	RESTHeaders map[string]string `json:"restHeaders"`
+
+	// HMAC request signing for REST APIs, enabled by HMACSecret. The
+	// template may use {method}, {path}, {query}, {timestamp} and {body};
+	// the timestamp is sent in HMACTimestampHeader.
+	HMACSecret          string `json:"hmacSecret"`
+	HMACHeader          string `json:"hmacHeader"`
+	HMACTimestampHeader string `json:"hmacTimestampHeader"`
+	HMACTemplate        string `json:"hmacTemplate"`
+
+	// REST health check; the endpoint's expected status defaults to any 2xx.
+	// Without an endpoint the base URL is checked with a HEAD request, which
+	// passes on any status below 500 except 401 and 403.
+	RESTHealthEndpoint       string `json:"restHealthEndpoint"`
+	RESTHealthExpectedStatus int    `json:"restHealthExpectedStatus"`
+	RESTHealthExpectedBody   string `json:"restHealthExpectedBody"`
+
+	// PrometheusHealthQuery is a sentinel PromQL query (e.g. vector(1)) run
+	// by the health check instead of probing /-/healthy
+	PrometheusHealthQuery string `json:"prometheusHealthQuery"`
+
+	// ScrapeInterval is the target scrape interval used to size
+	// $__rate_interval, as a Go duration (default 15s)
+	ScrapeInterval string `json:"scrapeInterval"`
+
+	// Loki specific
+	LokiMinStepSeconds int    `json:"lokiMinStepSeconds"`
+	LokiAPIPrefix      string `json:"lokiApiPrefix"`
+
+	// LokiMaxLines is the default log line limit of Loki queries (1000)
+	LokiMaxLines int `json:"lokiMaxLines"`
+
+	// LokiDerivedFields extract values such as trace IDs from log lines
+	LokiDerivedFields []DerivedField `json:"lokiDerivedFields"`
+
+	// Maximum query range per source as Go durations (empty means no
+	// limit), and whether longer ranges are rejected or clamped
+	PrometheusMaxRange string       `json:"prometheusMaxRange"`
+	LokiMaxRange       string       `json:"lokiMaxRange"`
+	MaxRangeMode       MaxRangeMode `json:"maxRangeMode"`
+
+	// SeriesNameLabel is the label used to name Loki and REST series,
+	// falling back to the built-in heuristics when absent
+	SeriesNameLabel string `json:"seriesNameLabel"`
+
+	// StrictQueryTypes makes unknown query types an error instead of an
+	// empty frame with a notice (default true)
+	StrictQueryTypes bool `json:"strictQueryTypes"`
+
+	// HealthMode decides whether CheckHealth needs all or any of the
+	// configured sources to be reachable (default all)
+	HealthMode HealthMode `json:"healthMode"`
+
+	// TLS policy for backend connections. Versions are "1.2" or "1.3"
+	// (minimum defaults to 1.2); renegotiation is "never" (default),
+	// "once" or "freely".
+	TLSMinVersion    string `json:"tlsMinVersion"`
+	TLSMaxVersion    string `json:"tlsMaxVersion"`
+	TLSRenegotiation string `json:"tlsRenegotiation"`
+
+	// TLSSkipVerify disables certificate verification for self-signed backends
+	TLSSkipVerify bool `json:"tlsSkipVerify"`
+
+	// TLSCACert is a PEM bundle of CAs trusted for backend connections,
+	// loaded from secure settings
+	TLSCACert string `json:"-"`
+
+	// ClockSkewCheck compares each backend's Date header with the local
+	// clock during the health check, warning above the threshold (default 30s)
+	ClockSkewCheck            bool `json:"clockSkewCheck"`
+	ClockSkewThresholdSeconds int  `json:"clockSkewThresholdSeconds"`
+
+	// ProxyURL routes all backend requests through a forward proxy; when
+	// empty the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply
+	ProxyURL string `json:"proxyUrl"`
+
+	// DNSCacheTTL caches backend host resolutions for this Go duration;
+	// empty disables the cache
+	DNSCacheTTL string `json:"dnsCacheTtl"`
+
+	// Compress gzips REST request bodies and negotiates gzip responses for
+	// queries that don't set their own Compress
+	Compress bool `json:"compress"`
+
+	// Backend request and health probe timeouts in seconds; zero or
+	// negative values use the defaults of 30 and 5 seconds
+	TimeoutSeconds       int `json:"timeoutSeconds"`
+	HealthTimeoutSeconds int `json:"healthTimeoutSeconds"`
+
+	// StaleCacheSize keeps the last good result of this many queries and
+	// serves it, with a notice, when the backend fails (0 disables).
+	// Stale results older than StaleCacheMaxAgeSeconds (default 600) are
+	// not served.
+	StaleCacheSize          int `json:"staleCacheSize"`
+	StaleCacheMaxAgeSeconds int `json:"staleCacheMaxAgeSeconds"`
+
+	// CacheTTLSeconds reuses complete query results for this long instead
+	// of querying the backend again (0 disables). At most CacheMaxEntries
+	// results (default 1000) are kept, evicting the least recently used.
+	// Results carrying warnings, such as a partial fan-out, are not cached.
+	CacheTTLSeconds int `json:"cacheTTLSeconds"`
+	CacheMaxEntries int `json:"cacheMaxEntries"`
+
+	// AllowURLOverride lets queries select another backend with
+	// BaseURLOverride and other credentials with AuthProfile; both are
+	// rejected while it is off. Configurations saved before the flag
+	// existed that list AllowedBaseURLs keep their overrides working: the
+	// flag is turned on when it is absent from the settings.
+	AllowURLOverride bool `json:"allowUrlOverride"`
+
+	// AllowedBaseURLs lists the base URLs a query's BaseURLOverride may
+	// select; overrides are rejected when it is empty
+	AllowedBaseURLs []string `json:"allowedBaseUrls"`
+
+	// AuthProfiles are named credentials a query may select with AuthProfile
+	AuthProfiles []AuthProfile `json:"authProfiles"`
+
+	// Maximum number of queries of one request executed in parallel
+	QueryConcurrency int `json:"queryConcurrency"`
+
+	// Maximum number of concurrent backend requests (default 8), shared by
+	// queries, fan-out targets, resources, proxied calls and health checks
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
+
+	// Maximum number of frames returned for one query (default 10000)
+	MaxFramesPerQuery int `json:"maxFramesPerQuery"`
+
+	// Maximum size of a backend response body in bytes
+	MaxResponseBytes int64 `json:"maxResponseBytes"`
+
+	// Retry of idempotent query requests; disabled when MaxRetries is zero.
+	// RetryableStatusCodes defaults to 502, 503 and 504; codes outside
+	// 5xx are ignored.
+	MaxRetries               int   `json:"maxRetries"`
+	RetryBackoffMs           int   `json:"retryBackoffMs"`
+	RetryableStatusCodes     []int `json:"retryableStatusCodes"`
+	DisableNetworkErrorRetry bool  `json:"disableNetworkErrorRetry"`
+}
+
+// AuthProfile is a named set of credentials replacing the datasource's own,
+// e.g. for a second cluster selected with BaseURLOverride. Its secrets are
+// read from the secure settings authProfile.<name>.apiKey, .basicAuthPass
+// and .bearerToken. The credentials are only sent to the base URLs in
+// AllowedURLs, whether the query overrides the URL or uses the default.
+type AuthProfile struct {
+	Name          string   `json:"name"`
+	AuthMode      AuthMode `json:"authMode"`
+	BasicAuthUser string   `json:"basicAuthUser,omitempty"`
+	AllowedURLs   []string `json:"allowedUrls"`
+	APIKey        string   `json:"-"`
+	BasicAuthPass string   `json:"-"`
+	BearerToken   string   `json:"-"`
+}
+
+// DerivedField extracts a value from Loki log lines into its own field.
+// MatcherRegex's first capture group (or whole match) is the value, and URL,
+// when set, links it, e.g. https://tempo/trace/${__value.raw}.
+type DerivedField struct {
+	Name         string `json:"name"`
+	MatcherRegex string `json:"matcherRegex"`
+	URL          string `json:"url"`
 }
 
 // QueryModel represents a query from Grafana
@@ -31,18 +331,236 @@ type QueryModel struct {
 	
 	// Prometheus query fields
 	PromQL string `json:"promQL,omitempty"`
+
+	// SeriesMatchers lists series selectors passed as match[] to the series
+	// endpoint; when set, the query returns matching label sets instead of
+	// evaluating PromQL
+	SeriesMatchers []string `json:"seriesMatchers,omitempty"`
+
+	// QueryKind selects instant or range evaluation for Prometheus and Loki;
+	// queries saved without it use QueryMode, or a range query without
+	// either. The time range never decides the kind.
+	QueryKind QueryKind `json:"queryKind,omitempty"`
+
+	// QueryMode is the former name of QueryKind, honored when QueryKind is
+	// absent
+	QueryMode QueryKind `json:"queryMode,omitempty"`
+
+	// LegendFormat names series from their labels, e.g. "{{job}} - {{instance}}"
+	LegendFormat string `json:"legendFormat,omitempty"`
+
+	// Format arranges the series, e.g. histogram buckets as a heatmap
+	Format Format `json:"format,omitempty"`
+
+	// GroupByLabels groups series into one frame per distinct combination
+	// of these label values
+	GroupByLabels []string `json:"groupByLabels,omitempty"`
+
+	// Step fixes the range query resolution (a Go duration such as "30s");
+	// by default it follows the panel interval and max data points
+	Step string `json:"step,omitempty"`
+
+	// Steps runs a range query once per step (Go durations such as "1m"),
+	// returning one set of frames per resolution labeled with its step
+	Steps []string `json:"steps,omitempty"`
+
+	// PromoteLabels adds these metric labels as string fields aligned with
+	// the value rows, in addition to keeping them as labels
+	PromoteLabels []string `json:"promoteLabels,omitempty"`
+
+	// TimestampPolicy corrects duplicate or out-of-order samples in a series
+	TimestampPolicy TimestampPolicy `json:"timestampPolicy,omitempty"`
+
+	// Min, Max and Thresholds configure the value fields' scale and colors
+	Min        *float64        `json:"min,omitempty"`
+	Max        *float64        `json:"max,omitempty"`
+	Thresholds []ThresholdStep `json:"thresholds,omitempty"`
+
+	// Unit is the value fields' display unit. UnitFromLabel takes it from a
+	// series label instead, falling back to Unit when the label is absent.
+	Unit          string `json:"unit,omitempty"`
+	UnitFromLabel string `json:"unitFromLabel,omitempty"`
+
+	// IncludeRaw also returns the counter series behind a rate() expression
+	IncludeRaw bool `json:"includeRaw,omitempty"`
 	
 	// Loki query fields
 	LogQL string `json:"logQL,omitempty"`
+
+	// WithCount adds a single-value frame with the number of returned log lines
+	WithCount bool `json:"withCount,omitempty"`
+
+	// Limit is the maximum number of log lines returned, overriding
+	// LokiMaxLines; it is capped at 5000
+	Limit int `json:"limit,omitempty"`
+
+	// Direction orders log lines newest first (backward, the default) or
+	// oldest first (forward)
+	Direction Direction `json:"direction,omitempty"`
+
+	// DetectLevel adds a "level" field for log row coloring, taken from a
+	// level label or parsed from logfmt or JSON lines
+	DetectLevel bool `json:"detectLevel,omitempty"`
+
+	// EmitLabelsField adds a "labels_json" string field holding each row's
+	// stream labels as JSON, for table views and transformations
+	EmitLabelsField bool `json:"emitLabelsField,omitempty"`
+
+	// MetricsLinkTemplate is a PromQL query linked from each log stream, with
+	// ${label} placeholders filled from the stream labels
+	// (e.g. rate(http_requests_total{job="${job}"}[5m]))
+	MetricsLinkTemplate string `json:"metricsLinkTemplate,omitempty"`
 	
 	// REST API query fields
 	RESTEndpoint string            `json:"restEndpoint,omitempty"`
 	RESTMethod   string            `json:"restMethod,omitempty"`
 	RESTHeaders  map[string]string `json:"restHeaders,omitempty"`
 	RESTBody     string            `json:"restBody,omitempty"`
+
+	// RESTParams are encoded as query parameters. RESTRawQuery is appended
+	// verbatim after them, so repeated keys (a=1&a=2) keep their order and a
+	// key present in both is sent twice with the structured value first.
+	RESTParams   map[string]string `json:"restParams,omitempty"`
+	RESTRawQuery string            `json:"restRawQuery,omitempty"`
+
+	// RESTGraphQL POSTs GraphQLQuery and GraphQLVariables as a GraphQL
+	// request and converts the response's data member
+	RESTGraphQL      bool                   `json:"restGraphQL,omitempty"`
+	GraphQLQuery     string                 `json:"graphQLQuery,omitempty"`
+	GraphQLVariables map[string]interface{} `json:"graphQLVariables,omitempty"`
+
+	// TimeField and then TimeFieldCandidates name the time column, tried in
+	// order; when set and none is present, rows get synthetic timestamps
+	TimeField           string   `json:"timeField,omitempty"`
+	TimeFieldCandidates []string `json:"timeFieldCandidates,omitempty"`
+
+	// AutoDetectEpochTime treats a numeric epoch-like column as the time field
+	// when no conventionally named time column exists
+	AutoDetectEpochTime bool `json:"autoDetectEpochTime,omitempty"`
+
+	// TimeFormats are Go time layouts tried in order when parsing string
+	// timestamps, before falling back to epoch and ISO 8601 detection
+	TimeFormats []string `json:"timeFormats,omitempty"`
+
+	// MaxWaitMs bounds the body read for streaming endpoints that keep the
+	// connection open; the data received by then is used (0 waits for the
+	// complete response)
+	MaxWaitMs int `json:"maxWaitMs,omitempty"`
+
+	// ResponseFormat overrides the parser chosen from the Content-Type
+	ResponseFormat ResponseFormat `json:"responseFormat,omitempty"`
+
+	// CSVDelimiter is the CSV field separator (default ",")
+	CSVDelimiter string `json:"csvDelimiter,omitempty"`
+
+	// CSVNoHeader marks CSV bodies without a header row; columns are then
+	// named column1, column2, ...
+	CSVNoHeader bool `json:"csvNoHeader,omitempty"`
+
+	// ResponseShape overrides how the response body is converted to frames
+	ResponseShape ResponseShape `json:"responseShape,omitempty"`
+
+	// RESTDataPath selects the array or object to tabulate, as a dotted path
+	// or simple JSONPath (e.g. "result.items" or "$.result.items[0]"). XML
+	// paths start at the root element (e.g. "rows.row").
+	RESTDataPath string `json:"restDataPath,omitempty"`
+
+	// MetaPath points at a block describing field units, display names and types
+	MetaPath string `json:"metaPath,omitempty"`
+
+	// ForceValueType coerces value fields to string, number or bool; empty
+	// or "auto" keeps the inferred types
+	ForceValueType ValueType `json:"forceValueType,omitempty"`
+
+	// MaxRows truncates REST arrays before conversion (0 means no limit),
+	// keeping rows according to SampleMode (default head)
+	MaxRows    int        `json:"maxRows,omitempty"`
+	SampleMode SampleMode `json:"sampleMode,omitempty"`
+
+	// FieldExpressions derives new fields from arithmetic on numeric columns,
+	// keyed by the new field name (e.g. "kb": "bytes / 1024")
+	FieldExpressions map[string]string `json:"fieldExpressions,omitempty"`
+
+	// FanOut lists substitutions for the {{target}} placeholder in RESTEndpoint
+	FanOut []string `json:"fanOut,omitempty"`
+
+	// FanOutMerge returns all fan-out targets as one long-format frame with
+	// a target column instead of one frame per target
+	FanOutMerge bool `json:"fanOutMerge,omitempty"`
 	
+	// RoundDecimals rounds numeric value fields to this many decimals;
+	// unset or negative keeps full precision
+	RoundDecimals *int `json:"roundDecimals,omitempty"`
+
+	// Compress overrides the datasource's compression setting for this query
+	Compress *bool `json:"compress,omitempty"`
+
 	// Common fields
 	RefID string `json:"refId"`
+
+	// BaseURLOverride replaces the configured backend URL for this query,
+	// e.g. to select a cluster with a dashboard variable. It must be listed
+	// in AllowedBaseURLs; authentication still comes from the configuration
+	// unless AuthProfile selects other credentials.
+	BaseURLOverride string `json:"baseUrlOverride,omitempty"`
+
+	// AuthProfile names the configured AuthProfiles entry authenticating
+	// this query instead of the datasource credentials
+	AuthProfile string `json:"authProfile,omitempty"`
+
+	// NoCache bypasses the response cache for this query
+	NoCache bool `json:"nocache,omitempty"`
+
+	// Variables holds dashboard variable values substituted into ${name}
+	// placeholders in PromQL and LogQL, escaped unless ${name:raw} is used
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// MultiVariables holds multi-value variables, expanded into JSON arrays
+	// when substituted into a REST body
+	MultiVariables map[string][]string `json:"multiVariables,omitempty"`
+}
+
+// Kind returns the query kind, falling back to the legacy field and then to
+// a range query
+func (q *QueryModel) Kind() QueryKind {
+	switch {
+	case q.QueryKind != "":
+		return q.QueryKind
+	case q.QueryMode != "":
+		return q.QueryMode
+	}
+	return QueryKindRange
+}
+
+// Validate checks the enumerated fields of a query
+func (q *QueryModel) Validate() error {
+	for _, kind := range []QueryKind{q.QueryKind, q.QueryMode} {
+		switch kind {
+		case "", QueryKindRange, QueryKindInstant:
+		default:
+			return fmt.Errorf("invalid query kind %q, expected %q or %q", kind, QueryKindInstant, QueryKindRange)
+		}
+	}
+
+	switch q.Direction {
+	case "", DirectionBackward, DirectionForward:
+	default:
+		return fmt.Errorf("invalid direction %q, expected %q or %q", q.Direction, DirectionBackward, DirectionForward)
+	}
+
+	switch q.SampleMode {
+	case "", SampleModeHead, SampleModeTail, SampleModeEven:
+	default:
+		return fmt.Errorf("invalid sample mode %q, expected %q, %q or %q", q.SampleMode, SampleModeHead, SampleModeTail, SampleModeEven)
+	}
+
+	return nil
+}
+
+// ThresholdStep is a single threshold step; a nil Value marks the base step
+type ThresholdStep struct {
+	Value *float64 `json:"value"`
+	Color string   `json:"color"`
 }
 
 // PrometheusQueryRequest represents a Prometheus query request
@@ -55,15 +573,71 @@ type PrometheusQueryRequest struct {
 
 // PrometheusQueryResponse represents a Prometheus query response
 type PrometheusQueryResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ResultType string `json:"resultType"`
-		Result     []struct {
-			Metric map[string]string `json:"metric"`
-			Values [][]interface{}    `json:"values,omitempty"`
-			Value  []interface{}      `json:"value,omitempty"`
-		} `json:"result"`
-	} `json:"data"`
+	Status string         `json:"status"`
+	Data   PrometheusData `json:"data"`
+
+	// Warnings and infos are returned by Prometheus-compatible backends
+	// (Thanos, Mimir) when results may be incomplete
+	Warnings []string `json:"warnings,omitempty"`
+	Infos    []string `json:"infos,omitempty"`
+
+	// IsPartial is set by some backends when one or more stores did not respond
+	IsPartial bool `json:"isPartial,omitempty"`
+}
+
+// PrometheusData holds the result payload of a Prometheus query response
+type PrometheusData struct {
+	ResultType string             `json:"resultType"`
+	Result     []PrometheusResult `json:"result"`
+}
+
+// PrometheusResult represents a single series in a Prometheus query response
+type PrometheusResult struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values,omitempty"`
+	Value  []interface{}     `json:"value,omitempty"`
+}
+
+// UnmarshalJSON handles scalar and string result types, whose result is a
+// single [timestamp, value] pair instead of a list of series
+func (d *PrometheusData) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	d.ResultType = raw.ResultType
+	d.Result = nil
+	if len(raw.Result) == 0 || string(raw.Result) == "null" {
+		return nil
+	}
+
+	switch raw.ResultType {
+	case "scalar", "string":
+		var pair []interface{}
+		if err := json.Unmarshal(raw.Result, &pair); err != nil {
+			return err
+		}
+		d.Result = []PrometheusResult{{Metric: map[string]string{}, Value: pair}}
+		return nil
+	default:
+		return json.Unmarshal(raw.Result, &d.Result)
+	}
+}
+
+// IsIncomplete reports whether the backend flagged the response as partial
+func (r *PrometheusQueryResponse) IsIncomplete() bool {
+	return r.IsPartial || len(r.Warnings) > 0
+}
+
+// PrometheusSeriesResponse represents a Prometheus /api/v1/series response
+type PrometheusSeriesResponse struct {
+	Status string              `json:"status"`
+	Data   []map[string]string `json:"data"`
+	Error  string              `json:"error,omitempty"`
 }
 
 // LokiQueryRequest represents a Loki query request
@@ -74,14 +648,19 @@ type LokiQueryRequest struct {
 	EndTime   int64  `json:"end_time,omitempty"`
 }
 
-// LokiQueryResponse represents a Loki query response
+// LokiQueryResponse represents a Loki query response. Log queries return
+// streams whose values are [nanosecond timestamp, line] string pairs; metric
+// queries return a matrix or vector of [seconds, "value"] samples labeled by
+// metric, like Prometheus.
 type LokiQueryResponse struct {
 	Status string `json:"status"`
 	Data   struct {
 		ResultType string `json:"resultType"`
 		Result     []struct {
 			Stream map[string]string `json:"stream"`
-			Values [][]string        `json:"values"`
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+			Value  []interface{}     `json:"value"`
 		} `json:"result"`
 	} `json:"data"`
 }
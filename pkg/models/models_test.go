@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestQueryKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		model QueryModel
+		want  QueryKind
+	}{
+		{name: "explicit instant", model: QueryModel{QueryKind: QueryKindInstant}, want: QueryKindInstant},
+		{name: "explicit range", model: QueryModel{QueryKind: QueryKindRange}, want: QueryKindRange},
+		{name: "legacy query mode", model: QueryModel{QueryMode: QueryKindInstant}, want: QueryKindInstant},
+		{name: "kind wins over the legacy mode", model: QueryModel{QueryKind: QueryKindRange, QueryMode: QueryKindInstant}, want: QueryKindRange},
+		{name: "absent kind", model: QueryModel{}, want: QueryKindRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.model.Kind(); got != tt.want {
+				t.Errorf("Kind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryModelValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   QueryModel
+		wantErr bool
+	}{
+		{name: "empty", model: QueryModel{}},
+		{name: "valid kind and direction", model: QueryModel{QueryKind: QueryKindInstant, Direction: DirectionForward}},
+		{name: "invalid kind", model: QueryModel{QueryKind: "sometimes"}, wantErr: true},
+		{name: "invalid legacy mode", model: QueryModel{QueryMode: "sometimes"}, wantErr: true},
+		{name: "invalid direction", model: QueryModel{Direction: "sideways"}, wantErr: true},
+		{name: "valid sample mode", model: QueryModel{SampleMode: SampleModeEven}},
+		{name: "invalid sample mode", model: QueryModel{SampleMode: "random"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.model.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// legacySecrets maps secure settings keys to the config fields that older
+// versions read as plaintext from jsonData
+var legacySecrets = []struct {
+	key   string
+	field func(*models.DataSourceConfig) *string
+}{
+	{"apiKey", func(c *models.DataSourceConfig) *string { return &c.APIKey }},
+	{"basicAuthPass", func(c *models.DataSourceConfig) *string { return &c.BasicAuthPass }},
+	{"bearerToken", func(c *models.DataSourceConfig) *string { return &c.BearerToken }},
+}
+
+// loadSecrets applies the credentials from secure settings. A plaintext
+// credential left in jsonData by an older version is still used when the
+// secure key is absent, with a deprecation warning.
+func loadSecrets(config *models.DataSourceConfig, secure map[string]string, logger log.Logger) {
+	for _, s := range legacySecrets {
+		field := s.field(config)
+		if val, ok := secure[s.key]; ok {
+			*field = val
+			continue
+		}
+		if *field != "" {
+			logger.Warn("Using deprecated plaintext credential from jsonData, move it to secure settings", "key", s.key)
+		}
+	}
+}
+
+// loadAuthProfileSecrets applies the secure settings of each auth profile
+func loadAuthProfileSecrets(config *models.DataSourceConfig, secure map[string]string) {
+	for i := range config.AuthProfiles {
+		profile := &config.AuthProfiles[i]
+		prefix := "authProfile." + profile.Name + "."
+		profile.APIKey = secure[prefix+"apiKey"]
+		profile.BasicAuthPass = secure[prefix+"basicAuthPass"]
+		profile.BearerToken = secure[prefix+"bearerToken"]
+	}
+}
+
+// validateAuthProfiles checks that auth profiles are uniquely named, list
+// the URLs they may be sent to and use a per-request auth mode; OAuth2 lives
+// in the shared client's transport and can't be switched per query
+func validateAuthProfiles(profiles []models.AuthProfile) error {
+	seen := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("auth profiles must have a name")
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("duplicate auth profile %q", profile.Name)
+		}
+		seen[profile.Name] = true
+
+		switch profile.AuthMode {
+		case models.AuthModeNone, models.AuthModeAPIKey, models.AuthModeBasic, models.AuthModeBearer:
+		default:
+			return fmt.Errorf("auth profile %q has unsupported auth mode %q", profile.Name, profile.AuthMode)
+		}
+
+		if len(profile.AllowedURLs) == 0 {
+			return fmt.Errorf("auth profile %q must list its allowed URLs", profile.Name)
+		}
+		for _, allowed := range profile.AllowedURLs {
+			if _, err := normalizeBaseURL(allowed); err != nil {
+				return fmt.Errorf("auth profile %q has invalid allowed URL %q: %w", profile.Name, allowed, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setAuthHeaders adds the configured authentication to a backend request.
+// Without an explicit auth mode the first configured of bearer token, API
+// key and basic auth is used. OAuth2 is applied by the client's transport.
+func setAuthHeaders(req *http.Request, config *models.DataSourceConfig) {
+	switch config.AuthMode {
+	case models.AuthModeBearer:
+		req.Header.Set("Authorization", "Bearer "+config.BearerToken)
+	case models.AuthModeAPIKey:
+		req.Header.Set("X-API-Key", config.APIKey)
+	case models.AuthModeBasic:
+		req.SetBasicAuth(config.BasicAuthUser, config.BasicAuthPass)
+	case models.AuthModeAuto:
+		if config.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+config.BearerToken)
+		} else if config.APIKey != "" {
+			req.Header.Set("X-API-Key", config.APIKey)
+		} else if config.BasicAuthUser != "" && config.BasicAuthPass != "" {
+			req.SetBasicAuth(config.BasicAuthUser, config.BasicAuthPass)
+		}
+	}
+}
+
+// validateAuth checks that the selected auth mode has its credentials
+func validateAuth(config *models.DataSourceConfig) error {
+	switch config.AuthMode {
+	case models.AuthModeAuto, models.AuthModeNone:
+		return nil
+	case models.AuthModeBearer:
+		if config.BearerToken == "" {
+			return fmt.Errorf("auth mode %q requires a bearer token", config.AuthMode)
+		}
+	case models.AuthModeAPIKey:
+		if config.APIKey == "" {
+			return fmt.Errorf("auth mode %q requires an API key", config.AuthMode)
+		}
+	case models.AuthModeBasic:
+		if config.BasicAuthUser == "" || config.BasicAuthPass == "" {
+			return fmt.Errorf("auth mode %q requires a username and password", config.AuthMode)
+		}
+	case models.AuthModeOAuth2:
+		if config.OAuth2TokenURL == "" || config.OAuth2ClientID == "" || config.OAuth2ClientSecret == "" {
+			return fmt.Errorf("auth mode %q requires a token URL, client ID and client secret", config.AuthMode)
+		}
+	default:
+		return fmt.Errorf("unknown auth mode %q", config.AuthMode)
+	}
+	return nil
+}
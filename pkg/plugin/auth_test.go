@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLegacyPlaintextCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]interface{}
+		secure     map[string]string
+		wantHeader string
+		wantValue  string
+	}{
+		{name: "legacy bearer token", settings: map[string]interface{}{"authMode": "bearer", "bearerToken": "legacy"}, wantHeader: "Authorization", wantValue: "Bearer legacy"},
+		{name: "legacy API key", settings: map[string]interface{}{"authMode": "apiKey", "apiKey": "legacy"}, wantHeader: "X-API-Key", wantValue: "legacy"},
+		{name: "legacy basic auth password", settings: map[string]interface{}{"authMode": "basic", "basicAuthUser": "admin", "basicAuthPass": "legacy"}, wantHeader: "Authorization", wantValue: "Basic YWRtaW46bGVnYWN5"},
+		{name: "secure setting wins", settings: map[string]interface{}{"authMode": "bearer", "bearerToken": "legacy"}, secure: map[string]string{"bearerToken": "secure"}, wantHeader: "Authorization", wantValue: "Bearer secure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(tt.wantHeader)
+			}))
+			defer srv.Close()
+
+			settings := map[string]interface{}{"restUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, tt.secure)
+
+			if result := checkHealth(t, ds); result.Status != backend.HealthStatusOk {
+				t.Fatalf("health = %v: %s", result.Status, result.Message)
+			}
+			if got != tt.wantValue {
+				t.Errorf("%s = %q, want %q", tt.wantHeader, got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestMissingAuthSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]interface{}
+		secure      map[string]string
+		wantMessage string
+	}{
+		{name: "bearer", settings: map[string]interface{}{"authMode": "bearer"}, wantMessage: `Authentication is misconfigured: auth mode "bearer" requires a bearer token`},
+		{name: "API key", settings: map[string]interface{}{"authMode": "apiKey"}, wantMessage: `Authentication is misconfigured: auth mode "apiKey" requires an API key`},
+		{name: "basic without password", settings: map[string]interface{}{"authMode": "basic", "basicAuthUser": "admin"}, wantMessage: `Authentication is misconfigured: auth mode "basic" requires a username and password`},
+		{name: "empty secure setting", settings: map[string]interface{}{"authMode": "bearer", "bearerToken": "legacy"}, secure: map[string]string{"bearerToken": ""}, wantMessage: `Authentication is misconfigured: auth mode "bearer" requires a bearer token`},
+		{name: "unknown mode", settings: map[string]interface{}{"authMode": "kerberos"}, wantMessage: `Authentication is misconfigured: unknown auth mode "kerberos"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]interface{}{"restUrl": "http://backend.invalid"}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, tt.secure)
+			result := checkHealth(t, ds)
+
+			if result.Status != backend.HealthStatusError || result.Message != tt.wantMessage {
+				t.Errorf("health = %v %q, want error %q", result.Status, result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
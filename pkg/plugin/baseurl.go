@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// withBaseURLOverride returns a view of the datasource whose backend URL for
+// the query's type is replaced by the query's BaseURLOverride and whose
+// credentials are replaced by its AuthProfile. Both require AllowURLOverride,
+// and to keep dashboard variables from turning the plugin into an open proxy,
+// the override must match an entry of AllowedBaseURLs exactly and a profile
+// is only sent to the URLs it lists. Headers still come from the datasource
+// configuration.
+func (d *Datasource) withBaseURLOverride(queryModel *models.QueryModel) (*Datasource, error) {
+	if queryModel.BaseURLOverride == "" && queryModel.AuthProfile == "" {
+		return d, nil
+	}
+	if !d.config.AllowURLOverride {
+		return nil, fmt.Errorf("base URL and auth overrides are disabled for this datasource")
+	}
+
+	config := *d.config
+	if queryModel.BaseURLOverride != "" {
+		override, err := d.allowedBaseURL(queryModel.BaseURLOverride)
+		if err != nil {
+			return nil, err
+		}
+		switch queryModel.QueryType {
+		case models.QueryTypePrometheus:
+			config.PrometheusURL = override
+		case models.QueryTypeLoki:
+			config.LokiURL = override
+		case models.QueryTypeREST:
+			config.RESTURL = override
+		}
+	}
+	if queryModel.AuthProfile != "" {
+		if err := applyAuthProfile(&config, queryModel.AuthProfile, backendURL(&config, queryModel.QueryType)); err != nil {
+			return nil, err
+		}
+	}
+
+	view := *d
+	view.config = &config
+	return &view, nil
+}
+
+// migrateURLOverride turns AllowURLOverride on for settings saved before
+// the flag existed, where listing AllowedBaseURLs alone enabled overrides.
+// It reports whether the flag was turned on.
+func migrateURLOverride(config *models.DataSourceConfig, jsonData json.RawMessage) bool {
+	if config.AllowURLOverride || len(config.AllowedBaseURLs) == 0 {
+		return false
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &settings); err != nil {
+		return false
+	}
+	if _, ok := settings["allowUrlOverride"]; ok {
+		return false
+	}
+	config.AllowURLOverride = true
+	return true
+}
+
+// allowedBaseURL returns the normalized override when AllowedBaseURLs
+// lists it
+func (d *Datasource) allowedBaseURL(raw string) (string, error) {
+	override, err := normalizeBaseURL(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL override: %w", err)
+	}
+	if !containsBaseURL(d.config.AllowedBaseURLs, override) {
+		return "", fmt.Errorf("base URL override %s is not in the allowed base URLs", override)
+	}
+	return override, nil
+}
+
+// containsBaseURL reports whether list has an entry normalizing to the
+// normalized URL u
+func containsBaseURL(list []string, u string) bool {
+	for _, entry := range list {
+		if normalized, err := normalizeBaseURL(entry); err == nil && normalized == u {
+			return true
+		}
+	}
+	return false
+}
+
+// backendURL returns the configured backend URL of a query type
+func backendURL(config *models.DataSourceConfig, queryType models.QueryType) string {
+	switch queryType {
+	case models.QueryTypePrometheus:
+		return config.PrometheusURL
+	case models.QueryTypeLoki:
+		return config.LokiURL
+	case models.QueryTypeREST:
+		return config.RESTURL
+	}
+	return ""
+}
+
+// applyAuthProfile replaces the credentials of config with the named
+// profile's, provided the profile allows the query's backend URL
+func applyAuthProfile(config *models.DataSourceConfig, name, target string) error {
+	// The OAuth2 token is set by the shared client and would win
+	if config.OAuth2TokenURL != "" {
+		return fmt.Errorf("auth profiles can't be used while OAuth2 is configured")
+	}
+
+	for _, profile := range config.AuthProfiles {
+		if profile.Name != name {
+			continue
+		}
+		normalized, err := normalizeBaseURL(target)
+		if err != nil {
+			return fmt.Errorf("auth profile %q needs a valid backend URL: %w", name, err)
+		}
+		if !containsBaseURL(profile.AllowedURLs, normalized) {
+			return fmt.Errorf("auth profile %q is not allowed for %s", name, normalized)
+		}
+		config.AuthMode = profile.AuthMode
+		config.APIKey = profile.APIKey
+		config.BasicAuthUser = profile.BasicAuthUser
+		config.BasicAuthPass = profile.BasicAuthPass
+		config.BearerToken = profile.BearerToken
+		if err := validateAuth(config); err != nil {
+			return fmt.Errorf("auth profile %q: %w", name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown auth profile %q", name)
+}
+
+// normalizeBaseURL canonicalizes an http(s) base URL for comparison,
+// rejecting credentials, query strings and fragments
+func normalizeBaseURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("must not contain credentials, a query or a fragment")
+	}
+
+	return scheme + "://" + strings.ToLower(u.Host) + strings.TrimSuffix(u.EscapedPath(), "/"), nil
+}
@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultClockSkewThreshold is the skew above which a warning is reported
+const defaultClockSkewThreshold = 30 * time.Second
+
+// clockSkew is the measured offset of one backend's clock from the plugin's;
+// positive values mean the backend is ahead
+type clockSkew struct {
+	Source  string  `json:"source"`
+	Seconds float64 `json:"seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// measureClockSkew compares a backend's Date response header with the local
+// clock at the midpoint of the request. The probe carries the source's
+// headers and authentication like any other request to it; any response
+// carries a Date header, so the status code is irrelevant.
+func (d *Datasource) measureClockSkew(ctx context.Context, baseURL string, headers map[string]string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout(d.config))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", baseURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	setConfigHeaders(req, headers, d.config.Headers)
+	setAuthHeaders(req, d.config)
+
+	sent := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	received := time.Now()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("response has no valid Date header")
+	}
+
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	return date.Sub(midpoint), nil
+}
+
+// checkClockSkew measures the skew of every configured backend
+func (d *Datasource) checkClockSkew(ctx context.Context) []clockSkew {
+	sources := []struct {
+		name, url string
+		headers   map[string]string
+	}{
+		{"Prometheus", d.config.PrometheusURL, d.config.PrometheusHeaders},
+		{"Loki", d.config.LokiURL, d.config.LokiHeaders},
+		{"REST API", d.config.RESTURL, d.config.RESTHeaders},
+	}
+
+	var skews []clockSkew
+	for _, s := range sources {
+		if s.url == "" {
+			continue
+		}
+		skew, err := d.measureClockSkew(ctx, s.url, s.headers)
+		if err != nil {
+			skews = append(skews, clockSkew{Source: s.name, Error: err.Error()})
+			continue
+		}
+		skews = append(skews, clockSkew{Source: s.name, Seconds: skew.Seconds()})
+	}
+	return skews
+}
+
+// clockSkewThreshold returns the configured warning threshold
+func (d *Datasource) clockSkewThreshold() time.Duration {
+	if d.config.ClockSkewThresholdSeconds > 0 {
+		return time.Duration(d.config.ClockSkewThresholdSeconds) * time.Second
+	}
+	return defaultClockSkewThreshold
+}
+
+// clockSkewWarnings describes the skews exceeding the threshold
+func clockSkewWarnings(skews []clockSkew, threshold time.Duration) []string {
+	var warnings []string
+	for _, s := range skews {
+		if s.Error == "" && math.Abs(s.Seconds) > threshold.Seconds() {
+			warnings = append(warnings, fmt.Sprintf("%s clock is off by %.0fs", s.Source, s.Seconds))
+		}
+	}
+	return warnings
+}
+
+// handleClockSkewResource reports the measured skew of each backend
+func (d *Datasource) handleClockSkewResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	skews := d.checkClockSkew(ctx)
+	body, err := json.Marshal(map[string]interface{}{
+		"thresholdSeconds": d.clockSkewThreshold().Seconds(),
+		"sources":          skews,
+		"warnings":         clockSkewWarnings(skews, d.clockSkewThreshold()),
+	})
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 500,
+			Body:   []byte(fmt.Sprintf(`{"error": "%v"}`, err)),
+		})
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  200,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+}
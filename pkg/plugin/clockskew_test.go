@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestClockSkewHealthWarning(t *testing.T) {
+	tests := []struct {
+		name        string
+		skew        time.Duration
+		threshold   int
+		wantWarning string
+	}{
+		{name: "in sync"},
+		{name: "ahead", skew: 2 * time.Minute, wantWarning: "REST API clock is off by 1"},
+		{name: "behind", skew: -2 * time.Minute, wantWarning: "REST API clock is off by -1"},
+		{name: "within a raised threshold", skew: 2 * time.Minute, threshold: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var probes []http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				probes = append(probes, r.Header.Clone())
+				mu.Unlock()
+				w.Header().Set("Date", time.Now().Add(tt.skew).UTC().Format(http.TimeFormat))
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":                   srv.URL,
+				"restHeaders":               map[string]string{"X-Scope-OrgID": "tenant-a"},
+				"headers":                   map[string]string{"X-Client": "grafana"},
+				"authMode":                  "bearer",
+				"clockSkewCheck":            true,
+				"clockSkewThresholdSeconds": tt.threshold,
+			}, map[string]string{"bearerToken": "secret"})
+			result := checkHealth(t, ds)
+
+			if result.Status != backend.HealthStatusOk {
+				t.Errorf("status = %v, want ok: %s", result.Status, result.Message)
+			}
+			hasWarning := strings.Contains(result.Message, "warning:")
+			switch {
+			case tt.wantWarning == "" && hasWarning:
+				t.Errorf("message = %q, want no warning", result.Message)
+			case tt.wantWarning != "" && !strings.Contains(result.Message, tt.wantWarning):
+				t.Errorf("message = %q, want %q", result.Message, tt.wantWarning)
+			}
+
+			// Date headers have a one second resolution
+			var details struct {
+				ClockSkew []clockSkew `json:"clockSkew"`
+			}
+			if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+				t.Fatalf("decode details: %v", err)
+			}
+			if len(details.ClockSkew) != 1 || math.Abs(details.ClockSkew[0].Seconds-tt.skew.Seconds()) > 2 {
+				t.Errorf("clock skew = %+v, want about %s", details.ClockSkew, tt.skew)
+			}
+
+			// The health probe and the skew probe both reach the server
+			mu.Lock()
+			defer mu.Unlock()
+			if len(probes) != 2 {
+				t.Fatalf("got %d requests, want 2", len(probes))
+			}
+			for _, h := range probes {
+				if h.Get("X-Scope-OrgID") != "tenant-a" || h.Get("X-Client") != "grafana" || h.Get("Authorization") != "Bearer secret" {
+					t.Errorf("probe headers = %v, want the source, global and auth headers", h)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// compressionEnabled reports whether a query uses gzip, with the query's
+// Compress setting overriding the datasource default
+func compressionEnabled(config *models.DataSourceConfig, queryModel *models.QueryModel) bool {
+	if queryModel.Compress != nil {
+		return *queryModel.Compress
+	}
+	return config.Compress
+}
+
+// negotiateCompression asks for an uncompressed response only when the query
+// explicitly turns compression off; otherwise the transport requests gzip
+// and decodes it transparently
+func negotiateCompression(req *http.Request, queryModel *models.QueryModel) {
+	if queryModel.Compress != nil && !*queryModel.Compress {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+}
+
+// gzipBody compresses a request body
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
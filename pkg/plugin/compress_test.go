@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCompressionPerQueryOverride(t *testing.T) {
+	tests := []struct {
+		name           string
+		configCompress bool
+		queryCompress  interface{}
+		acceptEncoding string
+		gzippedBody    bool
+	}{
+		{name: "default leaves transport gzip", configCompress: false, queryCompress: nil, acceptEncoding: "gzip", gzippedBody: false},
+		{name: "datasource enabled", configCompress: true, queryCompress: nil, acceptEncoding: "gzip", gzippedBody: true},
+		{name: "query enables over datasource", configCompress: false, queryCompress: true, acceptEncoding: "gzip", gzippedBody: true},
+		{name: "query disables over datasource", configCompress: true, queryCompress: false, acceptEncoding: "identity", gzippedBody: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				accept   string
+				encoding string
+				body     string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				accept = r.Header.Get("Accept-Encoding")
+				encoding = r.Header.Get("Content-Encoding")
+				reader := io.Reader(r.Body)
+				if encoding == "gzip" {
+					zr, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Errorf("invalid gzip body: %v", err)
+						return
+					}
+					reader = zr
+				}
+				raw, _ := io.ReadAll(reader)
+				body = string(raw)
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `[{"value": 1}]`)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL, "compress": tt.configCompress}, nil)
+			model := map[string]interface{}{
+				"queryType":    "rest",
+				"restEndpoint": "/data",
+				"restMethod":   "POST",
+				"restBody":     `{"q": 1}`,
+			}
+			if tt.queryCompress != nil {
+				model["compress"] = tt.queryCompress
+			}
+
+			res := runQuery(t, ds, model)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if accept != tt.acceptEncoding {
+				t.Errorf("Accept-Encoding = %q, want %q", accept, tt.acceptEncoding)
+			}
+			if got := encoding == "gzip"; got != tt.gzippedBody {
+				t.Errorf("gzipped body = %v, want %v", got, tt.gzippedBody)
+			}
+			if body != `{"q": 1}` {
+				t.Errorf("body = %q", body)
+			}
+		})
+	}
+}
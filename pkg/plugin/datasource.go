@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sameersah/GrafanaConnect/pkg/models"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
 // Make sure Datasource implements required interfaces
@@ -16,41 +21,121 @@ var (
 	_ backend.QueryDataHandler      = (*Datasource)(nil)
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
 	_ backend.CallResourceHandler   = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
+// defaultQueryConcurrency bounds how many queries of one request run at once
+const defaultQueryConcurrency = 8
+
 // Datasource is the main plugin struct
 type Datasource struct {
 	settings *backend.DataSourceInstanceSettings
 	config   *models.DataSourceConfig
 	logger   log.Logger
+
+	// client and its transport are shared by all backend requests so
+	// connections are pooled and reused
+	client    *http.Client
+	transport *http.Transport
+
+	// stale serves the last good frames when a backend fails; nil when disabled
+	stale *staleCache
+
+	// cache reuses recent successful results; nil when disabled
+	cache *responseCache
+
+	// versions caches the backend versions reported by the version resource
+	versions *versionCache
+
+	// metricNames caches the metric names used for editor autocompletion
+	metricNames *metricNameCache
+
+	// derivedFields are the compiled Loki derived field matchers, and
+	// derivedFieldErrors describe the configured ones that were skipped
+	derivedFields      []derivedField
+	derivedFieldErrors []string
 }
 
 // NewDatasource creates a new instance of the datasource
 func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 	ds := &Datasource{
-		settings: &settings,
-		logger:   log.New(),
+		settings:    &settings,
+		logger:      log.New(),
+		versions:    &versionCache{},
+		metricNames: &metricNameCache{},
 	}
 
-	// Parse configuration
-	config := &models.DataSourceConfig{}
+	// Parse configuration, keeping defaults for fields absent from JSONData
+	config := &models.DataSourceConfig{
+		StrictQueryTypes: true,
+	}
 	if err := json.Unmarshal(settings.JSONData, config); err != nil {
 		ds.logger.Warn("Failed to parse JSON data, using defaults", "error", err)
 	}
 
 	// Load secure settings
-	if val, ok := settings.DecryptedSecureJSONData["apiKey"]; ok {
-		config.APIKey = val
+	loadSecrets(config, settings.DecryptedSecureJSONData, ds.logger)
+	loadAuthProfileSecrets(config, settings.DecryptedSecureJSONData)
+	if val, ok := settings.DecryptedSecureJSONData["hmacSecret"]; ok {
+		config.HMACSecret = val
+	}
+	if val, ok := settings.DecryptedSecureJSONData["oauth2ClientSecret"]; ok {
+		config.OAuth2ClientSecret = val
+	}
+	if val, ok := settings.DecryptedSecureJSONData["tlsCACert"]; ok {
+		config.TLSCACert = val
+	}
+
+	if _, err := parseScrapeInterval(config.ScrapeInterval); err != nil {
+		return nil, err
+	}
+	for _, maxRange := range []string{config.PrometheusMaxRange, config.LokiMaxRange} {
+		if _, err := parseMaxRange(maxRange); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, timeout := range map[string]time.Duration{
+		"timeoutSeconds":       requestTimeout(config),
+		"healthTimeoutSeconds": healthTimeout(config),
+	} {
+		if timeout > maxSensibleTimeout {
+			ds.logger.Warn("Configured timeout is unusually high", "setting", name, "timeout", timeout)
+		}
 	}
-	if val, ok := settings.DecryptedSecureJSONData["basicAuthPass"]; ok {
-		config.BasicAuthPass = val
+
+	if err := validateAuthProfiles(config.AuthProfiles); err != nil {
+		return nil, err
+	}
+	if migrateURLOverride(config, settings.JSONData) {
+		ds.logger.Info("Enabling base URL overrides for settings saved before allowUrlOverride existed; save the datasource to make this explicit")
 	}
-	if val, ok := settings.DecryptedSecureJSONData["bearerToken"]; ok {
-		config.BearerToken = val
+
+	// A bad derived field only affects Loki log frames, not the datasource
+	derivedFields, derivedErrs := compileDerivedFields(config.LokiDerivedFields)
+	for _, err := range derivedErrs {
+		ds.logger.Error("Skipping Loki derived field", "error", err)
+		ds.derivedFieldErrors = append(ds.derivedFieldErrors, err.Error())
+	}
+
+	transport, err := newTransport(config, ds.logger)
+	if err != nil {
+		return nil, err
 	}
 
 	ds.config = config
+	ds.transport = transport
+	ds.derivedFields = derivedFields
+	ds.client = &http.Client{
+		Timeout: requestTimeout(config),
+		Transport: &limitedTransport{
+			base:    newOAuth2Transport(config, transport),
+			limiter: newRequestLimiter(config.MaxConcurrentRequests),
+		},
+	}
+	ds.stale = newStaleCache(config.StaleCacheSize, time.Duration(config.StaleCacheMaxAgeSeconds)*time.Second)
+	ds.cache = newResponseCache(config.CacheMaxEntries, time.Duration(config.CacheTTLSeconds)*time.Second)
 	ds.logger.Info("Datasource initialized", "prometheusUrl", config.PrometheusURL, "lokiUrl", config.LokiURL)
 
 	return ds, nil
@@ -59,20 +144,64 @@ func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSetti
 // Dispose cleans up resources
 func (d *Datasource) Dispose() {
 	d.logger.Info("Disposing datasource")
+	d.transport.CloseIdleConnections()
 }
 
 // QueryData handles data queries
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	response := backend.NewQueryDataResponse()
 
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, queryConcurrency(d.config))
+	)
+
 	for _, q := range req.Queries {
-		res := d.handleQuery(ctx, q)
-		response.Responses[q.RefID] = res
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// Queries not yet started fail with the cancellation
+			mu.Lock()
+			response.Responses[q.RefID] = backend.DataResponse{Error: ctx.Err()}
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(q backend.DataQuery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := d.runQuery(ctx, q)
+
+			mu.Lock()
+			response.Responses[q.RefID] = res
+			mu.Unlock()
+		}(q)
 	}
+	wg.Wait()
 
 	return response, nil
 }
 
+// runQuery runs a query the same way for panels and exports: capped to the
+// frame limit, with its error classified, through the response cache and
+// with the stale fallback
+func (d *Datasource) runQuery(ctx context.Context, q backend.DataQuery) backend.DataResponse {
+	return d.stale.apply(q, d.cache.fetch(q, func() backend.DataResponse {
+		return classifyErrorResponse(limitFrames(d.handleQuery(ctx, q), d.config.MaxFramesPerQuery))
+	}))
+}
+
+// queryConcurrency returns how many queries of one request run in parallel
+func queryConcurrency(config *models.DataSourceConfig) int {
+	if config.QueryConcurrency > 0 {
+		return config.QueryConcurrency
+	}
+	return defaultQueryConcurrency
+}
+
 // handleQuery routes queries to appropriate handlers
 func (d *Datasource) handleQuery(ctx context.Context, query backend.DataQuery) backend.DataResponse {
 	var queryModel models.QueryModel
@@ -82,18 +211,61 @@ func (d *Datasource) handleQuery(ctx context.Context, query backend.DataQuery) b
 		}
 	}
 
+	if err := queryModel.Validate(); err != nil {
+		return backend.DataResponse{
+			Error: err,
+		}
+	}
+
 	queryModel.RefID = query.RefID
 
 	d.logger.Debug("Handling query", "type", queryModel.QueryType, "refId", query.RefID)
 
+	// The rest of the query runs against the overridden backend, if any
+	d, err := d.withBaseURLOverride(&queryModel)
+	if err != nil {
+		return backend.DataResponse{
+			Error: err,
+		}
+	}
+
 	switch queryModel.QueryType {
 	case models.QueryTypePrometheus:
-		return d.handlePrometheusQuery(ctx, query, &queryModel)
+		return d.withMaxRange(query, d.config.PrometheusMaxRange, func(q backend.DataQuery) backend.DataResponse {
+			return d.handlePrometheusQuery(ctx, q, &queryModel)
+		})
 	case models.QueryTypeLoki:
-		return d.handleLokiQuery(ctx, query, &queryModel)
+		return d.withMaxRange(query, d.config.LokiMaxRange, func(q backend.DataQuery) backend.DataResponse {
+			return d.handleLokiQuery(ctx, q, &queryModel)
+		})
 	case models.QueryTypeREST:
 		return d.handleRESTQuery(ctx, query, &queryModel)
+	case "":
+		// Freshly added panels have no query type yet; that isn't an error
+		frame := data.NewFrame("")
+		frame.Meta = &data.FrameMeta{
+			Notices: []data.Notice{{
+				Severity: data.NoticeSeverityInfo,
+				Text:     "Please select a query type",
+			}},
+		}
+		return backend.DataResponse{
+			Frames: data.Frames{frame},
+		}
 	default:
+		if !d.config.StrictQueryTypes {
+			// Mixed dashboards may route foreign queries here; don't fail the panel
+			frame := data.NewFrame("")
+			frame.Meta = &data.FrameMeta{
+				Notices: []data.Notice{{
+					Severity: data.NoticeSeverityWarning,
+					Text:     fmt.Sprintf("Ignoring query with unknown query type: %s", queryModel.QueryType),
+				}},
+			}
+			return backend.DataResponse{
+				Frames: data.Frames{frame},
+			}
+		}
 		return backend.DataResponse{
 			Error: fmt.Errorf("unknown query type: %s", queryModel.QueryType),
 		}
@@ -102,30 +274,39 @@ func (d *Datasource) handleQuery(ctx context.Context, query backend.DataQuery) b
 
 // CheckHealth checks the health of the datasource
 func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	var status backend.HealthStatus
-	var message string
+	if err := validateAuth(d.config); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: fmt.Sprintf("Authentication is misconfigured: %v", err),
+		}, nil
+	}
 
 	// Check if at least one data source is configured
 	if d.config.PrometheusURL == "" && d.config.LokiURL == "" && d.config.RESTURL == "" {
-		status = backend.HealthStatusError
-		message = "No data source URLs configured. Please configure at least one data source."
-	} else {
-		status = backend.HealthStatusOk
-		message = "Data source is ready"
-		
-		// Try to verify connectivity
-		if d.config.PrometheusURL != "" {
-			if err := d.checkPrometheusHealth(ctx); err != nil {
-				status = backend.HealthStatusError
-				message = fmt.Sprintf("Prometheus connection issue: %v", err)
-			}
-		}
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "No data source URLs configured. Please configure at least one data source.",
+		}, nil
+	}
+
+	// Verify connectivity of every configured source
+	probes := d.probeBackends(ctx)
+
+	// Skewed clocks explain missing data at the edge of the time range
+	var extra map[string]interface{}
+	var warnings []string
+	if d.config.ClockSkewCheck {
+		skews := d.checkClockSkew(ctx)
+		warnings = clockSkewWarnings(skews, d.clockSkewThreshold())
+		extra = map[string]interface{}{"clockSkew": skews}
 	}
 
-	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: message,
-	}, nil
+	result := aggregateHealth(probes, d.config.HealthMode, extra)
+	if len(warnings) > 0 {
+		result.Message += "; warning: " + strings.Join(warnings, ", ")
+	}
+
+	return result, nil
 }
 
 // CallResource handles resource calls
@@ -136,11 +317,29 @@ func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResource
 	switch req.Path {
 	case "prometheus":
 		return d.handlePrometheusResource(ctx, req, sender)
+	case "prometheus/label_values":
+		return d.handleLabelValuesResource(ctx, req, sender)
+	case "prometheus/metrics":
+		return d.handleMetricNamesResource(ctx, req, sender)
+	case "prometheus/series":
+		return d.handleSeriesResource(ctx, req, sender)
+	case "prometheus/metadata":
+		return d.handleMetadataResource(ctx, req, sender)
 	case "loki":
 		return d.handleLokiResource(ctx, req, sender)
 	case "rest":
 		return d.handleRESTResource(ctx, req, sender)
+	case "export":
+		return d.handleExportResource(ctx, req, sender)
+	case "clockskew":
+		return d.handleClockSkewResource(ctx, req, sender)
+	case "version":
+		return d.handleVersionResource(ctx, req, sender)
 	default:
+		// Label discovery paths carry the label name
+		if strings.HasPrefix(req.Path, "loki/label") {
+			return d.handleLokiLabelsResource(ctx, req, sender)
+		}
 		return sender.Send(&backend.CallResourceResponse{
 			Status: 404,
 			Body:   []byte(`{"error": "Unknown resource path"}`),
@@ -153,7 +352,27 @@ func (d *Datasource) checkPrometheusHealth(ctx context.Context) error {
 	promHandler := &PrometheusHandler{
 		config: d.config,
 		logger: d.logger,
+		client: d.client,
 	}
 	return promHandler.checkHealth(ctx)
 }
 
+// checkLokiHealth verifies Loki connectivity
+func (d *Datasource) checkLokiHealth(ctx context.Context) error {
+	lokiHandler := &LokiHandler{
+		config: d.config,
+		logger: d.logger,
+		client: d.client,
+	}
+	return lokiHandler.checkHealth(ctx)
+}
+
+// checkRESTHealth verifies the configured REST health endpoint
+func (d *Datasource) checkRESTHealth(ctx context.Context) error {
+	restHandler := &RESTAPIHandler{
+		config: d.config,
+		logger: d.logger,
+		client: d.client,
+	}
+	return restHandler.checkHealth(ctx)
+}
@@ -0,0 +1,191 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// slowServer answers REST queries after delay with the request path as value
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"path": %q}]`, r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryDataRunsQueriesConcurrently(t *testing.T) {
+	const (
+		delay   = 200 * time.Millisecond
+		queries = 5
+	)
+
+	tests := []struct {
+		name        string
+		concurrency int
+		minTime     time.Duration
+		maxTime     time.Duration
+	}{
+		{name: "default concurrency", minTime: delay, maxTime: 2 * delay},
+		{name: "bounded pool", concurrency: 2, minTime: 3 * delay, maxTime: 4 * delay},
+		{name: "sequential", concurrency: 1, minTime: queries * delay, maxTime: (queries + 1) * delay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slowServer(t, delay)
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL, "queryConcurrency": tt.concurrency}, nil)
+
+			var batch []backend.DataQuery
+			for i := 0; i < queries; i++ {
+				refID := string(rune('A' + i))
+				batch = append(batch, testQuery(t, refID, map[string]interface{}{"queryType": "rest", "restEndpoint": "/" + refID}))
+			}
+
+			start := time.Now()
+			resp := runQueries(t, ds, batch...)
+			elapsed := time.Since(start)
+
+			if elapsed < tt.minTime || elapsed > tt.maxTime {
+				t.Errorf("took %v, want between %v and %v", elapsed, tt.minTime, tt.maxTime)
+			}
+			if len(resp.Responses) != queries {
+				t.Fatalf("got %d responses, want %d", len(resp.Responses), queries)
+			}
+			for refID, res := range resp.Responses {
+				if res.Error != nil {
+					t.Errorf("query %s failed: %v", refID, res.Error)
+					continue
+				}
+				if got := res.Frames[0].Fields[0].At(0); fmt.Sprint(derefString(got)) != "/"+refID {
+					t.Errorf("query %s got the result for %v", refID, derefString(got))
+				}
+			}
+		})
+	}
+}
+
+func TestQueryDataKeepsPerQueryErrors(t *testing.T) {
+	srv := slowServer(t, 0)
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+
+	resp := runQueries(t, ds,
+		testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/a"}),
+		testQuery(t, "B", map[string]interface{}{"queryType": "unknown"}),
+	)
+	if res := resp.Responses["A"]; res.Error != nil {
+		t.Errorf("query A failed: %v", res.Error)
+	}
+	if res := resp.Responses["B"]; res.Error == nil {
+		t.Error("query B succeeded, want an error")
+	}
+}
+
+func TestQueryDataCancellation(t *testing.T) {
+	srv := slowServer(t, 5*time.Second)
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/a"}),
+		testQuery(t, "B", map[string]interface{}{"queryType": "rest", "restEndpoint": "/b"}),
+	}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled request took %v", elapsed)
+	}
+	if err != nil {
+		return
+	}
+	for refID, res := range resp.Responses {
+		if res.Error == nil || !strings.Contains(res.Error.Error(), "context canceled") {
+			t.Errorf("query %s error = %v, want cancellation", refID, res.Error)
+		}
+	}
+}
+
+// derefString dereferences a nullable string value
+func derefString(v interface{}) interface{} {
+	if s, ok := v.(*string); ok && s != nil {
+		return *s
+	}
+	return v
+}
+
+func TestUnknownQueryType(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]interface{}
+		wantErr    string
+		wantNotice string
+	}{
+		{name: "strict by default", wantErr: "unknown query type: influx"},
+		{name: "strict", settings: map[string]interface{}{"strictQueryTypes": true}, wantErr: "unknown query type: influx"},
+		{name: "lenient", settings: map[string]interface{}{"strictQueryTypes": false}, wantNotice: "Ignoring query with unknown query type: influx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDatasource(t, tt.settings, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "influx"})
+
+			if tt.wantErr != "" {
+				if res.Error == nil || res.Error.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("unexpected error: %v", res.Error)
+			}
+			if len(res.Frames) != 1 || !hasNotice(res.Frames[0], tt.wantNotice) {
+				t.Errorf("frames = %v, want one frame with notice %q", res.Frames, tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestEmptyQueryType(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		model    map[string]interface{}
+	}{
+		{name: "missing", model: map[string]interface{}{}},
+		{name: "empty", model: map[string]interface{}{"queryType": ""}},
+		{name: "empty with strict query types off", settings: map[string]interface{}{"strictQueryTypes": false}, model: map[string]interface{}{"queryType": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDatasource(t, tt.settings, nil)
+			res := runQuery(t, ds, tt.model)
+
+			if res.Error != nil {
+				t.Fatalf("unexpected error: %v", res.Error)
+			}
+			if len(res.Frames) != 1 || !hasNotice(res.Frames[0], "Please select a query type") {
+				t.Fatalf("frames = %v, want one frame asking for a query type", res.Frames)
+			}
+			if severity := res.Frames[0].Meta.Notices[0].Severity; severity != data.NoticeSeverityInfo {
+				t.Errorf("severity = %v, want info", severity)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// countingDNSCache returns a cache whose resolver fails every lookup that
+// isn't answered locally, counting the queries it would have sent
+func countingDNSCache(ttl time.Duration) (*dnsCache, *int32) {
+	var queries int32
+	c := newDNSCache(ttl)
+	c.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&queries, 1)
+			return nil, fmt.Errorf("no DNS in tests")
+		},
+	}
+	return c, &queries
+}
+
+func TestDNSCache(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	const host = "backend.invalid"
+
+	t.Run("cached resolution is reused within the TTL", func(t *testing.T) {
+		c, queries := countingDNSCache(time.Minute)
+		c.entries[host] = dnsEntry{addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Minute)}
+
+		for i := 0; i < 3; i++ {
+			conn, err := c.dialContext(context.Background(), "tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				t.Fatalf("dial %d: %v", i, err)
+			}
+			conn.Close()
+		}
+		if n := atomic.LoadInt32(queries); n != 0 {
+			t.Errorf("sent %d DNS queries, want none", n)
+		}
+	})
+
+	t.Run("expired resolution is looked up again", func(t *testing.T) {
+		c, queries := countingDNSCache(time.Minute)
+		c.entries[host] = dnsEntry{addrs: []string{"127.0.0.1"}, expires: time.Now().Add(-time.Second)}
+
+		if _, err := c.dialContext(context.Background(), "tcp", net.JoinHostPort(host, port)); err == nil {
+			t.Fatal("dial succeeded, want the failed lookup")
+		}
+		if atomic.LoadInt32(queries) == 0 {
+			t.Error("sent no DNS queries, want a fresh lookup")
+		}
+	})
+
+	t.Run("dial failure invalidates the entry", func(t *testing.T) {
+		c, _ := countingDNSCache(time.Minute)
+		c.entries[host] = dnsEntry{addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Minute)}
+
+		if _, err := c.dialContext(context.Background(), "tcp", net.JoinHostPort(host, closedPort)); err == nil {
+			t.Fatal("dial succeeded, want connection refused")
+		}
+		if _, ok := c.entries[host]; ok {
+			t.Error("entry kept after a failed dial")
+		}
+	})
+
+	t.Run("IP addresses bypass the cache", func(t *testing.T) {
+		c, queries := countingDNSCache(time.Minute)
+
+		conn, err := c.dialContext(context.Background(), "tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn.Close()
+		if len(c.entries) != 0 || atomic.LoadInt32(queries) != 0 {
+			t.Errorf("entries = %v, want the address dialed directly", c.entries)
+		}
+	})
+}
+
+func TestDNSCacheTTLSetting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	defer srv.Close()
+	localURL := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": localURL, "dnsCacheTTL": "1m"}, nil)
+	for i := 0; i < 2; i++ {
+		if res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}); res.Error != nil {
+			t.Fatalf("query %d failed: %v", i, res.Error)
+		}
+	}
+
+	for _, ttl := range []string{"soon", "0s", "-1m"} {
+		if _, err := newTransport(&models.DataSourceConfig{DNSCacheTTL: ttl}, nil); err == nil || !strings.Contains(err.Error(), "invalid DNS cache TTL") {
+			t.Errorf("TTL %q: error = %v, want invalid DNS cache TTL", ttl, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		queryType  string
+		status     int
+		body       string
+		wantSource backend.ErrorSource
+		wantStatus backend.Status
+	}{
+		{name: "Prometheus unauthorized", queryType: "prometheus", status: http.StatusUnauthorized, body: `unauthorized`, wantSource: backend.ErrorSourceDownstream, wantStatus: backend.StatusUnauthorized},
+		{name: "Prometheus server error", queryType: "prometheus", status: http.StatusInternalServerError, body: `boom`, wantSource: backend.ErrorSourceDownstream, wantStatus: backend.StatusInternal},
+		{name: "Prometheus unparseable JSON", queryType: "prometheus", status: http.StatusOK, body: `{not json`, wantSource: backend.ErrorSourcePlugin},
+		{name: "Loki forbidden", queryType: "loki", status: http.StatusForbidden, body: `forbidden`, wantSource: backend.ErrorSourceDownstream, wantStatus: backend.StatusForbidden},
+		{name: "Loki unparseable JSON", queryType: "loki", status: http.StatusOK, body: `[`, wantSource: backend.ErrorSourcePlugin},
+		{name: "REST not found", queryType: "rest", status: http.StatusNotFound, body: `missing`, wantSource: backend.ErrorSourceDownstream, wantStatus: backend.StatusNotFound},
+		{name: "REST HTML page", queryType: "rest", status: http.StatusOK, body: `<!DOCTYPE html><html></html>`, wantSource: backend.ErrorSourceDownstream, wantStatus: backend.StatusBadGateway},
+		{name: "REST unparseable JSON", queryType: "rest", status: http.StatusOK, body: `{not json`, wantSource: backend.ErrorSourcePlugin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"prometheusUrl": srv.URL,
+				"lokiUrl":       srv.URL,
+				"restUrl":       srv.URL,
+			}, nil)
+			res := runQuery(t, ds, map[string]interface{}{
+				"queryType":    tt.queryType,
+				"promQL":       "up",
+				"logQL":        `{job="api"}`,
+				"restEndpoint": "/data",
+			})
+
+			if res.Error == nil {
+				t.Fatal("expected an error")
+			}
+			if res.ErrorSource != tt.wantSource {
+				t.Errorf("ErrorSource = %q, want %q (%v)", res.ErrorSource, tt.wantSource, res.Error)
+			}
+			if tt.wantStatus != 0 && res.Status != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", res.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequestFailuresAreDownstream(t *testing.T) {
+	t.Run("unreachable backend", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+
+		ds := newTestDatasource(t, map[string]interface{}{"restUrl": url}, nil)
+		res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+		if res.ErrorSource != backend.ErrorSourceDownstream || res.Status != backend.StatusBadGateway {
+			t.Errorf("got source %q status %d: %v", res.ErrorSource, res.Status, res.Error)
+		}
+	})
+
+	t.Run("query deadline", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+		defer srv.Close()
+
+		ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{
+			testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/slow"}),
+		}})
+		if err != nil {
+			t.Fatalf("QueryData: %v", err)
+		}
+		res := resp.Responses["A"]
+		if res.ErrorSource != backend.ErrorSourceDownstream || res.Status != backend.StatusTimeout {
+			t.Errorf("got source %q status %d: %v", res.ErrorSource, res.Status, res.Error)
+		}
+	})
+}
+
+func TestClassifyErrorResponseKeepsExplicitSource(t *testing.T) {
+	res := classifyErrorResponse(backend.DataResponse{
+		Error:       fmt.Errorf("range too long"),
+		Status:      backend.StatusBadRequest,
+		ErrorSource: backend.ErrorSourcePlugin,
+	})
+	if res.ErrorSource != backend.ErrorSourcePlugin || res.Status != backend.StatusBadRequest {
+		t.Errorf("classification changed an explicit source: %+v", res)
+	}
+
+	if res := classifyErrorResponse(backend.DataResponse{}); res.ErrorSource != "" {
+		t.Errorf("successful response got source %q", res.ErrorSource)
+	}
+}
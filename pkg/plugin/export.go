@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// exportRequest is the body accepted by the export resource
+type exportRequest struct {
+	Query      json.RawMessage `json:"query"`
+	From       int64           `json:"from"`
+	To         int64           `json:"to"`
+	IntervalMs int64           `json:"intervalMs"`
+}
+
+// handleExportResource runs a query and returns its frames as CSV
+func (d *Datasource) handleExportResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req.Method != "POST" {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 405,
+			Body:   []byte(`{"error": "Export requires POST"}`),
+		})
+	}
+
+	var exportReq exportRequest
+	if err := json.Unmarshal(req.Body, &exportReq); err != nil || len(exportReq.Query) == 0 {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 400,
+			Body:   []byte(`{"error": "Export requires a JSON body with a query"}`),
+		})
+	}
+
+	// Default to the last hour when no range is given
+	to := time.Now()
+	if exportReq.To > 0 {
+		to = time.UnixMilli(exportReq.To)
+	}
+	from := to.Add(-time.Hour)
+	if exportReq.From > 0 {
+		from = time.UnixMilli(exportReq.From)
+	}
+
+	query := backend.DataQuery{
+		RefID:     "export",
+		Interval:  time.Duration(exportReq.IntervalMs) * time.Millisecond,
+		TimeRange: backend.TimeRange{From: from, To: to},
+		JSON:      exportReq.Query,
+	}
+
+	// Exports see the same data and errors as the panel
+	res := d.runQuery(ctx, query)
+	if res.Error != nil {
+		status := http.StatusBadRequest
+		if res.ErrorSource == backend.ErrorSourceDownstream {
+			status = http.StatusBadGateway
+		}
+		body, _ := json.Marshal(map[string]string{
+			"error":       res.Error.Error(),
+			"errorSource": string(res.ErrorSource),
+		})
+		return sender.Send(&backend.CallResourceResponse{
+			Status: status,
+			Body:   body,
+		})
+	}
+
+	body, err := framesToCSV(res.Frames)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 500,
+			Body:   []byte(fmt.Sprintf(`{"error": %q}`, err.Error())),
+		})
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status: 200,
+		Headers: map[string][]string{
+			"Content-Type":        {"text/csv; charset=utf-8"},
+			"Content-Disposition": {`attachment; filename="export.csv"`},
+		},
+		Body: body,
+	})
+}
+
+// framesToCSV flattens frames into a single CSV table. A leading "frame"
+// column identifies the source frame and the remaining columns are the union
+// of field names in order of first appearance; missing cells are empty.
+func framesToCSV(frames data.Frames) ([]byte, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				columns = append(columns, field.Name)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(append([]string{"frame"}, columns...)); err != nil {
+		return nil, err
+	}
+
+	for i, frame := range frames {
+		name := frame.Name
+		if name == "" {
+			name = fmt.Sprintf("frame%d", i)
+		}
+
+		rows, err := frame.RowLen()
+		if err != nil {
+			return nil, err
+		}
+
+		for row := 0; row < rows; row++ {
+			record := make([]string, len(columns)+1)
+			record[0] = name
+			for _, field := range frame.Fields {
+				for c, col := range columns {
+					if col == field.Name {
+						record[c+1] = formatCSVValue(field.At(row))
+						break
+					}
+				}
+			}
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// formatCSVValue renders a field value, dereferencing nullable values
+func formatCSVValue(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		v = rv.Elem().Interface()
+	}
+
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
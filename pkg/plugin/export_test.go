@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// exportResource posts a query to the export resource
+func exportResource(t *testing.T, ds *Datasource, query map[string]interface{}) *backend.CallResourceResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": query,
+		"from":  testTimeRange.From.UnixMilli(),
+		"to":    testTimeRange.To.UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("marshal export request: %v", err)
+	}
+	rec := callResource(t, ds, &backend.CallResourceRequest{Path: "export", Method: "POST", Body: body})
+	return rec.responses[0]
+}
+
+func TestExportCSV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"name": "a, b", "count": 1}, {"name": "c", "count": 2.5}]`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	resp := exportResource(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/rows"})
+
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Status, resp.Body)
+	}
+	if ct := resp.Headers["Content-Type"]; len(ct) == 0 || !strings.HasPrefix(ct[0], "text/csv") {
+		t.Errorf("Content-Type = %v", ct)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(resp.Body))).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v\n%s", err, resp.Body)
+	}
+	want := [][]string{
+		{"frame", "count", "name"},
+		{"frame0", "1", "a, b"},
+		{"frame0", "2.5", "c"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestExportMatchesPanelPipeline(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  int
+		wantSource  string
+		wantRecords int
+	}{
+		{name: "downstream failure", status: http.StatusUnauthorized, body: `denied`, wantStatus: http.StatusBadGateway, wantSource: "downstream"},
+		{name: "unparseable response", status: http.StatusOK, body: `{not json`, wantStatus: http.StatusBadRequest, wantSource: "plugin"},
+		{name: "frames capped", status: http.StatusOK, body: prometheusMatrix(4), wantStatus: http.StatusOK, wantRecords: 1 + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL, "maxFramesPerQuery": 2}, nil)
+			resp := exportResource(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up"})
+
+			if resp.Status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, tt.wantStatus, resp.Body)
+			}
+			if tt.wantSource != "" {
+				var body map[string]string
+				if err := json.Unmarshal(resp.Body, &body); err != nil {
+					t.Fatalf("error body: %v", err)
+				}
+				if body["errorSource"] != tt.wantSource {
+					t.Errorf("errorSource = %q, want %q", body["errorSource"], tt.wantSource)
+				}
+				return
+			}
+
+			records, err := csv.NewReader(strings.NewReader(string(resp.Body))).ReadAll()
+			if err != nil {
+				t.Fatalf("export is not valid CSV: %v", err)
+			}
+			if len(records) != tt.wantRecords {
+				t.Errorf("got %d records, want %d", len(records), tt.wantRecords)
+			}
+		})
+	}
+}
@@ -47,3 +47,32 @@ func (h *HandlerWrapper) CallResource(ctx context.Context, req *backend.CallReso
 	return ds.CallResource(ctx, req, sender)
 }
 
+// SubscribeStream implements backend.StreamHandler
+func (h *HandlerWrapper) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	instance, err := h.im.Get(ctx, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	ds := instance.(*Datasource)
+	return ds.SubscribeStream(ctx, req)
+}
+
+// PublishStream implements backend.StreamHandler
+func (h *HandlerWrapper) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	instance, err := h.im.Get(ctx, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	ds := instance.(*Datasource)
+	return ds.PublishStream(ctx, req)
+}
+
+// RunStream implements backend.StreamHandler
+func (h *HandlerWrapper) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	instance, err := h.im.Get(ctx, req.PluginContext)
+	if err != nil {
+		return err
+	}
+	ds := instance.(*Datasource)
+	return ds.RunStream(ctx, req, sender)
+}
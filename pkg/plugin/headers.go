@@ -0,0 +1,15 @@
+package plugin
+
+import "net/http"
+
+// setConfigHeaders applies a source's configured headers followed by the
+// global ones, so global headers override per-source values. Authentication
+// is added afterwards and wins over both.
+func setConfigHeaders(req *http.Request, source, global map[string]string) {
+	for k, v := range source {
+		req.Header.Set(k, v)
+	}
+	for k, v := range global {
+		req.Header.Set(k, v)
+	}
+}
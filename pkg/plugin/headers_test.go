@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+func TestHeaderPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		source map[string]string
+		global map[string]string
+		config models.DataSourceConfig
+		want   http.Header
+	}{
+		{
+			name:   "source only",
+			source: map[string]string{"X-Scope-OrgID": "tenant-a"},
+			want:   http.Header{"X-Scope-Orgid": {"tenant-a"}},
+		},
+		{
+			name:   "global overrides source",
+			source: map[string]string{"X-Scope-OrgID": "tenant-a", "X-Source": "s"},
+			global: map[string]string{"X-Scope-OrgID": "tenant-b", "X-Global": "g"},
+			want:   http.Header{"X-Scope-Orgid": {"tenant-b"}, "X-Source": {"s"}, "X-Global": {"g"}},
+		},
+		{
+			name:   "names compare case-insensitively",
+			source: map[string]string{"X-Scope-OrgID": "tenant-a"},
+			global: map[string]string{"x-scope-orgid": "tenant-b"},
+			want:   http.Header{"X-Scope-Orgid": {"tenant-b"}},
+		},
+		{
+			name:   "auth overrides both",
+			source: map[string]string{"Authorization": "Bearer source"},
+			global: map[string]string{"Authorization": "Bearer global"},
+			config: models.DataSourceConfig{AuthMode: models.AuthModeBearer, BearerToken: "secret"},
+			want:   http.Header{"Authorization": {"Bearer secret"}},
+		},
+		{
+			name:   "API key overrides both",
+			source: map[string]string{"X-API-Key": "source"},
+			global: map[string]string{"X-API-Key": "global"},
+			config: models.DataSourceConfig{AuthMode: models.AuthModeAPIKey, APIKey: "secret"},
+			want:   http.Header{"X-Api-Key": {"secret"}},
+		},
+		{
+			name:   "no auth keeps configured headers",
+			global: map[string]string{"Authorization": "Bearer global"},
+			config: models.DataSourceConfig{AuthMode: models.AuthModeNone},
+			want:   http.Header{"Authorization": {"Bearer global"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			setConfigHeaders(req, tt.source, tt.global)
+			setAuthHeaders(req, &tt.config)
+
+			if !reflect.DeepEqual(req.Header, tt.want) {
+				t.Errorf("headers = %v, want %v", req.Header, tt.want)
+			}
+		})
+	}
+}
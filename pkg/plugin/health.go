@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// healthProbe is the outcome of checking a single configured source
+type healthProbe struct {
+	Name string
+	Err  error
+}
+
+// healthCheck names a configured source and how to probe it
+type healthCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// probeBackends checks every configured source concurrently, so one slow
+// backend doesn't delay the others, and returns the probes in a stable order
+func (d *Datasource) probeBackends(ctx context.Context) []healthProbe {
+	var checks []healthCheck
+	if d.config.PrometheusURL != "" {
+		// Name the check that was used so a passing result is unambiguous
+		name := "Prometheus (/-/healthy)"
+		if d.config.PrometheusHealthQuery != "" {
+			name = fmt.Sprintf("Prometheus (query %s)", d.config.PrometheusHealthQuery)
+		}
+		checks = append(checks, healthCheck{name, d.checkPrometheusHealth})
+	}
+	if d.config.LokiURL != "" {
+		checks = append(checks, healthCheck{"Loki (status/buildinfo)", d.checkLokiHealth})
+	}
+	if d.config.RESTURL != "" {
+		name := "REST API (HEAD /)"
+		if d.config.RESTHealthEndpoint != "" {
+			name = fmt.Sprintf("REST API (GET %s)", d.config.RESTHealthEndpoint)
+		}
+		checks = append(checks, healthCheck{name, d.checkRESTHealth})
+	}
+
+	probes := make([]healthProbe, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+			probes[i] = healthProbe{Name: c.name, Err: c.check(ctx)}
+		}(i, c)
+	}
+	wg.Wait()
+
+	return probes
+}
+
+// healthSourceDetails is one entry of the per-source breakdown in JSONDetails
+type healthSourceDetails struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// aggregateHealth combines per-source probes into a single result according
+// to the health mode. The message and details always list every probe;
+// extra entries are merged into the details.
+func aggregateHealth(probes []healthProbe, mode models.HealthMode, extra map[string]interface{}) *backend.CheckHealthResult {
+	if len(probes) == 0 {
+		var details []byte
+		if len(extra) > 0 {
+			details, _ = json.Marshal(extra)
+		}
+		return &backend.CheckHealthResult{
+			Status:      backend.HealthStatusOk,
+			Message:     "Data source is ready",
+			JSONDetails: details,
+		}
+	}
+
+	healthy := 0
+	parts := make([]string, 0, len(probes))
+	sources := make([]healthSourceDetails, 0, len(probes))
+	for _, p := range probes {
+		if p.Err == nil {
+			healthy++
+			parts = append(parts, p.Name+": ok")
+			sources = append(sources, healthSourceDetails{Name: p.Name, Status: "ok"})
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: error (%v)", p.Name, p.Err))
+		sources = append(sources, healthSourceDetails{Name: p.Name, Status: "error", Message: p.Err.Error()})
+	}
+
+	if mode == "" {
+		mode = models.HealthModeAll
+	}
+
+	status := backend.HealthStatusOk
+	switch mode {
+	case models.HealthModeAny:
+		if healthy == 0 {
+			status = backend.HealthStatusError
+		}
+	default:
+		if healthy < len(probes) {
+			status = backend.HealthStatusError
+		}
+	}
+
+	detailsMap := map[string]interface{}{
+		"mode":    mode,
+		"sources": sources,
+	}
+	for k, v := range extra {
+		detailsMap[k] = v
+	}
+	details, _ := json.Marshal(detailsMap)
+
+	return &backend.CheckHealthResult{
+		Status:      status,
+		Message:     strings.Join(parts, ", "),
+		JSONDetails: details,
+	}
+}
@@ -0,0 +1,272 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestRESTCheckHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   string
+		expectBody string
+		status     int
+		body       string
+		wantMethod string
+		wantErr    string
+	}{
+		{name: "base URL ok", status: http.StatusOK, wantMethod: "HEAD"},
+		{name: "base URL not found is reachable", status: http.StatusNotFound, wantMethod: "HEAD"},
+		{name: "base URL without HEAD is reachable", status: http.StatusMethodNotAllowed, wantMethod: "HEAD"},
+		{name: "base URL server error", status: http.StatusInternalServerError, wantMethod: "HEAD", wantErr: "server error status 500"},
+		{name: "base URL unauthorized", status: http.StatusUnauthorized, wantMethod: "HEAD", wantErr: "check the authentication settings"},
+		{name: "endpoint ok", endpoint: "/health", status: http.StatusOK, wantMethod: "GET"},
+		{name: "endpoint not found", endpoint: "/health", status: http.StatusNotFound, wantMethod: "GET", wantErr: "expected a 2xx status, got 404"},
+		{name: "endpoint forbidden", endpoint: "/health", status: http.StatusForbidden, wantMethod: "GET", wantErr: "check the authentication settings"},
+		{name: "endpoint body matches", endpoint: "/health", expectBody: "UP", status: http.StatusOK, body: `{"status":"UP"}`, wantMethod: "GET"},
+		{name: "endpoint body differs", endpoint: "/health", expectBody: "UP", status: http.StatusOK, body: `{"status":"DOWN"}`, wantMethod: "GET", wantErr: `expected body to contain "UP"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path = r.Method, r.URL.Path
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":                srv.URL,
+				"restHealthEndpoint":     tt.endpoint,
+				"restHealthExpectedBody": tt.expectBody,
+			}, nil)
+			handler := &RESTAPIHandler{config: ds.config, logger: ds.logger, client: ds.client}
+
+			err := handler.checkHealth(context.Background())
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if method != tt.wantMethod {
+				t.Errorf("method = %s, want %s", method, tt.wantMethod)
+			}
+			if tt.endpoint != "" && path != tt.endpoint {
+				t.Errorf("path = %s, want %s", path, tt.endpoint)
+			}
+		})
+	}
+}
+
+// checkHealth runs the datasource health check
+func checkHealth(t *testing.T, ds *Datasource) *backend.CheckHealthResult {
+	t.Helper()
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("CheckHealth: %v", err)
+	}
+	return result
+}
+
+func TestLokiCheckHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		status     int
+		down       bool
+		wantStatus backend.HealthStatus
+		wantPath   string
+	}{
+		{name: "up", status: http.StatusOK, wantStatus: backend.HealthStatusOk, wantPath: "/loki/api/v1/status/buildinfo"},
+		{name: "prefixed gateway", prefix: "/gateway/loki/api/v1", status: http.StatusOK, wantStatus: backend.HealthStatusOk, wantPath: "/gateway/loki/api/v1/status/buildinfo"},
+		{name: "unavailable", status: http.StatusServiceUnavailable, wantStatus: backend.HealthStatusError, wantPath: "/loki/api/v1/status/buildinfo"},
+		{name: "down", down: true, wantStatus: backend.HealthStatusError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, `{"version":"3.0.0"}`)
+			}))
+			if tt.down {
+				srv.Close()
+			} else {
+				defer srv.Close()
+			}
+
+			ds := newTestDatasource(t, map[string]interface{}{"lokiUrl": srv.URL, "lokiApiPrefix": tt.prefix}, nil)
+			result := checkHealth(t, ds)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v: %s", result.Status, tt.wantStatus, result.Message)
+			}
+			if !strings.HasPrefix(result.Message, "Loki") {
+				t.Errorf("message = %q, want the Loki result", result.Message)
+			}
+			if path != tt.wantPath {
+				t.Errorf("path = %q, want %q", path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestAggregateHealth(t *testing.T) {
+	ok := healthProbe{Name: "Prometheus (/-/healthy)"}
+	failed := healthProbe{Name: "Loki (status/buildinfo)", Err: fmt.Errorf("connection refused")}
+	alsoFailed := healthProbe{Name: "REST API (HEAD /)", Err: fmt.Errorf("timeout")}
+
+	tests := []struct {
+		name        string
+		probes      []healthProbe
+		mode        models.HealthMode
+		wantStatus  backend.HealthStatus
+		wantMessage string
+		wantMode    models.HealthMode
+	}{
+		{name: "no probes", wantStatus: backend.HealthStatusOk, wantMessage: "Data source is ready"},
+		{name: "all healthy", probes: []healthProbe{ok, ok}, mode: models.HealthModeAll, wantStatus: backend.HealthStatusOk, wantMessage: "Prometheus (/-/healthy): ok, Prometheus (/-/healthy): ok", wantMode: models.HealthModeAll},
+		{name: "all with one failure", probes: []healthProbe{ok, failed}, mode: models.HealthModeAll, wantStatus: backend.HealthStatusError, wantMessage: "Prometheus (/-/healthy): ok, Loki (status/buildinfo): error (connection refused)", wantMode: models.HealthModeAll},
+		{name: "default mode is all", probes: []healthProbe{ok, failed}, wantStatus: backend.HealthStatusError, wantMessage: "Prometheus (/-/healthy): ok, Loki (status/buildinfo): error (connection refused)", wantMode: models.HealthModeAll},
+		{name: "any with one failure", probes: []healthProbe{failed, ok}, mode: models.HealthModeAny, wantStatus: backend.HealthStatusOk, wantMessage: "Loki (status/buildinfo): error (connection refused), Prometheus (/-/healthy): ok", wantMode: models.HealthModeAny},
+		{name: "any with every failure", probes: []healthProbe{failed, alsoFailed}, mode: models.HealthModeAny, wantStatus: backend.HealthStatusError, wantMessage: "Loki (status/buildinfo): error (connection refused), REST API (HEAD /): error (timeout)", wantMode: models.HealthModeAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := aggregateHealth(tt.probes, tt.mode, nil)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", result.Status, tt.wantStatus)
+			}
+			if result.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", result.Message, tt.wantMessage)
+			}
+			if len(tt.probes) == 0 {
+				return
+			}
+
+			var details struct {
+				Mode    models.HealthMode     `json:"mode"`
+				Sources []healthSourceDetails `json:"sources"`
+			}
+			if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+				t.Fatalf("decode details: %v", err)
+			}
+			if details.Mode != tt.wantMode {
+				t.Errorf("mode = %q, want %q", details.Mode, tt.wantMode)
+			}
+			if len(details.Sources) != len(tt.probes) {
+				t.Fatalf("got %d sources, want %d", len(details.Sources), len(tt.probes))
+			}
+			for i, p := range tt.probes {
+				wantStatus := "ok"
+				if p.Err != nil {
+					wantStatus = "error"
+				}
+				if got := details.Sources[i]; got.Name != p.Name || got.Status != wantStatus {
+					t.Errorf("source %d = %+v, want %s %s", i, got, p.Name, wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckHealthModeWithMixedSources(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	tests := []struct {
+		mode       string
+		wantStatus backend.HealthStatus
+	}{
+		{mode: "all", wantStatus: backend.HealthStatusError},
+		{mode: "any", wantStatus: backend.HealthStatusOk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":    up.URL,
+				"lokiUrl":    down.URL,
+				"healthMode": tt.mode,
+			}, nil)
+			result := checkHealth(t, ds)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v: %s", result.Status, tt.wantStatus, result.Message)
+			}
+			if !strings.Contains(result.Message, "Loki (status/buildinfo): error") || !strings.Contains(result.Message, "REST API (HEAD /): ok") {
+				t.Errorf("message = %q, want both sources", result.Message)
+			}
+		})
+	}
+}
+
+func TestPrometheusCheckHealth(t *testing.T) {
+	tests := []struct {
+		name        string
+		healthQuery string
+		token       string
+		queryStatus string
+		wantPath    string
+		wantQuery   string
+		wantStatus  backend.HealthStatus
+		wantMessage string
+	}{
+		{name: "health endpoint", token: "secret", wantPath: "/-/healthy", wantStatus: backend.HealthStatusOk, wantMessage: "Prometheus (/-/healthy): ok"},
+		{name: "sentinel query", healthQuery: "vector(1)", token: "secret", queryStatus: "success", wantPath: "/api/v1/query", wantQuery: "vector(1)", wantStatus: backend.HealthStatusOk, wantMessage: "Prometheus (query vector(1)): ok"},
+		{name: "sentinel query rejected", healthQuery: "vector(1)", token: "wrong", wantPath: "/api/v1/query", wantQuery: "vector(1)", wantStatus: backend.HealthStatusError, wantMessage: "health query was rejected with status 401, check the authentication settings"},
+		{name: "sentinel query failed", healthQuery: "vector(", token: "secret", queryStatus: "error", wantPath: "/api/v1/query", wantQuery: "vector(", wantStatus: backend.HealthStatusError, wantMessage: `health query returned status "error"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, query string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, query = r.URL.Path, r.URL.Query().Get("query")
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.URL.Path == "/api/v1/query" {
+					fmt.Fprintf(w, `{"status":%q,"data":{"resultType":"vector","result":[]}}`, tt.queryStatus)
+				}
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"prometheusUrl":         srv.URL,
+				"prometheusHealthQuery": tt.healthQuery,
+				"authMode":              "bearer",
+			}, map[string]string{"bearerToken": tt.token})
+			result := checkHealth(t, ds)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v: %s", result.Status, tt.wantStatus, result.Message)
+			}
+			if !strings.Contains(result.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want %q", result.Message, tt.wantMessage)
+			}
+			if path != tt.wantPath || query != tt.wantQuery {
+				t.Errorf("request = %s?query=%s, want %s?query=%s", path, query, tt.wantPath, tt.wantQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// newTestDatasource creates a datasource from JSON settings and optional
+// secure settings
+func newTestDatasource(t *testing.T, jsonData map[string]interface{}, secure map[string]string) *Datasource {
+	t.Helper()
+
+	raw, err := json.Marshal(jsonData)
+	if err != nil {
+		t.Fatalf("marshal settings: %v", err)
+	}
+	instance, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                raw,
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatalf("NewDatasource: %v", err)
+	}
+	ds := instance.(*Datasource)
+	t.Cleanup(ds.Dispose)
+	return ds
+}
+
+// testTimeRange is a fixed one hour range
+var testTimeRange = backend.TimeRange{
+	From: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	To:   time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+}
+
+// testQuery builds a data query from a query model
+func testQuery(t *testing.T, refID string, model map[string]interface{}) backend.DataQuery {
+	t.Helper()
+
+	raw, err := json.Marshal(model)
+	if err != nil {
+		t.Fatalf("marshal query: %v", err)
+	}
+	return backend.DataQuery{
+		RefID:         refID,
+		JSON:          raw,
+		TimeRange:     testTimeRange,
+		Interval:      time.Minute,
+		MaxDataPoints: 100,
+	}
+}
+
+// runQueries runs queries through QueryData
+func runQueries(t *testing.T, ds *Datasource, queries ...backend.DataQuery) *backend.QueryDataResponse {
+	t.Helper()
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+	if err != nil {
+		t.Fatalf("QueryData: %v", err)
+	}
+	return resp
+}
+
+// runQuery runs a single query through QueryData
+func runQuery(t *testing.T, ds *Datasource, model map[string]interface{}) backend.DataResponse {
+	t.Helper()
+	return runQueries(t, ds, testQuery(t, "A", model)).Responses["A"]
+}
+
+// resourceRecorder collects the responses sent by a resource call
+type resourceRecorder struct {
+	responses []*backend.CallResourceResponse
+}
+
+// Send records a response
+func (r *resourceRecorder) Send(resp *backend.CallResourceResponse) error {
+	r.responses = append(r.responses, resp)
+	return nil
+}
+
+// callResource runs a resource call and returns the recorded responses
+func callResource(t *testing.T, ds *Datasource, req *backend.CallResourceRequest) *resourceRecorder {
+	t.Helper()
+
+	rec := &resourceRecorder{}
+	if err := ds.CallResource(context.Background(), req, rec); err != nil {
+		t.Fatalf("CallResource: %v", err)
+	}
+	if len(rec.responses) == 0 {
+		t.Fatal("CallResource sent no response")
+	}
+	return rec
+}
@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// expectedSignature computes the hex HMAC-SHA256 of a signing string
+func expectedSignature(secret, signingString string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignRequest(t *testing.T) {
+	now := time.Unix(1704103200, 0)
+	body := []byte(`{"query":"up"}`)
+
+	tests := []struct {
+		name            string
+		config          models.DataSourceConfig
+		wantHeader      string
+		wantTimestamp   string
+		wantSigningText string
+	}{
+		{
+			name:            "default template and headers",
+			config:          models.DataSourceConfig{HMACSecret: "secret"},
+			wantHeader:      "X-Signature",
+			wantTimestamp:   "X-Signature-Timestamp",
+			wantSigningText: "POST\n/api/v1/data\n1704103200\n" + string(body),
+		},
+		{
+			name: "custom template and headers",
+			config: models.DataSourceConfig{
+				HMACSecret:          "secret",
+				HMACHeader:          "X-Auth-Signature",
+				HMACTimestampHeader: "X-Auth-Date",
+				HMACTemplate:        "{timestamp}:{method}:{path}?{query}",
+			},
+			wantHeader:      "X-Auth-Signature",
+			wantTimestamp:   "X-Auth-Date",
+			wantSigningText: "1704103200:POST:/api/v1/data?region=eu%2Dwest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "http://example.com/api/v1/data?region=eu%2Dwest", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signRequest(req, body, &tt.config, now)
+
+			if got := req.Header.Get(tt.wantTimestamp); got != "1704103200" {
+				t.Errorf("%s = %q, want 1704103200", tt.wantTimestamp, got)
+			}
+			if got, want := req.Header.Get(tt.wantHeader), expectedSignature("secret", tt.wantSigningText); got != want {
+				t.Errorf("%s = %q, want %q", tt.wantHeader, got, want)
+			}
+			if tt.wantTimestamp != defaultHMACTimestampHeader && req.Header.Get(defaultHMACTimestampHeader) != "" {
+				t.Errorf("default timestamp header set alongside %s", tt.wantTimestamp)
+			}
+		})
+	}
+}
+
+func TestSignRequestWithoutSecret(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signRequest(req, nil, &models.DataSourceConfig{HMACTemplate: "{method}"}, time.Now())
+
+	if len(req.Header) != 0 {
+		t.Errorf("headers = %v, want none without a secret", req.Header)
+	}
+}
+
+func TestSignedRESTQuery(t *testing.T) {
+	var method, path, timestamp, signature, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.EscapedPath(), string(raw)
+		timestamp, signature = r.Header.Get("X-Auth-Date"), r.Header.Get("X-Signature")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"restUrl":             srv.URL,
+		"hmacTimestampHeader": "X-Auth-Date",
+	}, map[string]string{"hmacSecret": "secret"})
+	res := runQuery(t, ds, map[string]interface{}{
+		"queryType":    "rest",
+		"restEndpoint": "/data",
+		"restMethod":   "POST",
+		"restBody":     `{"query":"up"}`,
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	if timestamp == "" {
+		t.Fatal("missing X-Auth-Date header")
+	}
+	want := expectedSignature("secret", method+"\n"+path+"\n"+timestamp+"\n"+body)
+	if signature != want {
+		t.Errorf("signature = %q, want %q over %q", signature, want, body)
+	}
+}
+
+func TestSignedResourceProxy(t *testing.T) {
+	type received struct{ method, path, timestamp, signature, body string }
+	var got map[string]received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		got[r.URL.Path] = received{r.Method, r.URL.EscapedPath(), r.Header.Get("X-Signature-Timestamp"), r.Header.Get("X-Signature"), string(raw)}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"restUrl":       srv.URL,
+		"prometheusUrl": srv.URL + "/prom/",
+	}, map[string]string{"hmacSecret": "secret"})
+	got = map[string]received{}
+
+	callResource(t, ds, &backend.CallResourceRequest{Path: "rest", URL: "rest", Method: "POST", Body: []byte(`{"q":1}`)})
+	callResource(t, ds, &backend.CallResourceRequest{Path: "prometheus", URL: "prometheus", Method: "GET"})
+
+	rest := got["/rest"]
+	if rest.timestamp == "" {
+		t.Fatal("REST resource call was not signed")
+	}
+	if want := expectedSignature("secret", "POST\n/rest\n"+rest.timestamp+"\n"+`{"q":1}`); rest.signature != want {
+		t.Errorf("signature = %q, want %q", rest.signature, want)
+	}
+
+	// Only REST requests are signed
+	if prom, ok := got["/prom/prometheus"]; !ok || prom.signature != "" || prom.timestamp != "" {
+		t.Errorf("Prometheus resource call = %+v, want it unsigned", prom)
+	}
+}
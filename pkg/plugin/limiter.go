@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// defaultMaxConcurrentRequests bounds outgoing backend requests when unset
+const defaultMaxConcurrentRequests = 8
+
+// requestLimiter bounds the number of concurrent backend requests
+type requestLimiter chan struct{}
+
+// newRequestLimiter creates a limiter allowing n concurrent requests
+func newRequestLimiter(n int) requestLimiter {
+	if n <= 0 {
+		n = defaultMaxConcurrentRequests
+	}
+	return make(requestLimiter, n)
+}
+
+// acquire blocks until a slot is free or the context is done
+func (l requestLimiter) acquire(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l requestLimiter) release() {
+	<-l
+}
+
+// limitedTransport holds a limiter slot for every backend request, from
+// sending it until its response body is closed, so all queries, resources,
+// proxied calls and health probes share MaxConcurrentRequests
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter requestLimiter
+}
+
+// RoundTrip waits for a free slot and sends the request
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.limiter.release()
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: t.limiter.release}
+	return resp, nil
+}
+
+// releaseOnClose frees a limiter slot once its body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close closes the body and frees the slot
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
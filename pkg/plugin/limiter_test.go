@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyServer records the highest number of requests in flight
+type concurrencyServer struct {
+	inFlight int32
+	peak     int32
+
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *concurrencyServer) handler(body func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&s.inFlight, 1)
+		defer atomic.AddInt32(&s.inFlight, -1)
+		for {
+			peak := atomic.LoadInt32(&s.peak)
+			if n <= peak || atomic.CompareAndSwapInt32(&s.peak, peak, n) {
+				break
+			}
+		}
+
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body(r))
+	})
+}
+
+func TestFanOutToThreeEndpoints(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		wantPeak      int32
+	}{
+		{name: "serialized by the limiter", maxConcurrent: 1, wantPeak: 1},
+		{name: "concurrent within the limit", maxConcurrent: 8, wantPeak: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &concurrencyServer{}
+			srv := httptest.NewServer(cs.handler(func(r *http.Request) string {
+				return fmt.Sprintf(`[{"value": %d}]`, len(r.URL.Path))
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":               srv.URL,
+				"maxConcurrentRequests": tt.maxConcurrent,
+			}, nil)
+			res := runQuery(t, ds, map[string]interface{}{
+				"queryType":    "rest",
+				"restEndpoint": "/hosts/{{target}}/metrics",
+				"fanOut":       []string{"a", "bb", "ccc"},
+			})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if len(res.Frames) != 3 {
+				t.Fatalf("got %d frames, want 3", len(res.Frames))
+			}
+			var targets []string
+			for _, frame := range res.Frames {
+				targets = append(targets, frame.Name)
+				for _, field := range frame.Fields {
+					if field.Type().Time() {
+						continue
+					}
+					if field.Labels["target"] != frame.Name {
+						t.Errorf("field %s of frame %s has target label %q", field.Name, frame.Name, field.Labels["target"])
+					}
+				}
+			}
+			if strings.Join(targets, ",") != "a,bb,ccc" {
+				t.Errorf("frames = %v, want targets in fan-out order", targets)
+			}
+
+			sort.Strings(cs.paths)
+			if want := "/hosts/a/metrics,/hosts/bb/metrics,/hosts/ccc/metrics"; strings.Join(cs.paths, ",") != want {
+				t.Errorf("requested %v", cs.paths)
+			}
+			if peak := atomic.LoadInt32(&cs.peak); peak != tt.wantPeak {
+				t.Errorf("peak concurrency = %d, want %d", peak, tt.wantPeak)
+			}
+		})
+	}
+}
+
+func TestRequestLimiterCoversAllQueryTypes(t *testing.T) {
+	cs := &concurrencyServer{}
+	srv := httptest.NewServer(cs.handler(func(r *http.Request) string {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/"):
+			return `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		case strings.HasPrefix(r.URL.Path, "/loki/"):
+			return `{"status":"success","data":{"resultType":"streams","result":[]}}`
+		}
+		return `[{"value": 1}]`
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"prometheusUrl":         srv.URL,
+		"lokiUrl":               srv.URL,
+		"restUrl":               srv.URL,
+		"maxConcurrentRequests": 1,
+	}, nil)
+
+	resp := runQueries(t, ds,
+		testQuery(t, "A", map[string]interface{}{"queryType": "prometheus", "promQL": "up", "queryKind": "instant"}),
+		testQuery(t, "B", map[string]interface{}{"queryType": "loki", "logQL": `{job="a"}`}),
+		testQuery(t, "C", map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}),
+	)
+	for refID, res := range resp.Responses {
+		if res.Error != nil {
+			t.Errorf("query %s failed: %v", refID, res.Error)
+		}
+	}
+	if peak := atomic.LoadInt32(&cs.peak); peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", peak)
+	}
+}
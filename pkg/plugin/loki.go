@@ -1,14 +1,16 @@
 package plugin
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"hash/fnv"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sameersah/GrafanaConnect/pkg/models"
@@ -17,17 +19,42 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
+const (
+	// defaultLokiAPIPrefix is the path the Loki HTTP API is mounted under
+	defaultLokiAPIPrefix = "/loki/api/v1"
+
+	// defaultLokiMinStep is the smallest step sent for Loki metric queries
+	defaultLokiMinStep = time.Second
+
+	// lokiMaxPoints mirrors Loki's server-side limit of points per series
+	lokiMaxPoints = 11000
+
+	// defaultLokiMaxLines is the log line limit when neither the query nor
+	// the configuration sets one
+	defaultLokiMaxLines = 1000
+
+	// lokiLineLimitCap bounds the requested line limit to protect Loki; it
+	// matches Loki's default max_entries_limit_per_query
+	lokiLineLimitCap = 5000
+)
+
 // LokiHandler handles Loki log queries
 type LokiHandler struct {
-	config *models.DataSourceConfig
-	logger log.Logger
+	config             *models.DataSourceConfig
+	logger             log.Logger
+	client             *http.Client
+	derivedFields      []derivedField
+	derivedFieldErrors []string
 }
 
 // handleLokiQuery processes Loki queries
 func (d *Datasource) handleLokiQuery(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
 	handler := &LokiHandler{
-		config: d.config,
-		logger: d.logger,
+		config:             d.config,
+		logger:             d.logger,
+		client:             d.client,
+		derivedFields:      d.derivedFields,
+		derivedFieldErrors: d.derivedFieldErrors,
 	}
 
 	if d.config.LokiURL == "" {
@@ -42,20 +69,102 @@ func (d *Datasource) handleLokiQuery(ctx context.Context, query backend.DataQuer
 		}
 	}
 
-	return handler.executeQuery(ctx, query, queryModel)
+	queryModel.LogQL = interpolateQueryVariables(queryModel.LogQL, queryModel.Variables)
+
+	res := handler.executeQuery(ctx, query, queryModel)
+	roundFrameValues(res.Frames, queryModel.RoundDecimals)
+	if queryModel.MetricsLinkTemplate != "" && res.Error == nil {
+		d.attachMetricsLinks(res.Frames, queryModel.MetricsLinkTemplate)
+	}
+
+	return res
+}
+
+// attachMetricsLinks adds a data link on each log frame's body field that
+// opens a Prometheus query in this datasource, with ${label} placeholders in
+// the template filled from the stream's labels
+func (d *Datasource) attachMetricsLinks(frames data.Frames, template string) {
+	for _, frame := range frames {
+		if frame.Meta == nil || frame.Meta.Type != data.FrameTypeLogLines {
+			continue
+		}
+		custom, ok := frame.Meta.Custom.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		labels, ok := custom["labels"].(map[string]string)
+		if !ok {
+			continue
+		}
+		for _, field := range frame.Fields {
+			if field.Name != "body" {
+				continue
+			}
+
+			promQL := interpolateQueryVariables(template, labels)
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			field.Config.Links = append(field.Config.Links, data.DataLink{
+				Title: "View metrics",
+				Internal: &data.InternalDataLink{
+					Query: map[string]interface{}{
+						"queryType": models.QueryTypePrometheus,
+						"promQL":    promQL,
+					},
+					DatasourceUID:  d.settings.UID,
+					DatasourceName: d.settings.Name,
+				},
+			})
+		}
+	}
 }
 
 // executeQuery executes a Loki query
 func (h *LokiHandler) executeQuery(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
+	// Loki only evaluates metric queries at a single instant
+	instant := queryModel.Kind() == models.QueryKindInstant
+	if instant && !isLokiMetricQuery(queryModel.LogQL) {
+		return backend.DataResponse{
+			Error: fmt.Errorf("instant Loki queries must be metric queries, use a range query for log lines"),
+		}
+	}
+
 	// Build query URL
-	queryURL := fmt.Sprintf("%s/loki/api/v1/query_range", h.config.LokiURL)
+	endpoint := "query_range"
+	if instant {
+		endpoint = "query"
+	}
+	queryURL, err := lokiAPIURL(h.config, endpoint)
+	if err != nil {
+		return backend.DataResponse{
+			Error: err,
+		}
+	}
 
 	// Build query parameters
 	params := url.Values{}
 	params.Set("query", queryModel.LogQL)
-	params.Set("start", strconv.FormatInt(query.TimeRange.From.UnixNano(), 10))
-	params.Set("end", strconv.FormatInt(query.TimeRange.To.UnixNano(), 10))
-	params.Set("limit", "1000") // Default limit
+	if instant {
+		params.Set("time", strconv.FormatInt(query.TimeRange.To.UnixNano(), 10))
+	} else {
+		params.Set("start", strconv.FormatInt(query.TimeRange.From.UnixNano(), 10))
+		params.Set("end", strconv.FormatInt(query.TimeRange.To.UnixNano(), 10))
+	}
+	limit := lokiLineLimit(h.config, queryModel)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", string(lokiDirection(queryModel)))
+
+	// Metric queries are evaluated at a step, which Loki rejects when too dense
+	if !instant && isLokiMetricQuery(queryModel.LogQL) {
+		step, err := h.calculateStep(query)
+		if err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
+		}
+		params.Set("step", strconv.FormatInt(int64(step.Seconds()), 10)+"s")
+	}
 
 	// Make HTTP request
 	req, err := http.NewRequestWithContext(ctx, "GET", queryURL+"?"+params.Encode(), nil)
@@ -65,29 +174,36 @@ func (h *LokiHandler) executeQuery(ctx context.Context, query backend.DataQuery,
 		}
 	}
 
-	// Add authentication
+	// Add configured headers and authentication
+	setConfigHeaders(req, h.config.LokiHeaders, h.config.Headers)
 	h.addAuthHeaders(req)
+	negotiateCompression(req, queryModel)
 
 	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(ctx, h.client, req, newRetryPolicy(h.config), h.logger)
 	if err != nil {
-		return backend.DataResponse{
-			Error: fmt.Errorf("failed to execute request: %w", err),
-		}
+		return requestErrorResponse(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		body, _ := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return h.clientErrorResponse(resp.StatusCode, body)
+		}
+		return statusErrorResponse(resp.StatusCode, fmt.Errorf("Loki API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	body, err := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+	if err != nil {
 		return backend.DataResponse{
-			Error: fmt.Errorf("Loki API returned status %d: %s", resp.StatusCode, string(body)),
+			Error: err,
 		}
 	}
 
 	// Parse response
 	var lokiResp models.LokiQueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lokiResp); err != nil {
+	if err := json.Unmarshal(body, &lokiResp); err != nil {
 		return backend.DataResponse{
 			Error: fmt.Errorf("failed to parse response: %w", err),
 		}
@@ -100,21 +216,171 @@ func (h *LokiHandler) executeQuery(ctx context.Context, query backend.DataQuery,
 	}
 
 	// Convert to Grafana data frames
-	frames, err := h.convertToDataFrames(&lokiResp)
+	frames, err := h.convertToDataFrames(&lokiResp, queryModel)
 	if err != nil {
 		return backend.DataResponse{
 			Error: fmt.Errorf("failed to convert response: %w", err),
 		}
 	}
 
+	setFrameMetaCustom(frames, "limit", limit)
+	if lines := lokiLineCount(&lokiResp); lines >= limit {
+		addNotice(frames, data.NoticeSeverityWarning,
+			fmt.Sprintf("Loki returned %d lines, the limit of this query; results may be truncated", lines))
+	}
+
 	return backend.DataResponse{
 		Frames: frames,
 	}
 }
 
+// lokiLineLimit returns the line limit of a query: its own limit, else the
+// configured default, capped to protect the backend
+func lokiLineLimit(config *models.DataSourceConfig, queryModel *models.QueryModel) int {
+	limit := queryModel.Limit
+	if limit <= 0 {
+		limit = config.LokiMaxLines
+	}
+	if limit <= 0 {
+		limit = defaultLokiMaxLines
+	}
+	if limit > lokiLineLimitCap {
+		limit = lokiLineLimitCap
+	}
+	return limit
+}
+
+// lokiDirection returns the query's log direction, newest first by default
+func lokiDirection(queryModel *models.QueryModel) models.Direction {
+	if queryModel.Direction == "" {
+		return models.DirectionBackward
+	}
+	return queryModel.Direction
+}
+
+// sortLogLines orders log lines by time, ascending for forward and
+// descending for backward, keeping lines with equal timestamps in order
+func sortLogLines(times []time.Time, values []string, direction models.Direction) {
+	idx := make([]int, len(times))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		if direction == models.DirectionForward {
+			return times[idx[a]].Before(times[idx[b]])
+		}
+		return times[idx[a]].After(times[idx[b]])
+	})
+
+	sortedTimes := make([]time.Time, len(times))
+	sortedValues := make([]string, len(values))
+	for i, j := range idx {
+		sortedTimes[i], sortedValues[i] = times[j], values[j]
+	}
+	copy(times, sortedTimes)
+	copy(values, sortedValues)
+}
+
+// lokiLineCount returns the number of log lines across all streams
+func lokiLineCount(resp *models.LokiQueryResponse) int {
+	if resp.Data.ResultType != "streams" {
+		return 0
+	}
+
+	lines := 0
+	for _, result := range resp.Data.Result {
+		lines += len(result.Values)
+	}
+	return lines
+}
+
+// lokiAPIURL joins the Loki base URL, the configured API prefix and the
+// given path elements
+func lokiAPIURL(config *models.DataSourceConfig, elem ...string) (string, error) {
+	prefix := config.LokiAPIPrefix
+	if prefix == "" {
+		prefix = defaultLokiAPIPrefix
+	}
+
+	joined, err := url.JoinPath(config.LokiURL, append([]string{prefix}, elem...)...)
+	if err != nil {
+		return "", fmt.Errorf("invalid Loki URL: %w", err)
+	}
+	return joined, nil
+}
+
+// isLokiMetricQuery reports whether a LogQL query is a metric query rather
+// than a plain log stream selector
+func isLokiMetricQuery(logQL string) bool {
+	return !strings.HasPrefix(strings.TrimSpace(logQL), "{")
+}
+
+// calculateStep derives the step for a metric query from the panel interval,
+// floored at the configured minimum and checked against Loki's point limit
+func (h *LokiHandler) calculateStep(query backend.DataQuery) (time.Duration, error) {
+	minStep := defaultLokiMinStep
+	if h.config.LokiMinStepSeconds > 0 {
+		minStep = time.Duration(h.config.LokiMinStepSeconds) * time.Second
+	}
+
+	step := query.Interval.Truncate(time.Second)
+	if step < minStep {
+		step = minStep
+	}
+
+	rangeDuration := query.TimeRange.To.Sub(query.TimeRange.From)
+	if points := int64(rangeDuration / step); points > lokiMaxPoints {
+		return 0, fmt.Errorf("query would return %d points per series, exceeding Loki's limit of %d; increase the step or shorten the time range", points, lokiMaxPoints)
+	}
+
+	return step, nil
+}
+
+// parseLokiError extracts the message from a Loki error body, which is either
+// plain text or a JSON envelope depending on the Loki version and endpoint
+func parseLokiError(body []byte) string {
+	var envelope struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		if envelope.Error != "" {
+			return envelope.Error
+		}
+		if envelope.Message != "" {
+			return envelope.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// clientErrorResponse turns a Loki 4xx response into a clean, classified error
+func (h *LokiHandler) clientErrorResponse(status int, body []byte) backend.DataResponse {
+	msg := parseLokiError(body)
+	lower := strings.ToLower(msg)
+
+	var err error
+	switch {
+	case strings.Contains(lower, "parse error") || strings.Contains(lower, "syntax error"):
+		err = fmt.Errorf("invalid LogQL query: %s", msg)
+	case strings.Contains(lower, "limit") || strings.Contains(lower, "query length") || strings.Contains(lower, "too many"):
+		err = fmt.Errorf("Loki query limit exceeded: %s (try reducing the time range or the line limit)", msg)
+	default:
+		err = fmt.Errorf("Loki API returned status %d: %s", status, msg)
+	}
+
+	return statusErrorResponse(status, err)
+}
+
 // convertToDataFrames converts Loki response to Grafana data frames
-func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse) (data.Frames, error) {
+func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse, queryModel *models.QueryModel) (data.Frames, error) {
+	// Metric queries return numeric samples rather than log streams
+	if resp.Data.ResultType == "matrix" || resp.Data.ResultType == "vector" {
+		return h.convertMetricFrames(resp)
+	}
+
 	var frames data.Frames
+	var count int64
 
 	for _, result := range resp.Data.Result {
 		// Extract labels
@@ -130,7 +396,8 @@ func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse) (data.
 			}
 
 			// Parse timestamp (nanoseconds)
-			tsNano, err := strconv.ParseInt(val[0], 10, 64)
+			tsStr, _ := val[0].(string)
+			tsNano, err := strconv.ParseInt(tsStr, 10, 64)
 			if err != nil {
 				h.logger.Warn("Failed to parse timestamp", "error", err, "value", val[0])
 				continue
@@ -138,7 +405,10 @@ func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse) (data.
 			timestamp := time.Unix(0, tsNano)
 
 			// Log line
-			logLine := val[1]
+			logLine, ok := val[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid log line format")
+			}
 
 			times = append(times, timestamp)
 			values = append(values, logLine)
@@ -148,18 +418,20 @@ func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse) (data.
 			continue
 		}
 
-		// Create data frame
-		timeField := data.NewField("time", nil, times)
-		valueField := data.NewField("value", labels, values)
+		// Order each stream consistently with the requested direction
+		sortLogLines(times, values, lokiDirection(queryModel))
 
-		// Set field config
-		valueField.Config = &data.FieldConfig{
-			DisplayNameFromDS: h.buildSeriesName(labels),
+		// Create a logs frame in the shape the Logs panel expects
+		frame := newLogsFrame(h.buildSeriesName(labels), labels, times, values)
+
+		frame.Fields = append(frame.Fields, derivedDataFields(h.derivedFields, values)...)
+
+		if queryModel.DetectLevel {
+			frame.Fields = append(frame.Fields, levelField(labels, values))
 		}
 
-		frame := data.NewFrame("", timeField, valueField)
-		frame.Meta = &data.FrameMeta{
-			Type: data.FrameTypeLogLines,
+		if queryModel.EmitLabelsField {
+			frame.Fields = append(frame.Fields, labelsJSONField(labels, len(times)))
 		}
 
 		// Add labels as frame metadata
@@ -168,13 +440,123 @@ func (h *LokiHandler) convertToDataFrames(resp *models.LokiQueryResponse) (data.
 		}
 
 		frames = append(frames, frame)
+		count += int64(len(times))
+	}
+
+	if len(frames) > 0 && len(h.derivedFieldErrors) > 0 {
+		addNotice(frames[:1], data.NoticeSeverityWarning,
+			"Derived fields skipped: "+strings.Join(h.derivedFieldErrors, "; "))
+	}
+
+	// Total matched lines for stat panels
+	if queryModel.WithCount {
+		countFrame := data.NewFrame("count", data.NewField("count", nil, []int64{count}))
+		frames = append(frames, countFrame)
 	}
 
 	return frames, nil
 }
 
+// convertMetricFrames converts the matrix or vector result of a LogQL metric
+// query into numeric time series frames
+func (h *LokiHandler) convertMetricFrames(resp *models.LokiQueryResponse) (data.Frames, error) {
+	var frames data.Frames
+
+	for _, result := range resp.Data.Result {
+		samples := result.Values
+		if resp.Data.ResultType == "vector" {
+			samples = [][]interface{}{result.Value}
+		}
+
+		times := make([]time.Time, 0, len(samples))
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			if len(sample) < 2 {
+				return nil, fmt.Errorf("invalid metric sample")
+			}
+
+			// Sample timestamps are seconds with millisecond precision
+			ts, ok := sample[0].(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid timestamp format")
+			}
+			v, err := parseSampleValue(sample[1])
+			if err != nil {
+				return nil, err
+			}
+
+			times = append(times, time.UnixMilli(int64(math.Round(ts*1000))))
+			values = append(values, v)
+		}
+
+		valueField := data.NewField("value", result.Metric, values)
+		valueField.Config = &data.FieldConfig{
+			DisplayNameFromDS: h.buildSeriesName(result.Metric),
+		}
+
+		frame := data.NewFrame("", data.NewField("time", nil, times), valueField)
+		frame.Meta = &data.FrameMeta{
+			Type: data.FrameTypeTimeSeriesMany,
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames, nil
+}
+
+// newLogsFrame builds a log lines frame with timestamp, body, labels and id
+// fields. labels holds each line's stream labels as JSON, and id is unique
+// per line so the Logs panel can de-duplicate and show log context.
+func newLogsFrame(name string, labels map[string]string, times []time.Time, lines []string) *data.Frame {
+	encodedLabels, _ := json.Marshal(labels)
+
+	labelValues := make([]json.RawMessage, len(lines))
+	ids := make([]string, len(lines))
+	seen := make(map[string]int, len(lines))
+	for i, line := range lines {
+		labelValues[i] = encodedLabels
+
+		hash := fnv.New64a()
+		hash.Write(encodedLabels)
+		hash.Write([]byte(line))
+		id := fmt.Sprintf("%d_%x", times[i].UnixNano(), hash.Sum64())
+		if n := seen[id]; n > 0 {
+			seen[id] = n + 1
+			id = fmt.Sprintf("%s_%d", id, n)
+		} else {
+			seen[id] = 1
+		}
+		ids[i] = id
+	}
+
+	frame := data.NewFrame(name,
+		data.NewField("timestamp", nil, times),
+		data.NewField("body", nil, lines),
+		data.NewField("labels", nil, labelValues),
+		data.NewField("id", nil, ids),
+	)
+	frame.Meta = &data.FrameMeta{
+		Type: data.FrameTypeLogLines,
+	}
+	return frame
+}
+
+// labelsJSONField builds a "labels_json" string field repeating the stream
+// labels, JSON encoded with sorted keys, on every row
+func labelsJSONField(labels map[string]string, rows int) *data.Field {
+	encoded, _ := json.Marshal(labels)
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = string(encoded)
+	}
+	return data.NewField("labels_json", nil, values)
+}
+
 // buildSeriesName creates a series name from log labels
 func (h *LokiHandler) buildSeriesName(labels map[string]string) string {
+	if name, ok := labels[h.config.SeriesNameLabel]; ok && h.config.SeriesNameLabel != "" {
+		return name
+	}
 	if job, ok := labels["job"]; ok {
 		return job
 	}
@@ -192,77 +574,51 @@ func (h *LokiHandler) buildSeriesName(labels map[string]string) string {
 
 // addAuthHeaders adds authentication headers to the request
 func (h *LokiHandler) addAuthHeaders(req *http.Request) {
-	if h.config.BearerToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.config.BearerToken)
-	} else if h.config.APIKey != "" {
-		req.Header.Set("X-API-Key", h.config.APIKey)
-	} else if h.config.BasicAuthUser != "" && h.config.BasicAuthPass != "" {
-		req.SetBasicAuth(h.config.BasicAuthUser, h.config.BasicAuthPass)
-	}
+	setAuthHeaders(req, h.config)
 }
 
-// handleLokiResource handles resource calls for Loki
-func (d *Datasource) handleLokiResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	// Proxy the request to Loki
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	// Build URL
-	targetURL := d.config.LokiURL + req.Path
-	if len(req.URL) > 0 && req.URL != req.Path {
-		// Parse URL to extract query string if present
-		if parsedURL, err := url.Parse(req.URL); err == nil && parsedURL.RawQuery != "" {
-			targetURL += "?" + parsedURL.RawQuery
-		}
-	}
-
-	var bodyReader io.Reader
-	if len(req.Body) > 0 {
-		bodyReader = bytes.NewReader(req.Body)
-	}
+// checkHealth verifies Loki connectivity via its buildinfo endpoint under
+// the configured API prefix, so gateways that only expose the API pass
+func (h *LokiHandler) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout(h.config))
+	defer cancel()
 
-	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, targetURL, bodyReader)
+	buildInfoURL, err := lokiAPIURL(h.config, "status", "buildinfo")
 	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to create request: %v"}`, err)),
-		})
+		return err
 	}
-
-	// Copy headers
-	for k, v := range req.Headers {
-		proxyReq.Header[k] = v
+	req, err := http.NewRequestWithContext(ctx, "GET", buildInfoURL, nil)
+	if err != nil {
+		return err
 	}
 
-	// Add auth
-	if d.config.BearerToken != "" {
-		proxyReq.Header.Set("Authorization", "Bearer "+d.config.BearerToken)
-	} else if d.config.APIKey != "" {
-		proxyReq.Header.Set("X-API-Key", d.config.APIKey)
-	} else if d.config.BasicAuthUser != "" && d.config.BasicAuthPass != "" {
-		proxyReq.SetBasicAuth(d.config.BasicAuthUser, d.config.BasicAuthPass)
-	}
+	setConfigHeaders(req, h.config.LokiHeaders, h.config.Headers)
+	h.addAuthHeaders(req)
 
-	resp, err := client.Do(proxyReq)
+	resp, err := h.client.Do(req)
 	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Request failed: %v"}`, err)),
-		})
+		return err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// handleLokiResource handles resource calls for Loki
+func (d *Datasource) handleLokiResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	// Build URL under the configured API prefix
+	targetURL, err := lokiAPIURL(d.config, strings.TrimPrefix(req.Path, "loki"))
 	if err != nil {
 		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to read response: %v"}`, err)),
+			Status: 400,
+			Body:   []byte(fmt.Sprintf(`{"error": "%v"}`, err)),
 		})
 	}
 
-	return sender.Send(&backend.CallResourceResponse{
-		Status: resp.StatusCode,
-		Headers: resp.Header,
-		Body:   body,
-	})
+	// Proxy the request to Loki
+	return d.proxyResource(ctx, proxyTarget{URL: targetURL, Headers: d.config.LokiHeaders}, req, sender)
 }
-
@@ -0,0 +1,707 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestLokiQueryKindEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		logQL      string
+		queryKind  string
+		emptyRange bool
+		wantPath   string
+	}{
+		{name: "log query over an empty range", logQL: `{job="api"}`, emptyRange: true, wantPath: "/loki/api/v1/query_range"},
+		{name: "log query", logQL: `{job="api"}`, wantPath: "/loki/api/v1/query_range"},
+		{name: "explicit instant metric query", logQL: `count_over_time({job="api"}[5m])`, queryKind: "instant", wantPath: "/loki/api/v1/query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu   sync.Mutex
+				path string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				path = r.URL.Path
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/loki/api/v1/query" {
+					fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+					return
+				}
+				fmt.Fprint(w, lokiStreamsResponse)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"lokiUrl": srv.URL}, nil)
+			model := map[string]interface{}{"queryType": "loki", "logQL": tt.logQL}
+			if tt.queryKind != "" {
+				model["queryKind"] = tt.queryKind
+			}
+			query := testQuery(t, "A", model)
+			if tt.emptyRange {
+				query.TimeRange.From = query.TimeRange.To
+			}
+
+			res := runQueries(t, ds, query).Responses["A"]
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if path != tt.wantPath {
+				t.Errorf("path = %s, want %s", path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestAttachMetricsLinks(t *testing.T) {
+	logFrame := func(custom interface{}) *data.Frame {
+		frame := data.NewFrame("", data.NewField("body", nil, []string{"line"}))
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeLogLines, Custom: custom}
+		return frame
+	}
+
+	tests := []struct {
+		name     string
+		frame    *data.Frame
+		wantLink string
+	}{
+		{
+			name:     "stream labels",
+			frame:    logFrame(map[string]interface{}{"labels": map[string]string{"job": "api"}}),
+			wantLink: `rate(http_requests_total{job="api"}[5m])`,
+		},
+		{name: "no custom metadata", frame: logFrame(nil)},
+		{name: "unexpected custom metadata", frame: logFrame("labels")},
+		{name: "unexpected labels", frame: logFrame(map[string]interface{}{"labels": "job=api"})},
+		{name: "not a log frame", frame: data.NewFrame("", data.NewField("body", nil, []string{"line"}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDatasource(t, map[string]interface{}{}, nil)
+			ds.attachMetricsLinks(data.Frames{tt.frame}, `rate(http_requests_total{job="${job}"}[5m])`)
+
+			body := tt.frame.Fields[0]
+			if tt.wantLink == "" {
+				if body.Config != nil && len(body.Config.Links) > 0 {
+					t.Errorf("unexpected links %v", body.Config.Links)
+				}
+				return
+			}
+			if body.Config == nil || len(body.Config.Links) != 1 {
+				t.Fatalf("want one link on the body field")
+			}
+			if got := body.Config.Links[0].Internal.Query.(map[string]interface{})["promQL"]; got != tt.wantLink {
+				t.Errorf("promQL = %v, want %s", got, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestLokiAPIPrefix(t *testing.T) {
+	const prefix = "/gateway/loki/api/v1"
+
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case prefix + "/query_range":
+			fmt.Fprint(w, lokiStreamsResponse)
+		case prefix + "/query":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		case prefix + "/labels":
+			fmt.Fprint(w, `{"status":"success","data":["job"]}`)
+		case prefix + "/status/buildinfo":
+			fmt.Fprint(w, `{"version":"3.0.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"lokiUrl": srv.URL, "lokiApiPrefix": prefix}, nil)
+
+	if res := runQuery(t, ds, map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`}); res.Error != nil {
+		t.Errorf("range query failed: %v", res.Error)
+	}
+	if res := runQuery(t, ds, map[string]interface{}{"queryType": "loki", "logQL": `count_over_time({job="api"}[5m])`, "queryKind": "instant"}); res.Error != nil {
+		t.Errorf("instant query failed: %v", res.Error)
+	}
+	rec := callResource(t, ds, &backend.CallResourceRequest{Path: "loki/labels", Method: "GET"})
+	if resp := rec.responses[0]; resp.Status != http.StatusOK || string(resp.Body) != `{"values":["job"]}` {
+		t.Errorf("labels = %d %s, want 200 with the label names", resp.Status, resp.Body)
+	}
+	if result := checkHealth(t, ds); result.Status != backend.HealthStatusOk {
+		t.Errorf("health = %v: %s", result.Status, result.Message)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{prefix + "/query_range", prefix + "/query", prefix + "/labels", prefix + "/status/buildinfo"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+// lokiMatrixResponse is an empty metric query result
+const lokiMatrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+
+// serveLoki runs a query against a Loki server returning body and reports the
+// query parameters the server received
+func serveLoki(t *testing.T, settings map[string]interface{}, body string, query backend.DataQuery) (url.Values, backend.DataResponse) {
+	t.Helper()
+
+	var (
+		mu     sync.Mutex
+		params url.Values
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		params = r.URL.Query()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	jsonData := map[string]interface{}{"lokiUrl": srv.URL}
+	for k, v := range settings {
+		jsonData[k] = v
+	}
+	ds := newTestDatasource(t, jsonData, nil)
+	res := runQueries(t, ds, query).Responses[query.RefID]
+
+	mu.Lock()
+	defer mu.Unlock()
+	return params, res
+}
+
+func TestLokiStepFloor(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		interval time.Duration
+		span     time.Duration
+		wantStep string
+		wantErr  string
+	}{
+		{name: "panel interval", interval: time.Minute, wantStep: "60s"},
+		{name: "default floor", interval: 200 * time.Millisecond, wantStep: "1s"},
+		{name: "configured floor", settings: map[string]interface{}{"lokiMinStepSeconds": 120}, interval: time.Minute, wantStep: "120s"},
+		{name: "interval above the floor", settings: map[string]interface{}{"lokiMinStepSeconds": 30}, interval: 5 * time.Minute, wantStep: "300s"},
+		{name: "too many points", interval: time.Second, span: 4 * time.Hour, wantErr: "exceeding Loki's limit of 11000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := testQuery(t, "A", map[string]interface{}{"queryType": "loki", "logQL": `rate({job="api"}[5m])`})
+			query.Interval = tt.interval
+			if tt.span > 0 {
+				query.TimeRange.From = query.TimeRange.To.Add(-tt.span)
+			}
+
+			params, res := serveLoki(t, tt.settings, lokiMatrixResponse, query)
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if got := params.Get("step"); got != tt.wantStep {
+				t.Errorf("step = %q, want %q", got, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestLokiClientErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "max entries limit as text",
+			body:    "max entries limit per query exceeded, limit > max_entries_limit (5000 > 1000)\n",
+			wantErr: "Loki query limit exceeded: max entries limit per query exceeded, limit > max_entries_limit (5000 > 1000) (try reducing the time range or the line limit)",
+		},
+		{
+			name:    "query length limit as JSON",
+			body:    `{"status":"error","errorType":"bad_data","error":"the query time range exceeds the limit (query length: 800h, limit: 721h)"}`,
+			wantErr: "Loki query limit exceeded: the query time range exceeds the limit",
+		},
+		{
+			name:    "message envelope",
+			body:    `{"code":400,"message":"too many outstanding requests"}`,
+			wantErr: "Loki query limit exceeded: too many outstanding requests",
+		},
+		{
+			name:    "parse error",
+			body:    `parse error at line 1, col 2: syntax error: unexpected IDENTIFIER`,
+			wantErr: "invalid LogQL query: parse error at line 1, col 2",
+		},
+		{
+			name:    "other client error",
+			body:    `tenant not found`,
+			wantErr: "Loki API returned status 400: tenant not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"lokiUrl": srv.URL}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`})
+
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", res.Error, tt.wantErr)
+			}
+			if res.Status != backend.StatusBadRequest {
+				t.Errorf("status = %v, want %v", res.Status, backend.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestLokiWithCount(t *testing.T) {
+	const twoStreams = `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"job":"api"},"values":[["1704103200000000000","a"],["1704103201000000000","b"]]},
+		{"stream":{"job":"web"},"values":[["1704103202000000000","c"]]}
+	]}}`
+
+	tests := []struct {
+		name      string
+		body      string
+		withCount bool
+		wantCount int64
+	}{
+		{name: "counted", body: twoStreams, withCount: true, wantCount: 3},
+		{name: "no lines", body: `{"status":"success","data":{"resultType":"streams","result":[]}}`, withCount: true, wantCount: 0},
+		{name: "not requested", body: twoStreams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := testQuery(t, "A", map[string]interface{}{"queryType": "loki", "logQL": `{job=~".+"}`, "withCount": tt.withCount})
+			_, res := serveLoki(t, nil, tt.body, query)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			var count *data.Frame
+			for _, frame := range res.Frames {
+				if frame.Name == "count" {
+					count = frame
+				}
+			}
+			if !tt.withCount {
+				if count != nil {
+					t.Error("count frame returned without withCount")
+				}
+				return
+			}
+			if count == nil {
+				t.Fatal("missing count frame")
+			}
+			if got := count.Fields[0].At(0); got != tt.wantCount {
+				t.Errorf("count = %v, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestLokiSeriesNameLabel(t *testing.T) {
+	const streams = `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"app":"checkout","job":"api"},"values":[["1704103200000000000","a"]]},
+		{"stream":{"job":"web"},"values":[["1704103200000000000","b"]]}
+	]}}`
+	const matrix = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"app":"checkout","job":"api"},"values":[[1704103200,"1"]]}
+	]}}`
+
+	tests := []struct {
+		name      string
+		label     string
+		logQL     string
+		body      string
+		wantNames []string
+	}{
+		{name: "log streams by label", label: "app", logQL: `{job=~".+"}`, body: streams, wantNames: []string{"checkout", "web"}},
+		{name: "log streams by job", logQL: `{job=~".+"}`, body: streams, wantNames: []string{"api", "web"}},
+		{name: "metric series by label", label: "app", logQL: `rate({job="api"}[5m])`, body: matrix, wantNames: []string{"checkout"}},
+		{name: "metric series by job", logQL: `rate({job="api"}[5m])`, body: matrix, wantNames: []string{"api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := testQuery(t, "A", map[string]interface{}{"queryType": "loki", "logQL": tt.logQL})
+			_, res := serveLoki(t, map[string]interface{}{"seriesNameLabel": tt.label}, tt.body, query)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			var names []string
+			for _, frame := range res.Frames {
+				name := frame.Name
+				for _, field := range frame.Fields {
+					if field.Config != nil && field.Config.DisplayNameFromDS != "" {
+						name = field.Config.DisplayNameFromDS
+					}
+				}
+				names = append(names, name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("names = %q, want %q", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestLokiLabelsField(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"job":"api","pod":"api-\"0\""},"values":[["1704103200000000000","a"],["1704103201000000000","b"]]},
+		{"stream":{"job":"web"},"values":[["1704103200000000000","c"]]}
+	]}}`
+
+	for _, emit := range []bool{false, true} {
+		t.Run(fmt.Sprint(emit), func(t *testing.T) {
+			_, res := serveLoki(t, nil, body, testQuery(t, "A", map[string]interface{}{
+				"queryType":       "loki",
+				"logQL":           `{job=~".+"}`,
+				"emitLabelsField": emit,
+			}))
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != 2 {
+				t.Fatalf("got %d frames, want 2", len(res.Frames))
+			}
+
+			wantStreams := []map[string]string{{"job": "api", "pod": `api-"0"`}, {"job": "web"}}
+			for i, frame := range res.Frames {
+				field, _ := frame.FieldByName("labels_json")
+				if !emit {
+					if field != nil {
+						t.Errorf("frame %d has a labels_json field without the option", i)
+					}
+					continue
+				}
+				if field == nil {
+					t.Fatalf("frame %d has no labels_json field", i)
+				}
+				if field.Len() != frame.Rows() {
+					t.Errorf("frame %d labels_json has %d rows, want %d", i, field.Len(), frame.Rows())
+				}
+				for row := 0; row < field.Len(); row++ {
+					var got map[string]string
+					if err := json.Unmarshal([]byte(field.At(row).(string)), &got); err != nil {
+						t.Fatalf("frame %d row %d: %v", i, row, err)
+					}
+					if !reflect.DeepEqual(got, wantStreams[i]) {
+						t.Errorf("frame %d row %d labels = %v, want %v", i, row, got, wantStreams[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLokiLineLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]interface{}
+		limit      int
+		wantLimit  int
+		wantNotice bool
+	}{
+		{name: "default", wantLimit: 1000},
+		{name: "configured default", settings: map[string]interface{}{"lokiMaxLines": 200}, wantLimit: 200},
+		{name: "query limit wins", settings: map[string]interface{}{"lokiMaxLines": 200}, limit: 50, wantLimit: 50},
+		{name: "capped", limit: 100000, wantLimit: 5000},
+		{name: "configured default capped", settings: map[string]interface{}{"lokiMaxLines": 9000}, wantLimit: 5000},
+		{name: "limit reached", limit: 2, wantLimit: 2, wantNotice: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, res := serveLoki(t, tt.settings, lokiStreamsResponse, testQuery(t, "A", map[string]interface{}{
+				"queryType": "loki",
+				"logQL":     `{job="api"}`,
+				"limit":     tt.limit,
+			}))
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if got := params.Get("limit"); got != fmt.Sprint(tt.wantLimit) {
+				t.Errorf("limit = %s, want %d", got, tt.wantLimit)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			frame := res.Frames[0]
+			if custom, _ := frame.Meta.Custom.(map[string]interface{}); custom["limit"] != tt.wantLimit {
+				t.Errorf("meta limit = %v, want %d", custom["limit"], tt.wantLimit)
+			}
+			if got := hasNotice(frame, "Loki returned 2 lines, the limit of this query; results may be truncated"); got != tt.wantNotice {
+				t.Errorf("truncation notice = %v, want %v", got, tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestLokiDirection(t *testing.T) {
+	// Lines arrive out of order, with two sharing a timestamp
+	body := `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"job":"api"},"values":[
+			["1704103201000000000","second"],
+			["1704103200000000000","first"],
+			["1704103202000000000","third"],
+			["1704103201000000000","second again"]
+		]}
+	]}}`
+
+	tests := []struct {
+		name          string
+		direction     string
+		wantDirection string
+		wantLines     []string
+		wantErr       string
+	}{
+		{name: "default", wantDirection: "backward", wantLines: []string{"third", "second", "second again", "first"}},
+		{name: "backward", direction: "backward", wantDirection: "backward", wantLines: []string{"third", "second", "second again", "first"}},
+		{name: "forward", direction: "forward", wantDirection: "forward", wantLines: []string{"first", "second", "second again", "third"}},
+		{name: "invalid", direction: "sideways", wantErr: `invalid direction "sideways"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, res := serveLoki(t, nil, body, testQuery(t, "A", map[string]interface{}{
+				"queryType": "loki",
+				"logQL":     `{job="api"}`,
+				"direction": tt.direction,
+			}))
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", res.Error, tt.wantErr)
+				}
+				if params != nil {
+					t.Errorf("invalid query reached Loki with %v", params)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if got := params.Get("direction"); got != tt.wantDirection {
+				t.Errorf("direction = %q, want %q", got, tt.wantDirection)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			frame := res.Frames[0]
+			field, _ := frame.FieldByName("body")
+			if field == nil {
+				t.Fatal("missing body field")
+			}
+			var lines []string
+			for i := 0; i < field.Len(); i++ {
+				lines = append(lines, field.At(i).(string))
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines = %q, want %q", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestLokiMetricFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantTimes  []time.Time
+		wantValues []float64
+		wantErr    string
+	}{
+		{
+			name: "matrix",
+			body: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"api"},"values":[[1704103200,"1.5"],[1704103260.25,"2"]]}
+			]}}`,
+			wantTimes:  []time.Time{time.UnixMilli(1704103200000), time.UnixMilli(1704103260250)},
+			wantValues: []float64{1.5, 2},
+		},
+		{
+			name: "vector",
+			body: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"job":"api"},"value":[1704103200.5,"42"]}
+			]}}`,
+			wantTimes:  []time.Time{time.UnixMilli(1704103200500)},
+			wantValues: []float64{42},
+		},
+		{
+			name: "invalid timestamp",
+			body: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"api"},"values":[["now","1"]]}
+			]}}`,
+			wantErr: "invalid timestamp format",
+		},
+		{
+			name: "short sample",
+			body: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"job":"api"},"value":[1704103200]}
+			]}}`,
+			wantErr: "invalid metric sample",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, res := serveLoki(t, nil, tt.body, testQuery(t, "A", map[string]interface{}{
+				"queryType": "loki",
+				"logQL":     `rate({job="api"}[5m])`,
+			}))
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", res.Error, tt.wantErr)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			frame := res.Frames[0]
+			if frame.Meta == nil || frame.Meta.Type != data.FrameTypeTimeSeriesMany {
+				t.Errorf("frame meta = %+v, want type %s", frame.Meta, data.FrameTypeTimeSeriesMany)
+			}
+			if len(frame.Fields) != 2 {
+				t.Fatalf("got %d fields, want 2", len(frame.Fields))
+			}
+
+			timeField, valueField := frame.Fields[0], frame.Fields[1]
+			var times []time.Time
+			for i := 0; i < timeField.Len(); i++ {
+				times = append(times, timeField.At(i).(time.Time))
+			}
+			if !reflect.DeepEqual(times, tt.wantTimes) {
+				t.Errorf("times = %v, want %v", times, tt.wantTimes)
+			}
+			var values []float64
+			for i := 0; i < valueField.Len(); i++ {
+				values = append(values, valueField.At(i).(float64))
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", values, tt.wantValues)
+			}
+			if got := valueField.Labels["job"]; got != "api" {
+				t.Errorf("job label = %q, want api", got)
+			}
+			if valueField.Config == nil || valueField.Config.DisplayNameFromDS != "api" {
+				t.Errorf("display name config = %+v, want api", valueField.Config)
+			}
+		})
+	}
+}
+
+func TestLokiLogsFrame(t *testing.T) {
+	// The duplicated line needs its own id for the Logs panel to keep both
+	body := `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"job":"api","env":"prod"},"values":[
+			["1704103200000000000","started"],
+			["1704103201000000000","tick"],
+			["1704103201000000000","tick"]
+		]}
+	]}}`
+
+	_, res := serveLoki(t, nil, body, testQuery(t, "A", map[string]interface{}{
+		"queryType": "loki",
+		"logQL":     `{job="api"}`,
+		"direction": "forward",
+	}))
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+	frame := res.Frames[0]
+
+	if frame.Meta == nil || frame.Meta.Type != data.FrameTypeLogLines {
+		t.Errorf("frame meta = %+v, want type %s", frame.Meta, data.FrameTypeLogLines)
+	}
+
+	wantFields := []struct {
+		name string
+		typ  data.FieldType
+	}{
+		{"timestamp", data.FieldTypeTime},
+		{"body", data.FieldTypeString},
+		{"labels", data.FieldTypeJSON},
+		{"id", data.FieldTypeString},
+	}
+	if len(frame.Fields) != len(wantFields) {
+		t.Fatalf("got %d fields, want %d", len(frame.Fields), len(wantFields))
+	}
+	for i, want := range wantFields {
+		field := frame.Fields[i]
+		if field.Name != want.name || field.Type() != want.typ {
+			t.Errorf("field %d = %s (%s), want %s (%s)", i, field.Name, field.Type(), want.name, want.typ)
+		}
+	}
+
+	labels, _ := frame.FieldByName("labels")
+	ids, _ := frame.FieldByName("id")
+	seen := make(map[string]bool)
+	for i := 0; i < frame.Rows(); i++ {
+		var got map[string]string
+		if err := json.Unmarshal(labels.At(i).(json.RawMessage), &got); err != nil {
+			t.Fatalf("row %d labels: %v", i, err)
+		}
+		if want := map[string]string{"job": "api", "env": "prod"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d labels = %v, want %v", i, got, want)
+		}
+
+		id := ids.At(i).(string)
+		if seen[id] {
+			t.Errorf("row %d id %q is not unique", i, id)
+		}
+		seen[id] = true
+	}
+}
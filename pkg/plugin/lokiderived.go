@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// derivedField is a configured Loki derived field with its compiled matcher
+type derivedField struct {
+	name    string
+	matcher *regexp.Regexp
+	url     string
+}
+
+// compileDerivedFields compiles the configured derived field matchers once,
+// so an invalid regex is reported when the datasource is created. Invalid
+// fields are skipped and returned as errors rather than failing the whole
+// datasource.
+func compileDerivedFields(configs []models.DerivedField) ([]derivedField, []error) {
+	fields := make([]derivedField, 0, len(configs))
+	var errs []error
+	for _, c := range configs {
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("derived field requires a name"))
+			continue
+		}
+		matcher, err := regexp.Compile(c.MatcherRegex)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid regex for derived field %q: %w", c.Name, err))
+			continue
+		}
+		fields = append(fields, derivedField{name: c.Name, matcher: matcher, url: c.URL})
+	}
+	return fields, errs
+}
+
+// derivedDataFields extracts each derived field from the log lines. The
+// value is the first capture group, or the whole match without one; lines
+// that don't match get a null. A configured URL becomes a data link, with
+// ${__value.raw} filled in by Grafana.
+func derivedDataFields(fields []derivedField, lines []string) []*data.Field {
+	result := make([]*data.Field, 0, len(fields))
+	for _, f := range fields {
+		values := make([]*string, len(lines))
+		for i, line := range lines {
+			m := f.matcher.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			value := m[0]
+			if len(m) > 1 {
+				value = m[1]
+			}
+			values[i] = &value
+		}
+
+		field := data.NewField(f.name, nil, values)
+		if f.url != "" {
+			field.Config = &data.FieldConfig{
+				Links: []data.DataLink{{Title: f.name, URL: f.url}},
+			}
+		}
+		result = append(result, field)
+	}
+	return result
+}
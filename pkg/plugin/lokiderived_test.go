@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const lokiStreamsResponse = `{"status":"success","data":{"resultType":"streams","result":[
+	{"stream":{"job":"api"},"values":[
+		["1704103200000000000","request done traceID=abc123"],
+		["1704103201000000000","no trace here"]
+	]}
+]}}`
+
+func TestLokiDerivedFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, lokiStreamsResponse)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"lokiUrl": srv.URL,
+		"lokiDerivedFields": []map[string]string{
+			{"name": "traceID", "matcherRegex": `traceID=(\w+)`, "url": "https://tempo/trace/${__value.raw}"},
+			{"name": "broken", "matcherRegex": `(unclosed`},
+		},
+	}, nil)
+
+	res := runQuery(t, ds, map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+	frame := res.Frames[0]
+
+	field, _ := frame.FieldByName("traceID")
+	if field == nil {
+		t.Fatal("traceID field missing")
+	}
+	if field.Config == nil || len(field.Config.Links) != 1 || field.Config.Links[0].URL != "https://tempo/trace/${__value.raw}" {
+		t.Errorf("traceID links = %+v", field.Config)
+	}
+
+	values := map[string]*string{}
+	for i := 0; i < field.Len(); i++ {
+		line, _ := frame.Fields[1].ConcreteAt(i)
+		values[line.(string)] = field.At(i).(*string)
+	}
+	if v := values["request done traceID=abc123"]; v == nil || *v != "abc123" {
+		t.Errorf("traceID of the traced line = %v, want abc123", v)
+	}
+	if v := values["no trace here"]; v != nil {
+		t.Errorf("traceID of the untraced line = %q, want null", *v)
+	}
+
+	if f, _ := frame.FieldByName("broken"); f != nil {
+		t.Error("invalid derived field was added")
+	}
+	if !hasNotice(frame, `invalid regex for derived field "broken"`) {
+		t.Error("skipped derived field was not reported")
+	}
+}
+
+func TestInvalidDerivedFieldKeepsOtherSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"prometheusUrl":     srv.URL,
+		"lokiDerivedFields": []map[string]string{{"name": "broken", "matcherRegex": `(unclosed`}},
+	}, nil)
+
+	res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up", "queryKind": "instant"})
+	if res.Error != nil {
+		t.Fatalf("Prometheus query failed: %v", res.Error)
+	}
+}
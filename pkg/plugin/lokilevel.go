@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// levelLabels are the stream labels that carry a log level, in priority order
+var levelLabels = []string{"level", "severity", "detected_level", "lvl"}
+
+var (
+	// logfmtLevelPattern matches level=error style keys in logfmt lines
+	logfmtLevelPattern = regexp.MustCompile(`(?i)(?:^|\s)(?:level|lvl|severity)="?(\w+)`)
+
+	// jsonLevelPattern matches "level":"warn" style keys in JSON lines
+	jsonLevelPattern = regexp.MustCompile(`(?i)"(?:level|lvl|severity)"\s*:\s*"(\w+)"`)
+)
+
+// levelField builds the "level" field the Logs panel colors rows by. A level
+// label applies to every line of the stream; otherwise each line is parsed
+// as logfmt or JSON, and lines without a recognizable level get "".
+func levelField(labels map[string]string, lines []string) *data.Field {
+	levels := make([]string, len(lines))
+
+	for _, label := range levelLabels {
+		if v, ok := labels[label]; ok {
+			level := normalizeLevel(v)
+			for i := range levels {
+				levels[i] = level
+			}
+			return data.NewField("level", nil, levels)
+		}
+	}
+
+	for i, line := range lines {
+		levels[i] = detectLineLevel(line)
+	}
+	return data.NewField("level", nil, levels)
+}
+
+// detectLineLevel parses the level of a logfmt or JSON log line
+func detectLineLevel(line string) string {
+	pattern := logfmtLevelPattern
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		pattern = jsonLevelPattern
+	}
+	if m := pattern.FindStringSubmatch(line); m != nil {
+		return normalizeLevel(m[1])
+	}
+	return ""
+}
+
+// normalizeLevel maps common level spellings to the names Grafana colors
+func normalizeLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "crit", "critical", "fatal", "panic", "emerg", "alert":
+		return "critical"
+	case "err", "error", "eror":
+		return "error"
+	case "warn", "warning":
+		return "warning"
+	case "info", "information", "notice":
+		return "info"
+	case "debug", "dbug":
+		return "debug"
+	case "trace":
+		return "trace"
+	}
+	return ""
+}
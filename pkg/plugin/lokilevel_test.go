@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevelField(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		lines  []string
+		want   []string
+	}{
+		{
+			name:  "logfmt",
+			lines: []string{`level=error msg="boom"`, `ts=1 lvl=WARN msg=slow`, `severity="debug" msg=x`},
+			want:  []string{"error", "warning", "debug"},
+		},
+		{
+			name:  "json",
+			lines: []string{`{"level":"warn","msg":"slow"}`, `{"msg":"x", "severity" : "FATAL"}`, `{"lvl":"info"}`},
+			want:  []string{"warning", "critical", "info"},
+		},
+		{
+			name:  "unparseable",
+			lines: []string{"plain text line", "loglevel=error", `{"level":"verbose"}`, ""},
+			want:  []string{"", "", "", ""},
+		},
+		{
+			name:   "level label wins",
+			labels: map[string]string{"severity": "err"},
+			lines:  []string{"level=info", "no level"},
+			want:   []string{"error", "error"},
+		},
+		{
+			name:   "unrelated labels",
+			labels: map[string]string{"job": "api"},
+			lines:  []string{"level=info"},
+			want:   []string{"info"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := levelField(tt.labels, tt.lines)
+			if field.Name != "level" {
+				t.Errorf("field name = %q, want level", field.Name)
+			}
+			got := make([]string, field.Len())
+			for i := range got {
+				got[i] = field.At(i).(string)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("levels = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLokiDetectLevel(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"job":"api"},"values":[["1704103200000000000","level=error msg=boom"]]}
+	]}}`
+
+	for _, detect := range []bool{false, true} {
+		_, res := serveLoki(t, nil, body, testQuery(t, "A", map[string]interface{}{
+			"queryType":   "loki",
+			"logQL":       `{job="api"}`,
+			"detectLevel": detect,
+		}))
+		if res.Error != nil {
+			t.Fatalf("query failed: %v", res.Error)
+		}
+		field, _ := res.Frames[0].FieldByName("level")
+		if (field != nil) != detect {
+			t.Fatalf("detectLevel %v: level field present = %v", detect, field != nil)
+		}
+		if field != nil && field.At(0) != "error" {
+			t.Errorf("level = %v, want error", field.At(0))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// handleLokiLabelsResource serves loki/labels, listing label names, and
+// loki/label/<name>/values, listing a label's values. Both accept start and
+// end so discovery follows the dashboard time range, and return
+// {"values": [...]} without the Loki envelope.
+func (d *Datasource) handleLokiLabelsResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	var elem []string
+	switch {
+	case req.Path == "loki/labels":
+		elem = []string{"labels"}
+	case strings.HasPrefix(req.Path, "loki/label/") && strings.HasSuffix(req.Path, "/values"):
+		name := strings.TrimSuffix(strings.TrimPrefix(req.Path, "loki/label/"), "/values")
+		if !labelNamePattern.MatchString(name) {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: 400,
+				Body:   []byte(`{"error": "A valid label name is required"}`),
+			})
+		}
+		elem = []string{"label", name, "values"}
+	default:
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 404,
+			Body:   []byte(`{"error": "Unknown resource path"}`),
+		})
+	}
+
+	if d.config.LokiURL == "" {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 400,
+			Body:   []byte(`{"error": "Loki URL not configured"}`),
+		})
+	}
+	apiURL, err := lokiAPIURL(d.config, elem...)
+	if err != nil {
+		return d.sendAPIError(ctx, sender, err)
+	}
+
+	query := resourceQuery(req)
+	params := url.Values{}
+	for _, key := range []string{"start", "end", "query"} {
+		if v := query.Get(key); v != "" {
+			params.Set(key, v)
+		}
+	}
+
+	raw, err := d.fetchAPIData(ctx, "Loki", apiURL, params, d.config.LokiHeaders)
+	if err != nil {
+		return d.sendAPIError(ctx, sender, err)
+	}
+
+	values := []string{}
+	if err := json.Unmarshal(raw, &values); err != nil || values == nil {
+		values = []string{}
+	}
+
+	return sendResourceJSON(sender, map[string]interface{}{"values": values})
+}
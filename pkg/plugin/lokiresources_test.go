@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLokiLabelsResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		query      string
+		status     int
+		body       string
+		wantStatus int
+		wantBody   string
+		wantPath   string
+		wantParams url.Values
+	}{
+		{
+			name:       "label names",
+			path:       "loki/labels",
+			query:      "start=1704103200000000000&end=1704106800000000000&ignored=1",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":["app","job"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"values":["app","job"]}`,
+			wantPath:   "/loki/api/v1/labels",
+			wantParams: url.Values{"start": {"1704103200000000000"}, "end": {"1704106800000000000"}},
+		},
+		{
+			name:       "label values",
+			path:       "loki/label/job/values",
+			query:      `query={app="checkout"}&start=1704103200000000000`,
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":["api","web"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"values":["api","web"]}`,
+			wantPath:   "/loki/api/v1/label/job/values",
+			wantParams: url.Values{"query": {`{app="checkout"}`}, "start": {"1704103200000000000"}},
+		},
+		{
+			name:       "no values",
+			path:       "loki/label/job/values",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":null}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"values":[]}`,
+			wantPath:   "/loki/api/v1/label/job/values",
+			wantParams: url.Values{},
+		},
+		{
+			name:       "invalid label name",
+			path:       "loki/label/a..b/values",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error": "A valid label name is required"}`,
+		},
+		{
+			name:       "unknown path",
+			path:       "loki/label/job",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error": "Unknown resource path"}`,
+		},
+		{
+			name:       "backend error",
+			path:       "loki/labels",
+			status:     http.StatusBadRequest,
+			body:       `{"status":"error","error":"bad time range"}`,
+			wantStatus: http.StatusBadGateway,
+			wantBody:   `{"error":"Loki returned status 400: {\"status\":\"error\",\"error\":\"bad time range\"}"}`,
+			wantPath:   "/loki/api/v1/labels",
+			wantParams: url.Values{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := stubPrometheusAPI(t, tt.status, tt.body)
+			ds := newTestDatasource(t, map[string]interface{}{"lokiUrl": srv.URL, "authMode": "bearer"}, map[string]string{"bearerToken": "secret"})
+
+			resourceURL := tt.path
+			if tt.query != "" {
+				resourceURL += "?" + tt.query
+			}
+			resp := callResource(t, ds, &backend.CallResourceRequest{Path: tt.path, URL: resourceURL, Method: "GET"}).responses[0]
+			if resp.Status != tt.wantStatus || string(resp.Body) != tt.wantBody {
+				t.Errorf("response = %d %s, want %d %s", resp.Status, resp.Body, tt.wantStatus, tt.wantBody)
+			}
+
+			if tt.wantPath == "" {
+				if len(*requests) != 0 {
+					t.Errorf("requests = %v, want none", *requests)
+				}
+				return
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			got := (*requests)[0]
+			if got.path != tt.wantPath || !reflect.DeepEqual(got.params, tt.wantParams) || got.auth != "Bearer secret" {
+				t.Errorf("request = %+v, want %s %v with auth", got, tt.wantPath, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestLokiLabelsResourceNotConfigured(t *testing.T) {
+	ds := newTestDatasource(t, map[string]interface{}{}, nil)
+
+	resp := callResource(t, ds, &backend.CallResourceRequest{Path: "loki/labels", URL: "loki/labels", Method: "GET"}).responses[0]
+	if resp.Status != http.StatusBadRequest || string(resp.Body) != `{"error": "Loki URL not configured"}` {
+		t.Errorf("response = %d %s, want 400 Loki URL not configured", resp.Status, resp.Body)
+	}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultScrapeInterval is assumed when the datasource doesn't configure one
+const defaultScrapeInterval = 15 * time.Second
+
+// parseScrapeInterval parses the configured Prometheus scrape interval,
+// defaulting to 15s when empty
+func parseScrapeInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultScrapeInterval, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scrape interval %q: %w", value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid scrape interval %q: must be positive", value)
+	}
+	return d, nil
+}
+
+// rateInterval computes $__rate_interval as the larger of four scrape
+// intervals and the panel interval, rounded to whole seconds
+func rateInterval(scrape, interval time.Duration) time.Duration {
+	rate := 4 * scrape
+	if interval > rate {
+		rate = interval
+	}
+
+	rate = rate.Round(time.Second)
+	if rate < time.Second {
+		rate = time.Second
+	}
+	return rate
+}
+
+// formatPromDuration renders a duration in the seconds notation PromQL
+// range selectors accept
+func formatPromDuration(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+}
+
+// formatIntervalDuration renders a panel interval for PromQL, in
+// milliseconds when it isn't a whole number of seconds
+func formatIntervalDuration(d time.Duration) string {
+	if d%time.Second != 0 {
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	return formatPromDuration(d)
+}
+
+// expandPromMacros replaces the Grafana interval and range macros in a PromQL
+// query: $__interval, $__interval_ms, $__rate_interval, $__range, $__range_s
+// and $__range_ms. Without a panel interval the scrape interval is used.
+func expandPromMacros(promQL string, scrape time.Duration, query backend.DataQuery) string {
+	if !strings.Contains(promQL, "$__") {
+		return promQL
+	}
+
+	interval := query.Interval
+	if interval <= 0 {
+		interval = scrape
+	}
+	rangeDuration := query.TimeRange.To.Sub(query.TimeRange.From).Round(time.Second)
+
+	// Longer names come first so $__interval doesn't match $__interval_ms
+	return strings.NewReplacer(
+		"$__rate_interval", formatPromDuration(rateInterval(scrape, query.Interval)),
+		"$__interval_ms", strconv.FormatInt(interval.Milliseconds(), 10),
+		"$__interval", formatIntervalDuration(interval),
+		"$__range_ms", strconv.FormatInt(rangeDuration.Milliseconds(), 10),
+		"$__range_s", strconv.FormatInt(int64(rangeDuration/time.Second), 10),
+		"$__range", formatPromDuration(rangeDuration),
+	).Replace(promQL)
+}
+
+// timeMacroPattern matches the Grafana time macros ${__from}, ${__to},
+// ${__interval} and ${__interval_ms}, with an optional :date format on the
+// range bounds
+var timeMacroPattern = regexp.MustCompile(`\$\{__(from|to|interval_ms|interval)(?::date(?::([a-z]+))?)?\}`)
+
+// expandTimeMacros substitutes the query's time range and interval into a
+// REST endpoint, body or header value. ${__from} and ${__to} are epoch
+// milliseconds; ${__from:date} and ${__from:date:iso} are RFC 3339 and
+// ${__from:date:seconds} is epoch seconds. Unknown formats are left as is.
+func expandTimeMacros(s string, query backend.DataQuery) string {
+	if !strings.Contains(s, "${__") {
+		return s
+	}
+
+	return timeMacroPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := timeMacroPattern.FindStringSubmatch(match)
+		name, hasDate := groups[1], strings.Contains(match, ":date")
+
+		switch name {
+		case "interval":
+			if hasDate {
+				return match
+			}
+			return formatPromDuration(query.Interval)
+		case "interval_ms":
+			if hasDate {
+				return match
+			}
+			return strconv.FormatInt(query.Interval.Milliseconds(), 10)
+		}
+
+		t := query.TimeRange.From
+		if name == "to" {
+			t = query.TimeRange.To
+		}
+		if !hasDate {
+			return strconv.FormatInt(t.UnixMilli(), 10)
+		}
+		switch groups[2] {
+		case "", "iso":
+			return t.UTC().Format(time.RFC3339)
+		case "seconds":
+			return strconv.FormatInt(t.Unix(), 10)
+		}
+		return match
+	})
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpandTimeMacros(t *testing.T) {
+	query := testQuery(t, "A", nil)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "${__from}", want: "1704103200000"},
+		{in: "${__to}", want: "1704106800000"},
+		{in: "${__from:date}", want: "2024-01-01T10:00:00Z"},
+		{in: "${__to:date:iso}", want: "2024-01-01T11:00:00Z"},
+		{in: "${__from:date:seconds}", want: "1704103200"},
+		{in: "${__interval}", want: "60s"},
+		{in: "${__interval_ms}", want: "60000"},
+		{in: "${__from:date:unknown}", want: "${__from:date:unknown}"},
+		{in: "${__interval:date}", want: "${__interval:date}"},
+		{in: "$__from and ${other}", want: "$__from and ${other}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := expandTimeMacros(tt.in, query); got != tt.want {
+				t.Errorf("expandTimeMacros(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRESTTimeMacroSubstitution(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      map[string]interface{}
+		wantPath   string
+		wantQuery  string
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name:     "URL path",
+			model:    map[string]interface{}{"restEndpoint": "/metrics/${__from}/${__to}"},
+			wantPath: "/metrics/1704103200000/1704106800000",
+		},
+		{
+			name:      "query parameters",
+			model:     map[string]interface{}{"restEndpoint": "/metrics", "restParams": map[string]string{"start": "${__from:date:iso}", "step": "${__interval}"}},
+			wantPath:  "/metrics",
+			wantQuery: "start=2024-01-01T10%3A00%3A00Z&step=60s",
+		},
+		{
+			name:     "body",
+			model:    map[string]interface{}{"restEndpoint": "/search", "restMethod": "POST", "restBody": `{"from": ${__from}, "until": "${__to:date}"}`},
+			wantPath: "/search",
+			wantBody: `{"from": 1704103200000, "until": "2024-01-01T11:00:00Z"}`,
+		},
+		{
+			name:       "header",
+			model:      map[string]interface{}{"restEndpoint": "/metrics", "restHeaders": map[string]string{"X-Window": "${__from:date:seconds}-${__to:date:seconds}"}},
+			wantPath:   "/metrics",
+			wantHeader: "1704103200-1704106800",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu                       sync.Mutex
+				path, rawQuery, body, hd string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				path, rawQuery, body, hd = r.URL.Path, r.URL.RawQuery, string(raw), r.Header.Get("X-Window")
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `[{"value": 1}]`)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+			model := map[string]interface{}{"queryType": "rest"}
+			for k, v := range tt.model {
+				model[k] = v
+			}
+			if res := runQuery(t, ds, model); res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if path != tt.wantPath {
+				t.Errorf("path = %q, want %q", path, tt.wantPath)
+			}
+			if rawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", rawQuery, tt.wantQuery)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if hd != tt.wantHeader {
+				t.Errorf("X-Window = %q, want %q", hd, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestExpandPromMacros(t *testing.T) {
+	tests := []struct {
+		name     string
+		promQL   string
+		interval time.Duration
+		span     time.Duration
+		scrape   time.Duration
+		want     string
+	}{
+		{name: "interval", promQL: "avg_over_time(up[$__interval])", interval: time.Minute, span: time.Hour, scrape: 15 * time.Second, want: "avg_over_time(up[60s])"},
+		{name: "sub-second interval", promQL: "up[$__interval]", interval: 1500 * time.Millisecond, span: time.Hour, scrape: 15 * time.Second, want: "up[1500ms]"},
+		{name: "interval without a panel interval", promQL: "up[$__interval]", span: time.Hour, scrape: 30 * time.Second, want: "up[30s]"},
+		{name: "interval_ms", promQL: "up > $__interval_ms", interval: time.Minute, span: time.Hour, scrape: 15 * time.Second, want: "up > 60000"},
+		{name: "rate_interval from the scrape interval", promQL: "rate(x[$__rate_interval])", interval: 30 * time.Second, span: time.Hour, scrape: 15 * time.Second, want: "rate(x[60s])"},
+		{name: "rate_interval from the panel interval", promQL: "rate(x[$__rate_interval])", interval: 5 * time.Minute, span: time.Hour, scrape: 15 * time.Second, want: "rate(x[300s])"},
+		{name: "range", promQL: "increase(x[$__range])", interval: time.Minute, span: 6 * time.Hour, scrape: 15 * time.Second, want: "increase(x[21600s])"},
+		{name: "range_s and range_ms", promQL: "$__range_s / $__range_ms", interval: time.Minute, span: 90 * time.Second, scrape: 15 * time.Second, want: "90 / 90000"},
+		{name: "several macros", promQL: "rate(x[$__rate_interval]) * $__interval_ms", interval: time.Minute, span: time.Hour, scrape: 10 * time.Second, want: "rate(x[60s]) * 60000"},
+		{name: "no macros", promQL: "up", interval: time.Minute, span: time.Hour, scrape: 15 * time.Second, want: "up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := testQuery(t, "A", nil)
+			query.Interval = tt.interval
+			query.TimeRange.From = query.TimeRange.To.Add(-tt.span)
+
+			if got := expandPromMacros(tt.promQL, tt.scrape, query); got != tt.want {
+				t.Errorf("expandPromMacros(%q) = %q, want %q", tt.promQL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusQueryExpandsMacros(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		query string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		query = r.URL.Query().Get("query")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, prometheusMatrix(1))
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL, "scrapeInterval": "30s"}, nil)
+	res := runQuery(t, ds, map[string]interface{}{
+		"queryType": "prometheus",
+		"promQL":    "rate(http_requests_total[$__rate_interval]) / $__interval_ms + count_over_time(up[$__range])",
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := "rate(http_requests_total[120s]) / 60000 + count_over_time(up[3600s])"; query != want {
+		t.Errorf("sent query %q, want %q", query, want)
+	}
+}
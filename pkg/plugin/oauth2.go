@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauth2Transport authenticates requests with client-credentials tokens.
+// Tokens are cached until they expire; a 401 drops the cached token and the
+// request is retried once with a fresh one. Tokens are fetched with the
+// outgoing request's context, so a hanging token endpoint is bounded by the
+// query deadline as well as the request timeout.
+type oauth2Transport struct {
+	base   http.RoundTripper
+	config *clientcredentials.Config
+	client *http.Client
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// newOAuth2Transport wraps base with client-credentials authentication, or
+// returns base unchanged when no token URL is configured
+func newOAuth2Transport(config *models.DataSourceConfig, base http.RoundTripper) http.RoundTripper {
+	if config.OAuth2TokenURL == "" {
+		return base
+	}
+
+	return &oauth2Transport{
+		base: base,
+		config: &clientcredentials.Config{
+			ClientID:     config.OAuth2ClientID,
+			ClientSecret: config.OAuth2ClientSecret,
+			TokenURL:     config.OAuth2TokenURL,
+			Scopes:       config.OAuth2Scopes,
+		},
+		// Token requests go through the same transport as backend requests
+		client: &http.Client{Transport: base, Timeout: requestTimeout(config)},
+	}
+}
+
+// cachedToken returns the cached token while it is valid
+func (t *oauth2Transport) cachedToken() *oauth2.Token {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.Valid() {
+		return t.token
+	}
+	return nil
+}
+
+// fetchToken returns the cached token or fetches a new one within ctx. The
+// lock isn't held while fetching, so a slow token endpoint doesn't block
+// requests whose own context has already ended.
+func (t *oauth2Transport) fetchToken(ctx context.Context) (*oauth2.Token, error) {
+	if token := t.cachedToken(); token != nil {
+		return token, nil
+	}
+
+	token, err := t.config.Token(context.WithValue(ctx, oauth2.HTTPClient, t.client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain OAuth2 token: %w", err)
+	}
+
+	t.mu.Lock()
+	t.token = token
+	t.mu.Unlock()
+	return token, nil
+}
+
+// reset discards the cached token so the next request fetches a new one
+func (t *oauth2Transport) reset(stale *oauth2.Token) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == stale {
+		t.token = nil
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.fetchToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.roundTripWithToken(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token may have been revoked before its expiry; retry once if the
+	// body can be replayed
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.reset(token)
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	if token, err = t.fetchToken(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.roundTripWithToken(retry, token)
+}
+
+// roundTripWithToken sends req with the bearer token
+func (t *oauth2Transport) roundTripWithToken(req *http.Request, token *oauth2.Token) (*http.Response, error) {
+	authReq := req.Clone(req.Context())
+	token.SetAuthHeader(authReq)
+	return t.base.RoundTrip(authReq)
+}
+
+// CloseIdleConnections closes idle connections of the base transport
+func (t *oauth2Transport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
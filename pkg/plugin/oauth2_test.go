@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// tokenServer issues numbered client-credentials tokens after delay
+type tokenServer struct {
+	delay  time.Duration
+	issued int32
+}
+
+func (s *tokenServer) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the form lets the server notice a client giving up
+		r.ParseForm()
+		select {
+		case <-time.After(s.delay):
+		case <-r.Context().Done():
+			return
+		}
+		n := atomic.AddInt32(&s.issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, n)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOAuth2TokenCaching(t *testing.T) {
+	tokens := &tokenServer{}
+	tokenSrv := tokens.start(t)
+
+	var revoked int32
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first token is revoked after two uses
+		if r.Header.Get("Authorization") == "Bearer token-1" && atomic.AddInt32(&revoked, 1) > 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"auth": %q}]`, r.Header.Get("Authorization"))
+	}))
+	defer backendSrv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{
+		"restUrl":        backendSrv.URL,
+		"oauth2TokenUrl": tokenSrv.URL,
+		"oauth2ClientId": "grafana",
+	}, map[string]string{"oauth2ClientSecret": "secret"})
+
+	for i, want := range []string{"Bearer token-1", "Bearer token-1", "Bearer token-2"} {
+		res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+		if res.Error != nil {
+			t.Fatalf("query %d failed: %v", i, res.Error)
+		}
+		if got := derefString(res.Frames[0].Fields[0].At(0)); got != want {
+			t.Errorf("query %d sent %v, want %q", i, got, want)
+		}
+	}
+	if got := atomic.LoadInt32(&tokens.issued); got != 2 {
+		t.Errorf("issued %d tokens, want 2", got)
+	}
+}
+
+func TestOAuth2StalledTokenServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		deadline time.Duration
+	}{
+		{name: "bounded by the query deadline", deadline: 200 * time.Millisecond},
+		{name: "bounded by the request timeout", settings: map[string]interface{}{"timeoutSeconds": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenSrv := (&tokenServer{delay: 10 * time.Second}).start(t)
+			var requests int32
+			backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+			}))
+			defer backendSrv.Close()
+
+			settings := map[string]interface{}{
+				"restUrl":               backendSrv.URL,
+				"oauth2TokenUrl":        tokenSrv.URL,
+				"maxConcurrentRequests": 1,
+			}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, nil)
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			start := time.Now()
+			resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{
+				testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}),
+			}})
+			if err != nil {
+				t.Fatalf("QueryData: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("query took %v with a stalled token endpoint", elapsed)
+			}
+			if res := resp.Responses["A"]; res.Error == nil {
+				t.Error("expected the query to fail without a token")
+			}
+			if got := atomic.LoadInt32(&requests); got != 0 {
+				t.Errorf("backend got %d requests without a token", got)
+			}
+
+			// The concurrency slot is released for the next request
+			select {
+			case ds.client.Transport.(*limitedTransport).limiter <- struct{}{}:
+				<-ds.client.Transport.(*limitedTransport).limiter
+			default:
+				t.Error("concurrency slot still held after the token fetch failed")
+			}
+		})
+	}
+}
@@ -1,14 +1,16 @@
 package plugin
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sameersah/GrafanaConnect/pkg/models"
@@ -21,6 +23,7 @@ import (
 type PrometheusHandler struct {
 	config *models.DataSourceConfig
 	logger log.Logger
+	client *http.Client
 }
 
 // handlePrometheusQuery processes Prometheus queries
@@ -28,6 +31,7 @@ func (d *Datasource) handlePrometheusQuery(ctx context.Context, query backend.Da
 	handler := &PrometheusHandler{
 		config: d.config,
 		logger: d.logger,
+		client: d.client,
 	}
 
 	if d.config.PrometheusURL == "" {
@@ -36,19 +40,114 @@ func (d *Datasource) handlePrometheusQuery(ctx context.Context, query backend.Da
 		}
 	}
 
+	// Series lookups list label sets instead of evaluating PromQL
+	if len(queryModel.SeriesMatchers) > 0 {
+		return handler.executeSeriesQuery(ctx, query, queryModel)
+	}
+
 	if queryModel.PromQL == "" {
 		return backend.DataResponse{
 			Error: fmt.Errorf("PromQL query is required"),
 		}
 	}
 
-	return handler.executeQuery(ctx, query, queryModel)
+	queryModel.PromQL = interpolateQueryVariables(queryModel.PromQL, queryModel.Variables)
+
+	// The interval is validated when the datasource is created
+	scrape, err := parseScrapeInterval(d.config.ScrapeInterval)
+	if err != nil {
+		scrape = defaultScrapeInterval
+	}
+	queryModel.PromQL = expandPromMacros(queryModel.PromQL, scrape, query)
+
+	var res backend.DataResponse
+	if len(queryModel.Steps) > 0 && queryModel.Kind() == models.QueryKindRange {
+		// Overlay several resolutions of the same range query
+		res = handler.executeMultiStep(ctx, query, queryModel)
+	} else {
+		res = handler.executeQuery(ctx, query, queryModel)
+	}
+	if queryModel.IncludeRaw && res.Error == nil {
+		res.Frames = handler.appendRawSeries(ctx, query, queryModel, res.Frames)
+	}
+	roundFrameValues(res.Frames, queryModel.RoundDecimals)
+
+	return res
+}
+
+// appendRawSeries queries the counter selector inside a rate() expression and
+// appends its series as frames named "raw", so counter resets are visible
+func (h *PrometheusHandler) appendRawSeries(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel, frames data.Frames) data.Frames {
+	selector, ok := rateInnerSelector(queryModel.PromQL)
+	if !ok {
+		addNotice(frames, data.NoticeSeverityInfo, "Raw series are only available for rate(...) expressions")
+		return frames
+	}
+
+	rawModel := *queryModel
+	rawModel.PromQL = selector
+	rawModel.IncludeRaw = false
+
+	rawRes := h.executeQuery(ctx, query, &rawModel)
+	if rawRes.Error != nil {
+		addNotice(frames, data.NoticeSeverityWarning, fmt.Sprintf("Failed to fetch raw series: %v", rawRes.Error))
+		return frames
+	}
+
+	for _, frame := range rawRes.Frames {
+		frame.Name = "raw"
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// rateInnerSelector returns the series selector wrapped by a top-level
+// rate(...) or irate(...) call, without its range, e.g. "up{job="a"}" for
+// "rate(up{job="a"}[5m])"
+func rateInnerSelector(promQL string) (string, bool) {
+	expr := strings.TrimSpace(promQL)
+
+	var inner string
+	for _, fn := range []string{"rate(", "irate("} {
+		if strings.HasPrefix(expr, fn) && strings.HasSuffix(expr, ")") {
+			inner = expr[len(fn) : len(expr)-1]
+			break
+		}
+	}
+	if inner == "" {
+		return "", false
+	}
+
+	// Reject expressions such as rate(a[5m]) / rate(b[5m]) where the
+	// outer parentheses don't enclose the whole expression
+	depth := 0
+	for _, c := range inner {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return "", false
+			}
+		}
+	}
+
+	inner = strings.TrimSpace(inner)
+	if !strings.HasSuffix(inner, "]") {
+		return "", false
+	}
+	open := strings.LastIndex(inner, "[")
+	if open <= 0 {
+		return "", false
+	}
+	return strings.TrimSpace(inner[:open]), true
 }
 
 // executeQuery executes a Prometheus query
 func (h *PrometheusHandler) executeQuery(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
 	// Determine query type (instant vs range)
-	isRangeQuery := !query.TimeRange.From.Equal(query.TimeRange.To)
+	isRangeQuery := queryModel.Kind() == models.QueryKindRange
 
 	var promURL string
 	if isRangeQuery {
@@ -64,15 +163,16 @@ func (h *PrometheusHandler) executeQuery(ctx context.Context, query backend.Data
 	params.Set("query", queryModel.PromQL)
 
 	if isRangeQuery {
+		step, err := calculatePrometheusStep(query, queryModel)
+		if err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
+		}
+
 		params.Set("start", strconv.FormatInt(query.TimeRange.From.Unix(), 10))
 		params.Set("end", strconv.FormatInt(query.TimeRange.To.Unix(), 10))
-		
-		// Calculate step (default to 15s if not specified)
-		step := query.Interval
-		if step == 0 {
-			step = 15 * time.Second
-		}
-		params.Set("step", strconv.FormatInt(int64(step.Seconds()), 10)+"s")
+		params.Set("step", formatPromDuration(step))
 	} else {
 		params.Set("time", strconv.FormatInt(query.TimeRange.To.Unix(), 10))
 	}
@@ -85,29 +185,33 @@ func (h *PrometheusHandler) executeQuery(ctx context.Context, query backend.Data
 		}
 	}
 
-	// Add authentication
+	// Add configured headers and authentication
+	setConfigHeaders(req, h.config.PrometheusHeaders, h.config.Headers)
 	h.addAuthHeaders(req)
+	negotiateCompression(req, queryModel)
 
 	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(ctx, h.client, req, newRetryPolicy(h.config), h.logger)
 	if err != nil {
-		return backend.DataResponse{
-			Error: fmt.Errorf("failed to execute request: %w", err),
-		}
+		return requestErrorResponse(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		body, _ := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+		return statusErrorResponse(resp.StatusCode, fmt.Errorf("Prometheus API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	body, err := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+	if err != nil {
 		return backend.DataResponse{
-			Error: fmt.Errorf("Prometheus API returned status %d: %s", resp.StatusCode, string(body)),
+			Error: err,
 		}
 	}
 
 	// Parse response
 	var promResp models.PrometheusQueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&promResp); err != nil {
+	if err := json.Unmarshal(body, &promResp); err != nil {
 		return backend.DataResponse{
 			Error: fmt.Errorf("failed to parse response: %w", err),
 		}
@@ -119,22 +223,51 @@ func (h *PrometheusHandler) executeQuery(ctx context.Context, query backend.Data
 		}
 	}
 
+	// The result type, not the endpoint, decides the sample layout: scalar and
+	// string results carry a single sample even on the range endpoint, and
+	// subqueries can return a matrix from the instant endpoint
+	switch promResp.Data.ResultType {
+	case "scalar", "string", "vector":
+		isRangeQuery = false
+	case "matrix":
+		isRangeQuery = true
+	}
+
 	// Convert to Grafana data frames
-	frames, err := h.convertToDataFrames(&promResp, isRangeQuery)
+	frames, err := h.convertToDataFrames(&promResp, isRangeQuery, queryModel)
 	if err != nil {
 		return backend.DataResponse{
 			Error: fmt.Errorf("failed to convert response: %w", err),
 		}
 	}
 
+	// Arrange histogram buckets for heatmaps, or group series into one
+	// frame per distinct label combination
+	if queryModel.Format == models.FormatHeatmap {
+		frames = toHeatmapFrames(frames)
+	} else if len(queryModel.GroupByLabels) > 0 {
+		frames = groupFramesByLabels(frames, queryModel.GroupByLabels)
+	}
+
+	// Flag incomplete results so users don't silently trust them
+	if promResp.IsIncomplete() {
+		frames = h.attachPartialNotice(frames, &promResp)
+	}
+
+	// Echo the resolution actually used for debugging
+	if step := params.Get("step"); step != "" {
+		setFrameMetaCustom(frames, "step", step)
+	}
+
 	return backend.DataResponse{
 		Frames: frames,
 	}
 }
 
 // convertToDataFrames converts Prometheus response to Grafana data frames
-func (h *PrometheusHandler) convertToDataFrames(resp *models.PrometheusQueryResponse, isRangeQuery bool) (data.Frames, error) {
+func (h *PrometheusHandler) convertToDataFrames(resp *models.PrometheusQueryResponse, isRangeQuery bool, queryModel *models.QueryModel) (data.Frames, error) {
 	var frames data.Frames
+	correctedSeries := 0
 
 	for _, result := range resp.Data.Result {
 		var timeField *data.Field
@@ -158,17 +291,19 @@ func (h *PrometheusHandler) convertToDataFrames(resp *models.PrometheusQueryResp
 				times[i] = time.Unix(int64(ts), 0)
 
 				// Parse value
-				valStr, ok := val[1].(string)
-				if !ok {
-					return nil, fmt.Errorf("invalid value format")
-				}
-				v, err := strconv.ParseFloat(valStr, 64)
+				v, err := parseSampleValue(val[1])
 				if err != nil {
-					return nil, fmt.Errorf("failed to parse value: %w", err)
+					return nil, err
 				}
 				values[i] = v
 			}
 
+			var corrected bool
+			times, values, corrected = normalizeSamples(times, values, queryModel.TimestampPolicy)
+			if corrected {
+				correctedSeries++
+			}
+
 			timeField = data.NewField("time", nil, times)
 			valueField = data.NewField("value", result.Metric, values)
 		} else {
@@ -183,13 +318,9 @@ func (h *PrometheusHandler) convertToDataFrames(resp *models.PrometheusQueryResp
 			}
 			timestamp := time.Unix(int64(ts), 0)
 
-			valStr, ok := result.Value[1].(string)
-			if !ok {
-				return nil, fmt.Errorf("invalid value format")
-			}
-			v, err := strconv.ParseFloat(valStr, 64)
+			v, err := parseSampleValue(result.Value[1])
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse value: %w", err)
+				return nil, err
 			}
 
 			timeField = data.NewField("time", nil, []time.Time{timestamp})
@@ -198,20 +329,107 @@ func (h *PrometheusHandler) convertToDataFrames(resp *models.PrometheusQueryResp
 
 		// Set field config
 		valueField.Config = &data.FieldConfig{
-			DisplayNameFromDS: h.buildSeriesName(result.Metric),
+			DisplayNameFromDS: h.seriesDisplayName(result.Metric, queryModel.LegendFormat),
 		}
+		applyVisualizationConfig(valueField.Config, queryModel)
+		valueField.Config.Unit = seriesUnit(result.Metric, queryModel)
 
 		frame := data.NewFrame("", timeField, valueField)
 		frame.Meta = &data.FrameMeta{
 			Type: data.FrameTypeTimeSeriesMany,
 		}
 
+		// Promoted labels become string fields for table views, and stay labels
+		for _, label := range queryModel.PromoteLabels {
+			frame.Fields = append(frame.Fields, promotedLabelField(label, result.Metric[label], valueField.Len()))
+		}
+
 		frames = append(frames, frame)
 	}
 
+	if correctedSeries > 0 {
+		addNotice(frames, data.NoticeSeverityInfo,
+			fmt.Sprintf("Corrected out-of-order or duplicate timestamps in %d series", correctedSeries))
+	}
+
 	return frames, nil
 }
 
+// promotedLabelField repeats a label value once per value row
+func promotedLabelField(label, value string, rows int) *data.Field {
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = value
+	}
+	return data.NewField(label, nil, values)
+}
+
+// attachPartialNotice adds a warning notice to every frame of a partial response
+func (h *PrometheusHandler) attachPartialNotice(frames data.Frames, resp *models.PrometheusQueryResponse) data.Frames {
+	text := "Prometheus returned partial results; data may be incomplete"
+	if len(resp.Warnings) > 0 {
+		text += ": " + strings.Join(resp.Warnings, "; ")
+	}
+
+	h.logger.Warn("Partial Prometheus response", "warnings", resp.Warnings)
+
+	// Keep the notice visible even when no series came back
+	if len(frames) == 0 {
+		frames = append(frames, data.NewFrame(""))
+	}
+
+	addNotice(frames, data.NoticeSeverityWarning, text)
+	return frames
+}
+
+// seriesUnit returns the unit of a series, read from the configured label
+// when the series carries it
+func seriesUnit(metric map[string]string, queryModel *models.QueryModel) string {
+	if queryModel.UnitFromLabel != "" {
+		if unit := metric[queryModel.UnitFromLabel]; unit != "" {
+			return unit
+		}
+	}
+	return queryModel.Unit
+}
+
+// applyVisualizationConfig sets query-level min, max and thresholds on a
+// value field so gauges and bars render with consistent scales
+func applyVisualizationConfig(config *data.FieldConfig, queryModel *models.QueryModel) {
+	if queryModel.Min != nil {
+		config.SetMin(*queryModel.Min)
+	}
+	if queryModel.Max != nil {
+		config.SetMax(*queryModel.Max)
+	}
+
+	if len(queryModel.Thresholds) == 0 {
+		return
+	}
+
+	// Steps must be sorted by value and start with a -Infinity base step
+	var base *data.Threshold
+	steps := make([]data.Threshold, 0, len(queryModel.Thresholds)+1)
+	for _, t := range queryModel.Thresholds {
+		if t.Value == nil {
+			b := data.NewThreshold(math.Inf(-1), t.Color, "")
+			base = &b
+			continue
+		}
+		steps = append(steps, data.NewThreshold(*t.Value, t.Color, ""))
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].Value < steps[j].Value })
+	if base == nil {
+		b := data.NewThreshold(math.Inf(-1), "green", "")
+		base = &b
+	}
+
+	config.Thresholds = &data.ThresholdsConfig{
+		Mode:  data.ThresholdsModeAbsolute,
+		Steps: append([]data.Threshold{*base}, steps...),
+	}
+}
+
 // buildSeriesName creates a series name from metric labels
 func (h *PrometheusHandler) buildSeriesName(metric map[string]string) string {
 	if name, ok := metric["__name__"]; ok {
@@ -223,29 +441,45 @@ func (h *PrometheusHandler) buildSeriesName(metric map[string]string) string {
 	return "series"
 }
 
+// legendLabelPattern matches {{label}} placeholders in a legend format
+var legendLabelPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// seriesDisplayName renders the legend format with the series labels, where
+// missing labels render empty, or falls back to the default series name
+func (h *PrometheusHandler) seriesDisplayName(metric map[string]string, legendFormat string) string {
+	if legendFormat == "" {
+		return h.buildSeriesName(metric)
+	}
+	return legendLabelPattern.ReplaceAllStringFunc(legendFormat, func(m string) string {
+		return metric[legendLabelPattern.FindStringSubmatch(m)[1]]
+	})
+}
+
 // addAuthHeaders adds authentication headers to the request
 func (h *PrometheusHandler) addAuthHeaders(req *http.Request) {
-	if h.config.BearerToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.config.BearerToken)
-	} else if h.config.APIKey != "" {
-		req.Header.Set("X-API-Key", h.config.APIKey)
-	} else if h.config.BasicAuthUser != "" && h.config.BasicAuthPass != "" {
-		req.SetBasicAuth(h.config.BasicAuthUser, h.config.BasicAuthPass)
-	}
+	setAuthHeaders(req, h.config)
 }
 
-// checkHealth verifies Prometheus connectivity
+// checkHealth verifies Prometheus connectivity, running the configured
+// sentinel query instead of hitting /-/healthy when one is set
 func (h *PrometheusHandler) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout(h.config))
+	defer cancel()
+
+	if h.config.PrometheusHealthQuery != "" {
+		return h.checkHealthQuery(ctx)
+	}
+
 	healthURL := fmt.Sprintf("%s/-/healthy", h.config.PrometheusURL)
 	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
 	if err != nil {
 		return err
 	}
 
+	setConfigHeaders(req, h.config.PrometheusHeaders, h.config.Headers)
 	h.addAuthHeaders(req)
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -258,68 +492,47 @@ func (h *PrometheusHandler) checkHealth(ctx context.Context) error {
 	return nil
 }
 
-// handlePrometheusResource handles resource calls for Prometheus
-func (d *Datasource) handlePrometheusResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	// Proxy the request to Prometheus
-	client := &http.Client{Timeout: 30 * time.Second}
-	
-	// Build URL
-	targetURL := d.config.PrometheusURL + req.Path
-	if len(req.URL) > 0 && req.URL != req.Path {
-		// Parse URL to extract query string if present
-		if parsedURL, err := url.Parse(req.URL); err == nil && parsedURL.RawQuery != "" {
-			targetURL += "?" + parsedURL.RawQuery
-		}
-	}
+// checkHealthQuery runs the sentinel health query, exercising auth and the
+// read path, and requires a successful result
+func (h *PrometheusHandler) checkHealthQuery(ctx context.Context) error {
+	params := url.Values{}
+	params.Set("query", h.config.PrometheusHealthQuery)
+	queryURL := fmt.Sprintf("%s/api/v1/query?%s", h.config.PrometheusURL, params.Encode())
 
-	var bodyReader io.Reader
-	if len(req.Body) > 0 {
-		bodyReader = bytes.NewReader(req.Body)
+	req, err := http.NewRequestWithContext(ctx, "GET", queryURL, nil)
+	if err != nil {
+		return err
 	}
 
-	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, targetURL, bodyReader)
+	setConfigHeaders(req, h.config.PrometheusHeaders, h.config.Headers)
+	h.addAuthHeaders(req)
+
+	resp, err := h.client.Do(req)
 	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to create request: %v"}`, err)),
-		})
+		return err
 	}
+	defer resp.Body.Close()
 
-	// Copy headers
-	for k, v := range req.Headers {
-		proxyReq.Header[k] = v
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("health query was rejected with status %d, check the authentication settings", resp.StatusCode)
 	}
-
-	// Add auth
-	if d.config.BearerToken != "" {
-		proxyReq.Header.Set("Authorization", "Bearer "+d.config.BearerToken)
-	} else if d.config.APIKey != "" {
-		proxyReq.Header.Set("X-API-Key", d.config.APIKey)
-	} else if d.config.BasicAuthUser != "" && d.config.BasicAuthPass != "" {
-		proxyReq.SetBasicAuth(d.config.BasicAuthUser, d.config.BasicAuthPass)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health query returned status %d", resp.StatusCode)
 	}
 
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Request failed: %v"}`, err)),
-		})
+	var result models.PrometheusQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse health query response: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to read response: %v"}`, err)),
-		})
+	if result.Status != "success" {
+		return fmt.Errorf("health query returned status %q", result.Status)
 	}
 
-	return sender.Send(&backend.CallResourceResponse{
-		Status: resp.StatusCode,
-		Headers: resp.Header,
-		Body:   body,
-	})
+	return nil
 }
 
+// handlePrometheusResource handles resource calls for Prometheus
+func (d *Datasource) handlePrometheusResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	// Proxy the request to Prometheus
+	return d.proxyResource(ctx, proxyTarget{URL: d.config.PrometheusURL + req.Path, Headers: d.config.PrometheusHeaders}, req, sender)
+}
@@ -0,0 +1,550 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestPrometheusQueryKindEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		queryKind  string
+		queryMode  string
+		emptyRange bool
+		wantPath   string
+	}{
+		{name: "explicit instant", queryKind: "instant", wantPath: "/api/v1/query"},
+		{name: "explicit range", queryKind: "range", emptyRange: true, wantPath: "/api/v1/query_range"},
+		{name: "legacy query mode", queryMode: "instant", wantPath: "/api/v1/query"},
+		{name: "absent kind over an empty range", emptyRange: true, wantPath: "/api/v1/query_range"},
+		{name: "absent kind", wantPath: "/api/v1/query_range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+			model := map[string]interface{}{"queryType": "prometheus", "promQL": "up"}
+			if tt.queryKind != "" {
+				model["queryKind"] = tt.queryKind
+			}
+			if tt.queryMode != "" {
+				model["queryMode"] = tt.queryMode
+			}
+			query := testQuery(t, "A", model)
+			if tt.emptyRange {
+				query.TimeRange.From = query.TimeRange.To
+			}
+
+			res := runQueries(t, ds, query).Responses["A"]
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if path != tt.wantPath {
+				t.Errorf("path = %s, want %s", path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestPrometheusUnitFromLabel(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"a","unit":"bytes"},"values":[[1704103200,"1"]]},
+		{"metric":{"job":"b","unit":"s"},"values":[[1704103200,"2"]]},
+		{"metric":{"job":"c"},"values":[[1704103200,"3"]]}
+	]}}`
+
+	tests := []struct {
+		name  string
+		model map[string]interface{}
+		want  map[string]string
+	}{
+		{
+			name:  "label with static fallback",
+			model: map[string]interface{}{"unitFromLabel": "unit", "unit": "short"},
+			want:  map[string]string{"a": "bytes", "b": "s", "c": "short"},
+		},
+		{
+			name:  "label without fallback",
+			model: map[string]interface{}{"unitFromLabel": "unit"},
+			want:  map[string]string{"a": "bytes", "b": "s", "c": ""},
+		},
+		{
+			name:  "static unit only",
+			model: map[string]interface{}{"unit": "short"},
+			want:  map[string]string{"a": "short", "b": "short", "c": "short"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, body)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+			model := map[string]interface{}{"queryType": "prometheus", "promQL": "up"}
+			for k, v := range tt.model {
+				model[k] = v
+			}
+			res := runQuery(t, ds, model)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			got := map[string]string{}
+			for _, frame := range res.Frames {
+				for _, field := range frame.Fields {
+					if field.Type().Time() {
+						continue
+					}
+					unit := ""
+					if field.Config != nil {
+						unit = field.Config.Unit
+					}
+					got[field.Labels["job"]] = unit
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("units = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// servePrometheus runs a Prometheus query against a server returning body
+func servePrometheus(t *testing.T, body string, model map[string]interface{}) backend.DataResponse {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+	query := map[string]interface{}{"queryType": "prometheus", "promQL": "up"}
+	for k, v := range model {
+		query[k] = v
+	}
+	return runQuery(t, ds, query)
+}
+
+func TestPrometheusPartialResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantFrames int
+		wantNotice string
+	}{
+		{
+			name:       "isPartial flag",
+			body:       `{"status":"success","isPartial":true,"data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1704103200,"1"]]}]}}`,
+			wantFrames: 1,
+			wantNotice: "Prometheus returned partial results; data may be incomplete",
+		},
+		{
+			name:       "warnings",
+			body:       `{"status":"success","warnings":["store eu-1 unavailable"],"data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1704103200,"1"]]}]}}`,
+			wantFrames: 1,
+			wantNotice: "data may be incomplete: store eu-1 unavailable",
+		},
+		{
+			name:       "partial with no series",
+			body:       `{"status":"success","isPartial":true,"data":{"resultType":"matrix","result":[]}}`,
+			wantFrames: 1,
+			wantNotice: "Prometheus returned partial results",
+		},
+		{
+			name:       "complete",
+			body:       `{"status":"success","infos":["query is slow"],"data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1704103200,"1"]]}]}}`,
+			wantFrames: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := servePrometheus(t, tt.body, nil)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Fatalf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+			for _, frame := range res.Frames {
+				if tt.wantNotice != "" && !hasNotice(frame, tt.wantNotice) {
+					t.Errorf("missing notice %q", tt.wantNotice)
+				}
+				if tt.wantNotice == "" && hasNotice(frame, "partial") {
+					t.Errorf("unexpected partial notice on a complete response")
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusResultTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantFrames int
+		wantValues []float64
+	}{
+		{
+			name:       "scalar on the range endpoint",
+			body:       `{"status":"success","data":{"resultType":"scalar","result":[1704103200,"42"]}}`,
+			wantFrames: 1,
+			wantValues: []float64{42},
+		},
+		{
+			name:       "null result",
+			body:       `{"status":"success","data":{"resultType":"vector","result":null}}`,
+			wantFrames: 0,
+		},
+		{
+			name:       "vector on the range endpoint",
+			body:       `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1704103200,"7"]}]}}`,
+			wantFrames: 1,
+			wantValues: []float64{7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := servePrometheus(t, tt.body, nil)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Fatalf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+			if tt.wantFrames == 0 {
+				return
+			}
+			value := res.Frames[0].Fields[1]
+			var got []float64
+			for i := 0; i < value.Len(); i++ {
+				v, _ := value.FloatAt(i)
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.wantValues) {
+				t.Errorf("values = %v, want %v", got, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestRateInnerSelector(t *testing.T) {
+	tests := []struct {
+		promQL string
+		want   string
+		wantOK bool
+	}{
+		{promQL: `rate(http_requests_total{job="api"}[5m])`, want: `http_requests_total{job="api"}`, wantOK: true},
+		{promQL: ` irate(errors_total[1m]) `, want: "errors_total", wantOK: true},
+		{promQL: `rate(http_requests_total[$__rate_interval])`, want: "http_requests_total", wantOK: true},
+		{promQL: `rate(a[5m]) / rate(b[5m])`},
+		{promQL: `sum(rate(a[5m]))`},
+		{promQL: `up`},
+		{promQL: `rate(a)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.promQL, func(t *testing.T) {
+			got, ok := rateInnerSelector(tt.promQL)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("rateInnerSelector() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPrometheusIncludeRaw(t *testing.T) {
+	const matrix = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[1704103200,"1"]]}]}}`
+
+	tests := []struct {
+		name        string
+		promQL      string
+		rawStatus   int
+		wantQueries []string
+		wantNames   []string
+		wantNotice  string
+	}{
+		{
+			name:        "rate expression",
+			promQL:      `rate(http_requests_total{job="api"}[5m])`,
+			wantQueries: []string{`rate(http_requests_total{job="api"}[5m])`, `http_requests_total{job="api"}`},
+			wantNames:   []string{"", "raw"},
+		},
+		{
+			name:        "not a rate expression",
+			promQL:      `up`,
+			wantQueries: []string{`up`},
+			wantNames:   []string{""},
+			wantNotice:  "Raw series are only available for rate(...) expressions",
+		},
+		{
+			name:        "raw query fails",
+			promQL:      `rate(http_requests_total[5m])`,
+			rawStatus:   http.StatusInternalServerError,
+			wantQueries: []string{`rate(http_requests_total[5m])`, `http_requests_total`},
+			wantNames:   []string{""},
+			wantNotice:  "Failed to fetch raw series",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queries = append(queries, r.FormValue("query"))
+				if len(queries) > 1 && tt.rawStatus != 0 {
+					w.WriteHeader(tt.rawStatus)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, matrix)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": tt.promQL, "includeRaw": true})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if !reflect.DeepEqual(queries, tt.wantQueries) {
+				t.Errorf("queries = %q, want %q", queries, tt.wantQueries)
+			}
+			var names []string
+			for _, frame := range res.Frames {
+				names = append(names, frame.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("frame names = %q, want %q", names, tt.wantNames)
+			}
+			if tt.wantNotice != "" && !hasNotice(res.Frames[0], tt.wantNotice) {
+				t.Errorf("missing notice %q", tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestApplyVisualizationConfig(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		model     models.QueryModel
+		wantMin   *float64
+		wantMax   *float64
+		wantSteps []data.Threshold
+	}{
+		{name: "nothing configured"},
+		{name: "min and max", model: models.QueryModel{Min: f(0), Max: f(100)}, wantMin: f(0), wantMax: f(100)},
+		{
+			name: "thresholds sorted after the base",
+			model: models.QueryModel{Thresholds: []models.ThresholdStep{
+				{Value: f(90), Color: "red"},
+				{Color: "blue"},
+				{Value: f(70), Color: "orange"},
+			}},
+			wantSteps: []data.Threshold{
+				data.NewThreshold(math.Inf(-1), "blue", ""),
+				data.NewThreshold(70, "orange", ""),
+				data.NewThreshold(90, "red", ""),
+			},
+		},
+		{
+			name:  "default base step",
+			model: models.QueryModel{Thresholds: []models.ThresholdStep{{Value: f(80), Color: "red"}}},
+			wantSteps: []data.Threshold{
+				data.NewThreshold(math.Inf(-1), "green", ""),
+				data.NewThreshold(80, "red", ""),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &data.FieldConfig{}
+			applyVisualizationConfig(config, &tt.model)
+
+			if !reflect.DeepEqual((*float64)(config.Min), tt.wantMin) || !reflect.DeepEqual((*float64)(config.Max), tt.wantMax) {
+				t.Errorf("min, max = %v, %v, want %v, %v", config.Min, config.Max, tt.wantMin, tt.wantMax)
+			}
+			if tt.wantSteps == nil {
+				if config.Thresholds != nil {
+					t.Errorf("thresholds = %+v, want none", config.Thresholds)
+				}
+				return
+			}
+			if config.Thresholds == nil || config.Thresholds.Mode != data.ThresholdsModeAbsolute {
+				t.Fatalf("thresholds = %+v, want absolute steps", config.Thresholds)
+			}
+			if !reflect.DeepEqual(config.Thresholds.Steps, tt.wantSteps) {
+				t.Errorf("steps = %+v, want %+v", config.Thresholds.Steps, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestPrometheusVisualizationConfig(t *testing.T) {
+	res := servePrometheus(t, prometheusMatrix(2), map[string]interface{}{
+		"min":        0,
+		"max":        1,
+		"thresholds": []map[string]interface{}{{"value": nil, "color": "green"}, {"value": 0.9, "color": "red"}},
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	for _, frame := range res.Frames {
+		config := frame.Fields[1].Config
+		if config == nil || config.Min == nil || *config.Min != 0 || config.Max == nil || *config.Max != 1 {
+			t.Fatalf("config = %+v, want min 0 and max 1", config)
+		}
+		if config.Thresholds == nil || len(config.Thresholds.Steps) != 2 {
+			t.Errorf("thresholds = %+v, want two steps", config.Thresholds)
+		}
+	}
+}
+
+func TestPrometheusPromoteLabels(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"api","instance":"a:9090"},"values":[[1704103200,"1"],[1704103260,"2"]]},
+		{"metric":{"job":"web"},"values":[[1704103200,"3"]]}
+	]}}`
+
+	res := servePrometheus(t, body, map[string]interface{}{"promoteLabels": []string{"job", "instance"}})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(res.Frames))
+	}
+
+	want := []map[string][]string{
+		{"job": {"api", "api"}, "instance": {"a:9090", "a:9090"}},
+		{"job": {"web"}, "instance": {""}},
+	}
+	for i, frame := range res.Frames {
+		if len(frame.Fields) != 4 {
+			t.Fatalf("frame %d has %d fields, want time, value and 2 labels", i, len(frame.Fields))
+		}
+		columns := frameColumns(frame)
+		for label, values := range want[i] {
+			if !reflect.DeepEqual(columns[label], values) {
+				t.Errorf("frame %d %s = %v, want %v", i, label, columns[label], values)
+			}
+		}
+		// Promoted labels stay on the value field too
+		if got := frame.Fields[1].Labels["job"]; got != want[i]["job"][0] {
+			t.Errorf("frame %d value label job = %q, want %q", i, got, want[i]["job"][0])
+		}
+	}
+}
+
+func TestPrometheusLegendFormat(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"__name__":"http_requests_total","job":"api","instance":"a:9090","code":"200"},"values":[[1704103200,"1"]]}
+	]}}`
+
+	tests := []struct {
+		name         string
+		legendFormat string
+		want         string
+	}{
+		{name: "default name", want: "http_requests_total"},
+		{name: "several labels", legendFormat: "{{job}} - {{instance}}", want: "api - a:9090"},
+		{name: "spaces inside braces", legendFormat: "{{ code }} on {{job}}", want: "200 on api"},
+		{name: "missing label renders empty", legendFormat: "{{job}}/{{pod}}", want: "api/"},
+		{name: "literal text", legendFormat: "requests", want: "requests"},
+		{name: "metric name", legendFormat: "{{__name__}}", want: "http_requests_total"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := servePrometheus(t, body, map[string]interface{}{"legendFormat": tt.legendFormat})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			if got := res.Frames[0].Fields[1].Config.DisplayNameFromDS; got != tt.want {
+				t.Errorf("display name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusInstantResultTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantFrames int
+		wantValues []float64
+	}{
+		{
+			name: "matrix from a subquery",
+			body: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"a"},"values":[[1704103200,"1"],[1704103260,"2"],[1704103320,"3"]]},
+				{"metric":{"job":"b"},"values":[[1704103200,"4"]]}
+			]}}`,
+			wantFrames: 2,
+			wantValues: []float64{1, 2, 3},
+		},
+		{
+			name:       "vector",
+			body:       `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1704103200,"5"]}]}}`,
+			wantFrames: 1,
+			wantValues: []float64{5},
+		},
+		{
+			name:       "scalar",
+			body:       `{"status":"success","data":{"resultType":"scalar","result":[1704103200,"6"]}}`,
+			wantFrames: 1,
+			wantValues: []float64{6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := servePrometheus(t, tt.body, map[string]interface{}{"queryKind": "instant", "promQL": "max_over_time(up[3m:1m])"})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Fatalf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+
+			value := res.Frames[0].Fields[1]
+			var got []float64
+			for i := 0; i < value.Len(); i++ {
+				v, _ := value.FloatAt(i)
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.wantValues) {
+				t.Errorf("values = %v, want %v", got, tt.wantValues)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// groupFramesByLabels merges single-series frames into one wide frame per
+// distinct combination of the given labels. Member series become value
+// fields joined on the union of their timestamps, with nulls where a series
+// has no sample, and their notices carry over to the group. Frames without a
+// value field are passed through ungrouped with a notice.
+func groupFramesByLabels(frames data.Frames, groupBy []string) data.Frames {
+	type group struct {
+		name    string
+		members []*data.Frame
+	}
+
+	var order []string
+	groups := make(map[string]*group)
+	var ungrouped data.Frames
+
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			ungrouped = append(ungrouped, frame)
+			continue
+		}
+		labels := frame.Fields[1].Labels
+
+		parts := make([]string, 0, len(groupBy))
+		for _, l := range groupBy {
+			parts = append(parts, l+"="+labels[l])
+		}
+		key := strings.Join(parts, ", ")
+
+		g, ok := groups[key]
+		if !ok {
+			g = &group{name: key}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.members = append(g.members, frame)
+	}
+
+	grouped := make(data.Frames, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+
+		// Union of timestamps across the member series
+		seen := make(map[int64]bool)
+		var times []time.Time
+		for _, member := range g.members {
+			for i := 0; i < member.Fields[0].Len(); i++ {
+				t, ok := member.Fields[0].At(i).(time.Time)
+				if ok && !seen[t.UnixNano()] {
+					seen[t.UnixNano()] = true
+					times = append(times, t)
+				}
+			}
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		index := make(map[int64]int, len(times))
+		for i, t := range times {
+			index[t.UnixNano()] = i
+		}
+
+		frame := data.NewFrame(g.name, data.NewField("time", nil, times))
+		for _, member := range g.members {
+			valueField := member.Fields[1]
+			values := make([]*float64, len(times))
+			for i := 0; i < member.Fields[0].Len(); i++ {
+				t, ok := member.Fields[0].At(i).(time.Time)
+				if !ok {
+					continue
+				}
+				if v, err := valueField.FloatAt(i); err == nil {
+					values[index[t.UnixNano()]] = &v
+				}
+			}
+
+			field := data.NewField(valueField.Name, valueField.Labels, values)
+			field.Config = valueField.Config
+			frame.Fields = append(frame.Fields, field)
+		}
+
+		frame.Meta = &data.FrameMeta{
+			Type:    data.FrameTypeTimeSeriesWide,
+			Notices: memberNotices(g.members),
+		}
+		grouped = append(grouped, frame)
+	}
+
+	if len(ungrouped) > 0 {
+		addNotice(ungrouped, data.NoticeSeverityWarning,
+			fmt.Sprintf("%d frames without a value field could not be grouped by %s", len(ungrouped), strings.Join(groupBy, ", ")))
+		grouped = append(grouped, ungrouped...)
+	}
+
+	return grouped
+}
+
+// memberNotices collects the distinct notices of a group's member frames
+func memberNotices(members []*data.Frame) []data.Notice {
+	var notices []data.Notice
+	seen := make(map[string]bool)
+	for _, member := range members {
+		if member.Meta == nil {
+			continue
+		}
+		for _, notice := range member.Meta.Notices {
+			key := fmt.Sprintf("%d|%s", notice.Severity, notice.Text)
+			if !seen[key] {
+				seen[key] = true
+				notices = append(notices, notice)
+			}
+		}
+	}
+	return notices
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// seriesFrame builds a single-series frame as the Prometheus conversion does
+func seriesFrame(labels data.Labels, values ...float64) *data.Frame {
+	times := make([]time.Time, len(values))
+	for i := range values {
+		times[i] = testTimeRange.From.Add(time.Duration(i) * time.Minute)
+	}
+	return data.NewFrame("", data.NewField("time", nil, times), data.NewField("value", labels, values))
+}
+
+func TestGroupFramesByLabels(t *testing.T) {
+	withNotice := seriesFrame(data.Labels{"dc": "eu", "host": "b"}, 3)
+	withNotice.Meta = &data.FrameMeta{Notices: []data.Notice{{Severity: data.NoticeSeverityWarning, Text: "partial data"}}}
+
+	tests := []struct {
+		name        string
+		frames      data.Frames
+		groupBy     []string
+		wantFrames  int
+		wantFields  []int
+		wantNotices []string
+	}{
+		{
+			name: "one frame per label value",
+			frames: data.Frames{
+				seriesFrame(data.Labels{"dc": "eu", "host": "a"}, 1, 2),
+				seriesFrame(data.Labels{"dc": "us", "host": "c"}, 4),
+				seriesFrame(data.Labels{"dc": "eu", "host": "b"}, 3),
+			},
+			groupBy:    []string{"dc"},
+			wantFrames: 2,
+			wantFields: []int{3, 2},
+		},
+		{
+			name: "member notices carried over",
+			frames: data.Frames{
+				seriesFrame(data.Labels{"dc": "eu", "host": "a"}, 1),
+				withNotice,
+			},
+			groupBy:     []string{"dc"},
+			wantFrames:  1,
+			wantFields:  []int{3},
+			wantNotices: []string{"partial data"},
+		},
+		{
+			name: "frames without values passed through",
+			frames: data.Frames{
+				seriesFrame(data.Labels{"dc": "eu"}, 1),
+				data.NewFrame("empty"),
+			},
+			groupBy:     []string{"dc"},
+			wantFrames:  2,
+			wantFields:  []int{2, 0},
+			wantNotices: []string{"", "could not be grouped"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grouped := groupFramesByLabels(tt.frames, tt.groupBy)
+			if len(grouped) != tt.wantFrames {
+				t.Fatalf("got %d frames, want %d", len(grouped), tt.wantFrames)
+			}
+			for i, frame := range grouped {
+				if len(frame.Fields) != tt.wantFields[i] {
+					t.Errorf("frame %d has %d fields, want %d", i, len(frame.Fields), tt.wantFields[i])
+				}
+				if i < len(tt.wantNotices) && tt.wantNotices[i] != "" && !hasNotice(frame, tt.wantNotices[i]) {
+					t.Errorf("frame %d lacks notice %q", i, tt.wantNotices[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGroupFramesByLabelsAlignsTimestamps(t *testing.T) {
+	a := seriesFrame(data.Labels{"dc": "eu", "host": "a"}, 1, 2)
+	b := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{testTimeRange.From.Add(time.Minute)}),
+		data.NewField("value", data.Labels{"dc": "eu", "host": "b"}, []float64{5}))
+
+	frame := groupFramesByLabels(data.Frames{a, b}, []string{"dc"})[0]
+	if frame.Fields[0].Len() != 2 {
+		t.Fatalf("got %d timestamps, want 2", frame.Fields[0].Len())
+	}
+	if v := frame.Fields[2].At(0).(*float64); v != nil {
+		t.Errorf("host b at the first timestamp = %v, want null", *v)
+	}
+	if v := frame.Fields[2].At(1).(*float64); v == nil || *v != 5 {
+		t.Errorf("host b at the second timestamp = %v, want 5", v)
+	}
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameTypeHeatmapRows is Grafana's heatmap frame type: a time field
+// followed by one numeric field per bucket, ordered by bucket bound
+const frameTypeHeatmapRows data.FrameType = "heatmap-rows"
+
+// toHeatmapFrames arranges le-bucketed histogram series into heatmap frames,
+// one per distinct combination of the remaining labels. Bucket fields are
+// named after their upper bound and sorted numerically, with +Inf last.
+// Prometheus buckets are cumulative, so each cell is de-accumulated to the
+// count of its own bucket. Series without an le label are dropped.
+func toHeatmapFrames(frames data.Frames) data.Frames {
+	var order []string
+	groups := make(map[string]data.Frames)
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		labels := frame.Fields[1].Labels
+		if _, ok := labels["le"]; !ok {
+			continue
+		}
+
+		rest := data.Labels{}
+		for k, v := range labels {
+			if k != "le" {
+				rest[k] = v
+			}
+		}
+		key := rest.String()
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], frame)
+	}
+
+	heatmaps := make(data.Frames, 0, len(order))
+	for _, key := range order {
+		wide := groupFramesByLabels(groups[key], nil)[0]
+
+		buckets := wide.Fields[1:]
+		bounds := make([]float64, len(buckets))
+		for i, f := range buckets {
+			bounds[i] = parseBucketBound(f.Labels["le"])
+		}
+		sort.Sort(bucketsByBound{fields: buckets, bounds: bounds})
+		deaccumulateBuckets(buckets)
+
+		for _, f := range buckets {
+			f.Name = f.Labels["le"]
+			f.Labels = nil
+			f.Config = nil
+		}
+
+		wide.Name = key
+		wide.Meta = &data.FrameMeta{
+			Type:    frameTypeHeatmapRows,
+			Notices: wide.Meta.Notices,
+		}
+		heatmaps = append(heatmaps, wide)
+	}
+
+	return heatmaps
+}
+
+// deaccumulateBuckets turns cumulative bucket counts, sorted by bound, into
+// per-bucket counts by subtracting each bucket's lower neighbour. Negative
+// differences, from buckets scraped at slightly different moments, are
+// clamped to zero; cells next to a missing sample are left as they are.
+func deaccumulateBuckets(buckets []*data.Field) {
+	for i := len(buckets) - 1; i > 0; i-- {
+		upper, lower := buckets[i], buckets[i-1]
+		for row := 0; row < upper.Len(); row++ {
+			u, ok := upper.At(row).(*float64)
+			if !ok || u == nil {
+				continue
+			}
+			l, ok := lower.At(row).(*float64)
+			if !ok || l == nil {
+				continue
+			}
+			diff := math.Max(*u-*l, 0)
+			upper.Set(row, &diff)
+		}
+	}
+}
+
+// parseBucketBound parses an le label value, sorting unparsable bounds last
+func parseBucketBound(le string) float64 {
+	v, err := strconv.ParseFloat(le, 64)
+	if err != nil {
+		return math.Inf(1)
+	}
+	return v
+}
+
+// bucketsByBound sorts bucket fields by their parsed upper bound
+type bucketsByBound struct {
+	fields []*data.Field
+	bounds []float64
+}
+
+func (b bucketsByBound) Len() int           { return len(b.fields) }
+func (b bucketsByBound) Less(i, j int) bool { return b.bounds[i] < b.bounds[j] }
+func (b bucketsByBound) Swap(i, j int) {
+	b.fields[i], b.fields[j] = b.fields[j], b.fields[i]
+	b.bounds[i], b.bounds[j] = b.bounds[j], b.bounds[i]
+}
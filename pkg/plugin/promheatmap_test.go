@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestToHeatmapFrames(t *testing.T) {
+	bucket := func(le string, counts ...float64) *data.Frame {
+		return seriesFrame(data.Labels{"le": le, "job": "api"}, counts...)
+	}
+
+	tests := []struct {
+		name       string
+		frames     data.Frames
+		wantFields []string
+		wantRows   [][]float64
+	}{
+		{
+			name: "buckets sorted and de-accumulated",
+			frames: data.Frames{
+				bucket("1", 9, 12),
+				bucket("+Inf", 10, 15),
+				bucket("0.1", 2, 2),
+				bucket("0.5", 5, 6),
+			},
+			wantFields: []string{"0.1", "0.5", "1", "+Inf"},
+			wantRows:   [][]float64{{2, 3, 4, 1}, {2, 4, 6, 3}},
+		},
+		{
+			name: "negative differences clamped",
+			frames: data.Frames{
+				bucket("1", 4),
+				bucket("2", 3),
+			},
+			wantFields: []string{"1", "2"},
+			wantRows:   [][]float64{{4, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heatmaps := toHeatmapFrames(tt.frames)
+			if len(heatmaps) != 1 {
+				t.Fatalf("got %d heatmap frames, want 1", len(heatmaps))
+			}
+			frame := heatmaps[0]
+			if frame.Meta == nil || frame.Meta.Type != frameTypeHeatmapRows {
+				t.Errorf("frame type = %v", frame.Meta)
+			}
+
+			var names []string
+			for _, f := range frame.Fields[1:] {
+				names = append(names, f.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantFields) {
+				t.Errorf("bucket fields = %v, want %v", names, tt.wantFields)
+			}
+
+			for row, want := range tt.wantRows {
+				got := make([]float64, 0, len(want))
+				for _, f := range frame.Fields[1:] {
+					v, _ := f.ConcreteAt(row)
+					got = append(got, v.(float64))
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("row %d = %v, want %v", row, got, want)
+				}
+			}
+		})
+	}
+}
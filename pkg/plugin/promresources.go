@@ -0,0 +1,259 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricNamesTTL is how long the metric name list is reused, so editor
+// autocompletion doesn't query Prometheus on every keystroke
+const metricNamesTTL = 30 * time.Second
+
+// metricNameCache keeps the last metric name list for a short while
+type metricNameCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	names   []string
+}
+
+// get returns the cached names, refreshing them with fetch once stale.
+// Failed refreshes are not cached.
+func (c *metricNameCache) get(fetch func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.names == nil || time.Since(c.fetched) > metricNamesTTL {
+		names, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.names = names
+		c.fetched = time.Now()
+	}
+	return c.names, nil
+}
+
+// resourceQuery returns the query string parameters of a resource call
+func resourceQuery(req *backend.CallResourceRequest) url.Values {
+	parsedURL, err := url.Parse(req.URL)
+	if err != nil {
+		return url.Values{}
+	}
+	return parsedURL.Query()
+}
+
+// prometheusAPIData calls a Prometheus API endpoint and returns the data of
+// a successful envelope
+func (d *Datasource) prometheusAPIData(ctx context.Context, path string, params url.Values) (json.RawMessage, error) {
+	if d.config.PrometheusURL == "" {
+		return nil, fmt.Errorf("Prometheus URL not configured")
+	}
+	return d.fetchAPIData(ctx, "Prometheus", d.config.PrometheusURL+path, params, d.config.PrometheusHeaders)
+}
+
+// fetchAPIData GETs a Prometheus-style API endpoint with the source's
+// headers and the configured authentication and returns the data of a
+// successful {"status", "data"} envelope
+func (d *Datasource) fetchAPIData(ctx context.Context, source, apiURL string, params url.Values, headers map[string]string) (json.RawMessage, error) {
+	if len(params) > 0 {
+		apiURL += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	setConfigHeaders(req, headers, d.config.Headers)
+	setAuthHeaders(req, d.config)
+
+	resp, err := doWithRetry(ctx, d.client, req, newRetryPolicy(d.config), d.logger)
+	if err != nil {
+		return nil, fmt.Errorf("%s is unreachable: %w", source, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readResponseBody(resp, d.config.MaxResponseBytes, d.logger)
+		return nil, fmt.Errorf("%s returned status %d: %s", source, resp.StatusCode, bodySnippet(body))
+	}
+
+	var envelope struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", source, err)
+	}
+	if envelope.Status != "success" {
+		return nil, fmt.Errorf("%s request failed: %s", source, envelope.Status)
+	}
+
+	return envelope.Data, nil
+}
+
+// sendAPIError reports a failed backend API call as a bad gateway, keeping
+// the cancellation and timeout statuses of the proxy
+func (d *Datasource) sendAPIError(ctx context.Context, sender backend.CallResourceResponseSender, err error) error {
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return d.sendProxyError(ctx, sender, "Request failed", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return sender.Send(&backend.CallResourceResponse{
+		Status: http.StatusBadGateway,
+		Body:   body,
+	})
+}
+
+// sendResourceJSON sends a successful JSON resource response
+func sendResourceJSON(sender backend.CallResourceResponseSender, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 500,
+			Body:   []byte(fmt.Sprintf(`{"error": "%v"}`, err)),
+		})
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  200,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+}
+
+// handleLabelValuesResource lists the values of a label for template
+// variables. It takes label plus optional match[], start and end parameters
+// and returns {"values": [...]} without the Prometheus envelope.
+func (d *Datasource) handleLabelValuesResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	query := resourceQuery(req)
+	label := query.Get("label")
+	if !labelNamePattern.MatchString(label) {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 400,
+			Body:   []byte(`{"error": "A valid label parameter is required"}`),
+		})
+	}
+
+	params := url.Values{}
+	for _, key := range []string{"match[]", "start", "end"} {
+		for _, v := range query[key] {
+			params.Add(key, v)
+		}
+	}
+
+	raw, err := d.prometheusAPIData(ctx, fmt.Sprintf("/api/v1/label/%s/values", label), params)
+	if err != nil {
+		return d.sendAPIError(ctx, sender, err)
+	}
+
+	values := []string{}
+	if err := json.Unmarshal(raw, &values); err != nil || values == nil {
+		values = []string{}
+	}
+
+	return sendResourceJSON(sender, map[string]interface{}{"values": values})
+}
+
+// handleMetricNamesResource returns the sorted metric names for editor
+// autocompletion, capped by the optional limit parameter
+func (d *Datasource) handleMetricNamesResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	limit := 0
+	if v := resourceQuery(req).Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: 400,
+				Body:   []byte(`{"error": "limit must be a non-negative integer"}`),
+			})
+		}
+		limit = n
+	}
+
+	names, err := d.metricNames.get(func() ([]string, error) {
+		raw, err := d.prometheusAPIData(ctx, "/api/v1/label/__name__/values", nil)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		if err := json.Unmarshal(raw, &names); err != nil {
+			return nil, fmt.Errorf("failed to parse metric names: %w", err)
+		}
+		sort.Strings(names)
+		return names, nil
+	})
+	if err != nil {
+		return d.sendAPIError(ctx, sender, err)
+	}
+
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+
+	return sendResourceJSON(sender, map[string]interface{}{"metrics": names})
+}
+
+// handleSeriesResource returns the label sets of the series matching the
+// match[] selectors within the optional start and end
+func (d *Datasource) handleSeriesResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	query := resourceQuery(req)
+	if len(query["match[]"]) == 0 {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 400,
+			Body:   []byte(`{"error": "At least one match[] selector is required"}`),
+		})
+	}
+
+	params := url.Values{}
+	for _, key := range []string{"match[]", "start", "end"} {
+		for _, v := range query[key] {
+			params.Add(key, v)
+		}
+	}
+
+	return d.sendPrometheusAPIData(ctx, sender, "/api/v1/series", params)
+}
+
+// handleMetadataResource returns metric type, help and unit metadata,
+// optionally for a single metric and capped by limit
+func (d *Datasource) handleMetadataResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	query := resourceQuery(req)
+
+	params := url.Values{}
+	for _, key := range []string{"metric", "limit"} {
+		if v := query.Get(key); v != "" {
+			params.Set(key, v)
+		}
+	}
+
+	return d.sendPrometheusAPIData(ctx, sender, "/api/v1/metadata", params)
+}
+
+// sendPrometheusAPIData sends the data payload of a Prometheus API call
+// unchanged
+func (d *Datasource) sendPrometheusAPIData(ctx context.Context, sender backend.CallResourceResponseSender, path string, params url.Values) error {
+	raw, err := d.prometheusAPIData(ctx, path, params)
+	if err != nil {
+		return d.sendAPIError(ctx, sender, err)
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  200,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    raw,
+	})
+}
@@ -0,0 +1,240 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// apiRequest is what a stub Prometheus API received
+type apiRequest struct {
+	path   string
+	params url.Values
+	auth   string
+}
+
+// stubPrometheusAPI answers every API call with the envelope body and
+// records the requests
+func stubPrometheusAPI(t *testing.T, status int, body string) (*httptest.Server, *[]apiRequest) {
+	var requests []apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, apiRequest{r.URL.Path, r.URL.Query(), r.Header.Get("Authorization")})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestLabelValuesResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		status     int
+		body       string
+		wantStatus int
+		wantBody   string
+		wantPath   string
+		wantParams url.Values
+	}{
+		{
+			name:       "values",
+			url:        "prometheus/label_values?label=job&match[]=up&match[]=node_load1&start=1704103200&end=1704106800&ignored=1",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":["api","web"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"values":["api","web"]}`,
+			wantPath:   "/api/v1/label/job/values",
+			wantParams: url.Values{"match[]": {"up", "node_load1"}, "start": {"1704103200"}, "end": {"1704106800"}},
+		},
+		{
+			name:       "no values",
+			url:        "prometheus/label_values?label=job",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":null}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"values":[]}`,
+			wantPath:   "/api/v1/label/job/values",
+			wantParams: url.Values{},
+		},
+		{
+			name:       "missing label",
+			url:        "prometheus/label_values",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error": "A valid label parameter is required"}`,
+		},
+		{
+			name:       "label path traversal",
+			url:        "prometheus/label_values?label=" + url.QueryEscape("../../admin"),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error": "A valid label parameter is required"}`,
+		},
+		{
+			name:       "backend error",
+			url:        "prometheus/label_values?label=job",
+			status:     http.StatusUnprocessableEntity,
+			body:       `{"status":"error","error":"bad matcher"}`,
+			wantStatus: http.StatusBadGateway,
+			wantBody:   `{"error":"Prometheus returned status 422: {\"status\":\"error\",\"error\":\"bad matcher\"}"}`,
+			wantPath:   "/api/v1/label/job/values",
+			wantParams: url.Values{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := stubPrometheusAPI(t, tt.status, tt.body)
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL, "authMode": "bearer"}, map[string]string{"bearerToken": "secret"})
+
+			resp := callResource(t, ds, &backend.CallResourceRequest{Path: "prometheus/label_values", URL: tt.url, Method: "GET"}).responses[0]
+			if resp.Status != tt.wantStatus || string(resp.Body) != tt.wantBody {
+				t.Errorf("response = %d %s, want %d %s", resp.Status, resp.Body, tt.wantStatus, tt.wantBody)
+			}
+
+			if tt.wantPath == "" {
+				if len(*requests) != 0 {
+					t.Errorf("requests = %v, want none", *requests)
+				}
+				return
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			got := (*requests)[0]
+			if got.path != tt.wantPath || !reflect.DeepEqual(got.params, tt.wantParams) || got.auth != "Bearer secret" {
+				t.Errorf("request = %+v, want %s %v with auth", got, tt.wantPath, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestMetricNamesResource(t *testing.T) {
+	srv, requests := stubPrometheusAPI(t, http.StatusOK, `{"status":"success","data":["up","go_goroutines","node_load1"]}`)
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{url: "prometheus/metrics", wantStatus: http.StatusOK, wantBody: `{"metrics":["go_goroutines","node_load1","up"]}`},
+		{url: "prometheus/metrics?limit=2", wantStatus: http.StatusOK, wantBody: `{"metrics":["go_goroutines","node_load1"]}`},
+		{url: "prometheus/metrics?limit=0", wantStatus: http.StatusOK, wantBody: `{"metrics":["go_goroutines","node_load1","up"]}`},
+		{url: "prometheus/metrics?limit=-1", wantStatus: http.StatusBadRequest, wantBody: `{"error": "limit must be a non-negative integer"}`},
+		{url: "prometheus/metrics?limit=all", wantStatus: http.StatusBadRequest, wantBody: `{"error": "limit must be a non-negative integer"}`},
+	}
+	for _, tt := range tests {
+		resp := callResource(t, ds, &backend.CallResourceRequest{Path: "prometheus/metrics", URL: tt.url, Method: "GET"}).responses[0]
+		if resp.Status != tt.wantStatus || string(resp.Body) != tt.wantBody {
+			t.Errorf("%s = %d %s, want %d %s", tt.url, resp.Status, resp.Body, tt.wantStatus, tt.wantBody)
+		}
+	}
+
+	// Every call within the TTL reuses the first lookup
+	if len(*requests) != 1 || (*requests)[0].path != "/api/v1/label/__name__/values" {
+		t.Errorf("requests = %v, want one metric name lookup", *requests)
+	}
+}
+
+func TestMetricNamesResourceUnreachable(t *testing.T) {
+	srv, _ := stubPrometheusAPI(t, http.StatusOK, `{"status":"success","data":["up"]}`)
+	srv.Close()
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+
+	resp := callResource(t, ds, &backend.CallResourceRequest{Path: "prometheus/metrics", URL: "prometheus/metrics", Method: "GET"}).responses[0]
+	if resp.Status != http.StatusBadGateway || !strings.Contains(string(resp.Body), "Prometheus is unreachable") {
+		t.Errorf("response = %d %s, want 502 naming Prometheus as unreachable", resp.Status, resp.Body)
+	}
+
+	// Failures aren't cached
+	if ds.metricNames.names != nil {
+		t.Errorf("cached names = %v after a failure", ds.metricNames.names)
+	}
+}
+
+func TestSeriesAndMetadataResources(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		url        string
+		status     int
+		body       string
+		wantStatus int
+		wantBody   string
+		wantPath   string
+		wantParams url.Values
+	}{
+		{
+			name:       "series",
+			path:       "prometheus/series",
+			url:        "prometheus/series?match[]=up&match[]=node_load1&start=1704103200&end=1704106800&limit=5",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":[{"__name__":"up","job":"api"}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"__name__":"up","job":"api"}]`,
+			wantPath:   "/api/v1/series",
+			wantParams: url.Values{"match[]": {"up", "node_load1"}, "start": {"1704103200"}, "end": {"1704106800"}},
+		},
+		{
+			name:       "series without selector",
+			path:       "prometheus/series",
+			url:        "prometheus/series?start=1704103200",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error": "At least one match[] selector is required"}`,
+		},
+		{
+			name:       "metadata",
+			path:       "prometheus/metadata",
+			url:        "prometheus/metadata?metric=up&limit=1&match[]=ignored",
+			status:     http.StatusOK,
+			body:       `{"status":"success","data":{"up":[{"type":"gauge","help":"Target is up","unit":""}]}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"up":[{"type":"gauge","help":"Target is up","unit":""}]}`,
+			wantPath:   "/api/v1/metadata",
+			wantParams: url.Values{"metric": {"up"}, "limit": {"1"}},
+		},
+		{
+			name:       "failed envelope",
+			path:       "prometheus/metadata",
+			url:        "prometheus/metadata",
+			status:     http.StatusOK,
+			body:       `{"status":"error","data":null}`,
+			wantStatus: http.StatusBadGateway,
+			wantBody:   `{"error":"Prometheus request failed: error"}`,
+			wantPath:   "/api/v1/metadata",
+			wantParams: url.Values{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := stubPrometheusAPI(t, tt.status, tt.body)
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+
+			resp := callResource(t, ds, &backend.CallResourceRequest{Path: tt.path, URL: tt.url, Method: "GET"}).responses[0]
+			if resp.Status != tt.wantStatus || string(resp.Body) != tt.wantBody {
+				t.Errorf("response = %d %s, want %d %s", resp.Status, resp.Body, tt.wantStatus, tt.wantBody)
+			}
+
+			if tt.wantPath == "" {
+				if len(*requests) != 0 {
+					t.Errorf("requests = %v, want none", *requests)
+				}
+				return
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			if got := (*requests)[0]; got.path != tt.wantPath || !reflect.DeepEqual(got.params, tt.wantParams) {
+				t.Errorf("request = %+v, want %s %v", got, tt.wantPath, tt.wantParams)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// normalizeSamples makes a series' timestamps monotonic according to the
+// policy and reports whether anything had to be corrected. Every policy
+// sorts by time; dedupLast keeps the last sample of duplicate timestamps,
+// dropDuplicates keeps the first and sort keeps them all.
+func normalizeSamples(times []time.Time, values []float64, policy models.TimestampPolicy) ([]time.Time, []float64, bool) {
+	ordered, unique := true, true
+	for i := 1; i < len(times); i++ {
+		if times[i].Before(times[i-1]) {
+			ordered = false
+		} else if times[i].Equal(times[i-1]) {
+			unique = false
+		}
+	}
+	if ordered && (unique || policy == models.TimestampPolicySort) {
+		return times, values, false
+	}
+
+	idx := make([]int, len(times))
+	for i := range idx {
+		idx[i] = i
+	}
+	// Stable sort keeps duplicates in response order for the dedup policies
+	sort.SliceStable(idx, func(a, b int) bool { return times[idx[a]].Before(times[idx[b]]) })
+
+	outTimes := make([]time.Time, 0, len(times))
+	outValues := make([]float64, 0, len(values))
+	for _, i := range idx {
+		n := len(outTimes)
+		if n > 0 && outTimes[n-1].Equal(times[i]) && policy != models.TimestampPolicySort {
+			if policy == models.TimestampPolicyDropDuplicates {
+				continue
+			}
+			outValues[n-1] = values[i]
+			continue
+		}
+		outTimes = append(outTimes, times[i])
+		outValues = append(outValues, values[i])
+	}
+
+	return outTimes, outValues, true
+}
+
+// parseSampleValue parses a sample value, which Prometheus encodes as a
+// string so that NaN, +Inf and -Inf survive JSON. Every NaN, including the
+// staleness marker's special bit pattern, becomes a plain NaN, and an empty
+// value is treated as a gap rather than failing the query.
+func parseSampleValue(raw interface{}) (float64, error) {
+	var v float64
+	switch value := raw.(type) {
+	case string:
+		s := strings.TrimSpace(value)
+		if s == "" {
+			return math.NaN(), nil
+		}
+		parsed, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse value: %w", err)
+		}
+		v = parsed
+	case float64:
+		v = value
+	case nil:
+		return math.NaN(), nil
+	default:
+		return 0, fmt.Errorf("invalid value format")
+	}
+
+	if math.IsNaN(v) {
+		return math.NaN(), nil
+	}
+	return v, nil
+}
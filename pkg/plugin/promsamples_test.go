@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+func TestNormalizeSamples(t *testing.T) {
+	// Samples at t=3, 1, 2, 1 with the duplicate second sample last
+	times := []time.Time{time.Unix(3, 0), time.Unix(1, 0), time.Unix(2, 0), time.Unix(1, 0)}
+	values := []float64{30, 10, 20, 11}
+
+	tests := []struct {
+		name          string
+		times         []time.Time
+		values        []float64
+		policy        models.TimestampPolicy
+		wantTimes     []int64
+		wantValues    []float64
+		wantCorrected bool
+	}{
+		{name: "default keeps the last duplicate", times: times, values: values, wantTimes: []int64{1, 2, 3}, wantValues: []float64{11, 20, 30}, wantCorrected: true},
+		{name: "dedupLast", times: times, values: values, policy: models.TimestampPolicyDedupLast, wantTimes: []int64{1, 2, 3}, wantValues: []float64{11, 20, 30}, wantCorrected: true},
+		{name: "dropDuplicates keeps the first", times: times, values: values, policy: models.TimestampPolicyDropDuplicates, wantTimes: []int64{1, 2, 3}, wantValues: []float64{10, 20, 30}, wantCorrected: true},
+		{name: "sort keeps duplicates in order", times: times, values: values, policy: models.TimestampPolicySort, wantTimes: []int64{1, 1, 2, 3}, wantValues: []float64{10, 11, 20, 30}, wantCorrected: true},
+		{name: "ordered duplicates under sort", times: []time.Time{time.Unix(1, 0), time.Unix(1, 0)}, values: []float64{1, 2}, policy: models.TimestampPolicySort, wantTimes: []int64{1, 1}, wantValues: []float64{1, 2}},
+		{name: "already monotonic", times: []time.Time{time.Unix(1, 0), time.Unix(2, 0)}, values: []float64{1, 2}, wantTimes: []int64{1, 2}, wantValues: []float64{1, 2}},
+		{name: "empty", wantTimes: []int64{}, wantValues: []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTimes, gotValues, corrected := normalizeSamples(tt.times, tt.values, tt.policy)
+
+			secs := make([]int64, len(gotTimes))
+			for i, ts := range gotTimes {
+				secs[i] = ts.Unix()
+			}
+			if !reflect.DeepEqual(secs, tt.wantTimes) {
+				t.Errorf("times = %v, want %v", secs, tt.wantTimes)
+			}
+			if len(gotValues) != len(tt.wantValues) || (len(gotValues) > 0 && !reflect.DeepEqual(gotValues, tt.wantValues)) {
+				t.Errorf("values = %v, want %v", gotValues, tt.wantValues)
+			}
+			if corrected != tt.wantCorrected {
+				t.Errorf("corrected = %v, want %v", corrected, tt.wantCorrected)
+			}
+		})
+	}
+}
+
+func TestPrometheusTimestampCorrection(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"a"},"values":[[1704103260,"2"],[1704103200,"1"],[1704103260,"3"]]},
+		{"metric":{"job":"b"},"values":[[1704103200,"1"],[1704103260,"2"]]}
+	]}}`
+
+	res := servePrometheus(t, body, map[string]interface{}{"timestampPolicy": "dedupLast"})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(res.Frames))
+	}
+
+	frame := res.Frames[0]
+	if frame.Fields[0].Len() != 2 {
+		t.Fatalf("got %d samples, want 2", frame.Fields[0].Len())
+	}
+	for i, want := range []struct {
+		ts    int64
+		value float64
+	}{{1704103200, 1}, {1704103260, 3}} {
+		ts := frame.Fields[0].At(i).(time.Time)
+		v := frame.Fields[1].At(i).(float64)
+		if ts.Unix() != want.ts || v != want.value || math.IsNaN(v) {
+			t.Errorf("sample %d = %d %v, want %d %v", i, ts.Unix(), v, want.ts, want.value)
+		}
+	}
+
+	if !hasNotice(frame, "Corrected out-of-order or duplicate timestamps in 1 series") {
+		t.Errorf("notices = %+v, want a correction notice for 1 series", frame.Meta)
+	}
+}
+
+func TestParseSampleValue(t *testing.T) {
+	// Prometheus' staleness marker is a NaN with a special bit pattern
+	staleMarker := math.Float64frombits(0x7ff0000000000002)
+
+	tests := []struct {
+		name    string
+		raw     interface{}
+		want    float64
+		wantErr string
+	}{
+		{name: "number", raw: "1.5", want: 1.5},
+		{name: "NaN", raw: "NaN", want: math.NaN()},
+		{name: "positive infinity", raw: "+Inf", want: math.Inf(1)},
+		{name: "negative infinity", raw: "-Inf", want: math.Inf(-1)},
+		{name: "empty", raw: "", want: math.NaN()},
+		{name: "whitespace", raw: "  ", want: math.NaN()},
+		{name: "null", raw: nil, want: math.NaN()},
+		{name: "JSON number", raw: float64(3), want: 3},
+		{name: "staleness marker", raw: staleMarker, want: math.NaN()},
+		{name: "garbage", raw: "abc", wantErr: "failed to parse value"},
+		{name: "wrong type", raw: []interface{}{}, wantErr: "invalid value format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSampleValue(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !sameFloat(got, tt.want) {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+			if math.IsNaN(got) && math.Float64bits(got) != math.Float64bits(math.NaN()) {
+				t.Errorf("NaN bits = %x, want a plain NaN", math.Float64bits(got))
+			}
+		})
+	}
+}
+
+func TestPrometheusSpecialSampleValues(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"a"},"values":[[1704103200,"1"],[1704103260,"NaN"],[1704103320,"+Inf"],[1704103380,"-Inf"],[1704103440,""]]}
+	]}}`
+
+	res := servePrometheus(t, body, nil)
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+
+	want := []float64{1, math.NaN(), math.Inf(1), math.Inf(-1), math.NaN()}
+	value := res.Frames[0].Fields[1]
+	if value.Len() != len(want) {
+		t.Fatalf("got %d samples, want %d", value.Len(), len(want))
+	}
+	for i, w := range want {
+		if got := value.At(i).(float64); !sameFloat(got, w) {
+			t.Errorf("sample %d = %v, want %v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// executeSeriesQuery lists the series matching any of the query's selectors
+// via /api/v1/series and returns them as a single de-duplicated table frame
+func (h *PrometheusHandler) executeSeriesQuery(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
+	params := url.Values{}
+	for _, matcher := range queryModel.SeriesMatchers {
+		params.Add("match[]", interpolateQueryVariables(matcher, queryModel.Variables))
+	}
+	params.Set("start", strconv.FormatInt(query.TimeRange.From.Unix(), 10))
+	params.Set("end", strconv.FormatInt(query.TimeRange.To.Unix(), 10))
+
+	seriesURL := fmt.Sprintf("%s/api/v1/series", h.config.PrometheusURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", seriesURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return backend.DataResponse{
+			Error: fmt.Errorf("failed to create request: %w", err),
+		}
+	}
+
+	setConfigHeaders(req, h.config.PrometheusHeaders, h.config.Headers)
+	h.addAuthHeaders(req)
+
+	resp, err := doWithRetry(ctx, h.client, req, newRetryPolicy(h.config), h.logger)
+	if err != nil {
+		return requestErrorResponse(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+		return statusErrorResponse(resp.StatusCode, fmt.Errorf("Prometheus API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	body, err := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+	if err != nil {
+		return backend.DataResponse{
+			Error: err,
+		}
+	}
+
+	var seriesResp models.PrometheusSeriesResponse
+	if err := json.Unmarshal(body, &seriesResp); err != nil {
+		return backend.DataResponse{
+			Error: fmt.Errorf("failed to parse response: %w", err),
+		}
+	}
+
+	if seriesResp.Status != "success" {
+		return backend.DataResponse{
+			Error: fmt.Errorf("Prometheus series query failed: %s", seriesResp.Error),
+		}
+	}
+
+	return backend.DataResponse{
+		Frames: data.Frames{seriesToTableFrame(seriesResp.Data)},
+	}
+}
+
+// seriesToTableFrame builds a table with one row per unique label set and
+// one string column per label name
+func seriesToTableFrame(series []map[string]string) *data.Frame {
+	seen := make(map[string]bool)
+	columnSet := make(map[string]bool)
+	var unique []map[string]string
+
+	for _, labels := range series {
+		key := labelSetKey(labels)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, labels)
+		for name := range labels {
+			columnSet[name] = true
+		}
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for name := range columnSet {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	frame := data.NewFrame("series")
+	for _, name := range columns {
+		values := make([]string, len(unique))
+		for i, labels := range unique {
+			values[i] = labels[name]
+		}
+		frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+	}
+	frame.Meta = &data.FrameMeta{
+		Type: data.FrameTypeTable,
+	}
+
+	return frame
+}
+
+// labelSetKey returns a canonical string for a label set
+func labelSetKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPrometheusSeriesQuery(t *testing.T) {
+	var params url.Values
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, params = r.URL.Path, r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		// Series matching several selectors come back once per selector
+		fmt.Fprint(w, `{"status":"success","data":[
+			{"__name__":"up","job":"api","instance":"a:9090"},
+			{"__name__":"up","job":"web"},
+			{"__name__":"up","instance":"a:9090","job":"api"}
+		]}`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{
+		"queryType":      "prometheus",
+		"seriesMatchers": []string{`up{job="${job}"}`, `up{job="web"}`},
+		"variables":      map[string]string{"job": "api"},
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	if path != "/api/v1/series" {
+		t.Errorf("path = %s, want /api/v1/series", path)
+	}
+	if got, want := params["match[]"], []string{`up{job="api"}`, `up{job="web"}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("match[] = %q, want %q", got, want)
+	}
+	if params.Get("start") != "1704103200" || params.Get("end") != "1704106800" {
+		t.Errorf("start, end = %s, %s, want the query range in seconds", params.Get("start"), params.Get("end"))
+	}
+
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+	want := map[string][]string{
+		"__name__": {"up", "up"},
+		"instance": {"a:9090", ""},
+		"job":      {"api", "web"},
+	}
+	if got := frameColumns(res.Frames[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+}
+
+func TestPrometheusSeriesQueryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"error","error":"parse error: unexpected character"}`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "seriesMatchers": []string{`up{`}})
+	if res.Error == nil || res.Error.Error() != "Prometheus series query failed: parse error: unexpected character" {
+		t.Errorf("error = %v, want the series error", res.Error)
+	}
+}
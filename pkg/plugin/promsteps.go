@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// prometheusStepPointBudget caps the points per series summed over all
+// requested step resolutions, matching Prometheus' per-query limit
+const prometheusStepPointBudget = 11000
+
+// defaultPrometheusStep is used when neither the query nor the panel
+// provides an interval
+const defaultPrometheusStep = 15 * time.Second
+
+// calculatePrometheusStep returns the range query step: the query's explicit
+// Step, or else the panel interval widened so no more than MaxDataPoints
+// points are returned. Steps are whole seconds and must stay within
+// Prometheus' point limit.
+func calculatePrometheusStep(query backend.DataQuery, queryModel *models.QueryModel) (time.Duration, error) {
+	rangeDuration := query.TimeRange.To.Sub(query.TimeRange.From)
+
+	var step time.Duration
+	if queryModel.Step != "" {
+		parsed, err := time.ParseDuration(queryModel.Step)
+		if err != nil || parsed <= 0 {
+			return 0, fmt.Errorf("invalid step %q", queryModel.Step)
+		}
+		step = parsed
+	} else {
+		step = query.Interval
+		if step <= 0 {
+			step = defaultPrometheusStep
+		}
+		if query.MaxDataPoints > 0 {
+			if resolution := rangeDuration / time.Duration(query.MaxDataPoints); resolution > step {
+				step = resolution
+			}
+		}
+	}
+
+	step = step.Truncate(time.Second)
+	if step < time.Second {
+		step = time.Second
+	}
+
+	if points := int64(rangeDuration / step); points > prometheusStepPointBudget {
+		return 0, fmt.Errorf("step %s would return %d points per series, exceeding Prometheus' limit of %d; use a coarser step or a shorter range", formatPromDuration(step), points, prometheusStepPointBudget)
+	}
+
+	return step, nil
+}
+
+// executeMultiStep runs a range query once per requested step concurrently
+// and returns every resolution's frames, named and labeled by step
+func (h *PrometheusHandler) executeMultiStep(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
+	rangeDuration := query.TimeRange.To.Sub(query.TimeRange.From)
+
+	steps := make([]time.Duration, len(queryModel.Steps))
+	var points int64
+	for i, s := range queryModel.Steps {
+		step, err := time.ParseDuration(s)
+		if err != nil || step <= 0 {
+			return backend.DataResponse{
+				Error: fmt.Errorf("invalid step %q", s),
+			}
+		}
+		steps[i] = step
+		points += int64(rangeDuration / step)
+	}
+	if points > prometheusStepPointBudget {
+		return backend.DataResponse{
+			Error: fmt.Errorf("requested steps would return %d points per series, exceeding the budget of %d; use coarser steps or a shorter range", points, prometheusStepPointBudget),
+		}
+	}
+
+	responses := make([]backend.DataResponse, len(steps))
+	var wg sync.WaitGroup
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each resolution is exact, regardless of the panel's data points
+			stepModel := *queryModel
+			stepModel.Step = queryModel.Steps[i]
+			responses[i] = h.executeQuery(ctx, query, &stepModel)
+		}(i)
+	}
+	wg.Wait()
+
+	var frames data.Frames
+	var errs []error
+	for i, res := range responses {
+		name := queryModel.Steps[i]
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("step %s: %w", name, res.Error))
+			continue
+		}
+		for _, frame := range res.Frames {
+			frame.Name = name
+			for _, field := range frame.Fields {
+				if field.Type().Time() {
+					continue
+				}
+				labels := data.Labels{"step": name}
+				for k, v := range field.Labels {
+					labels[k] = v
+				}
+				field.Labels = labels
+			}
+			frames = append(frames, frame)
+		}
+	}
+
+	if len(errs) > 0 {
+		res := joinedErrorResponse(responses, errs)
+		res.Frames = frames
+		return res
+	}
+
+	return backend.DataResponse{
+		Frames: frames,
+	}
+}
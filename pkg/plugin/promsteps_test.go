@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrometheusMultiStep(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		steps []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		step := r.URL.Query().Get("step")
+		mu.Lock()
+		steps = append(steps, step)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		// The served step is echoed as a label to pair frames with requests
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a","served":%q},"values":[[1704103200,"1"]]}]}}`, step)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up", "steps": []string{"5m", "1m"}})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	sort.Strings(steps)
+	if strings.Join(steps, ",") != "300s,60s" {
+		t.Errorf("requested steps = %v, want 300s and 60s", steps)
+	}
+
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(res.Frames))
+	}
+	want := []struct{ name, served string }{{"5m", "300s"}, {"1m", "60s"}}
+	for i, frame := range res.Frames {
+		if frame.Name != want[i].name {
+			t.Errorf("frame %d name = %q, want %q", i, frame.Name, want[i].name)
+		}
+		labels := frame.Fields[1].Labels
+		if labels["step"] != want[i].name || labels["served"] != want[i].served || labels["job"] != "a" {
+			t.Errorf("frame %d labels = %v, want step %s served at %s", i, labels, want[i].name, want[i].served)
+		}
+	}
+}
+
+func TestPrometheusMultiStepErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("step") == "1s" {
+			http.Error(w, `{"status":"error","error":"exceeded maximum resolution"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, prometheusMatrix(1))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		steps      []string
+		wantErr    string
+		wantFrames int
+	}{
+		{name: "invalid step", steps: []string{"1m", "soon"}, wantErr: `invalid step "soon"`},
+		{name: "non-positive step", steps: []string{"0s"}, wantErr: `invalid step "0s"`},
+		{name: "point budget", steps: []string{"1s", "1s", "1s", "1s"}, wantErr: "requested steps would return 14400 points per series, exceeding the budget of 11000"},
+		{name: "one resolution fails", steps: []string{"1m", "1s"}, wantErr: "step 1s:", wantFrames: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up", "steps": tt.steps})
+
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", res.Error, tt.wantErr)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Errorf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+		})
+	}
+}
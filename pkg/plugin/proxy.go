@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// statusClientClosedRequest is the non-standard status used when the caller
+// cancels a request before the backend responds
+const statusClientClosedRequest = 499
+
+// proxyChunkSize is the size of the body chunks streamed back to the caller
+const proxyChunkSize = 32 * 1024
+
+// proxyTarget describes the backend a resource call is forwarded to
+type proxyTarget struct {
+	URL string
+
+	// Headers are the source's configured headers
+	Headers map[string]string
+
+	// Sign adds the configured HMAC request signature
+	Sign bool
+}
+
+// proxyResource forwards a resource call to the target, carrying over the
+// query string, body and headers and adding the source's configured headers
+// and authentication
+func (d *Datasource) proxyResource(ctx context.Context, target proxyTarget, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	targetURL := target.URL
+	if len(req.URL) > 0 && req.URL != req.Path {
+		// Parse URL to extract query string if present
+		if parsedURL, err := url.Parse(req.URL); err == nil && parsedURL.RawQuery != "" {
+			targetURL += "?" + parsedURL.RawQuery
+		}
+	}
+
+	var bodyReader io.Reader
+	if len(req.Body) > 0 {
+		bodyReader = bytes.NewReader(req.Body)
+	}
+
+	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, targetURL, bodyReader)
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 500,
+			Body:   []byte(fmt.Sprintf(`{"error": "Failed to create request: %v"}`, err)),
+		})
+	}
+
+	// Copy headers
+	for k, v := range req.Headers {
+		proxyReq.Header[k] = v
+	}
+
+	setConfigHeaders(proxyReq, target.Headers, d.config.Headers)
+
+	// Add auth
+	setAuthHeaders(proxyReq, d.config)
+	if target.Sign {
+		signRequest(proxyReq, req.Body, d.config, time.Now())
+	}
+
+	resp, err := d.client.Do(proxyReq)
+	if err != nil {
+		return d.sendProxyError(ctx, sender, "Request failed", err)
+	}
+	defer resp.Body.Close()
+
+	return d.streamProxyResponse(ctx, resp, sender)
+}
+
+// streamProxyResponse sends the status and headers with the first chunk of
+// the body and the rest as it arrives, so large responses are neither fully
+// buffered nor delayed. The response size limit still applies; once
+// streaming has started an oversized body can only be cut short.
+func (d *Datasource) streamProxyResponse(ctx context.Context, resp *http.Response, sender backend.CallResourceResponseSender) error {
+	limit := d.config.MaxResponseBytes
+	if limit <= 0 {
+		limit = defaultMaxResponseBytes
+	}
+	if resp.ContentLength > limit {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusBadGateway,
+			Body:   []byte(fmt.Sprintf(`{"error": "response size %d exceeds limit of %d bytes"}`, resp.ContentLength, limit)),
+		})
+	}
+
+	body := http.MaxBytesReader(nil, resp.Body, limit)
+	buf := make([]byte, proxyChunkSize)
+	first := true
+	for {
+		n, err := io.ReadFull(body, buf)
+		done := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !done {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				err = fmt.Errorf("response exceeds limit of %d bytes", limit)
+			}
+			if first {
+				return d.sendProxyError(ctx, sender, "Failed to read response", err)
+			}
+			return fmt.Errorf("failed to stream response: %w", err)
+		}
+
+		if n > 0 || first {
+			chunk := &backend.CallResourceResponse{Body: append([]byte(nil), buf[:n]...)}
+			if first {
+				chunk.Status = resp.StatusCode
+				chunk.Headers = resp.Header
+				first = false
+			}
+			if err := sender.Send(chunk); err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// sendProxyError reports a failed proxy call, distinguishing a caller that
+// went away (499) and an expired deadline (504) from other failures (500)
+func (d *Datasource) sendProxyError(ctx context.Context, sender backend.CallResourceResponseSender, msg string, err error) error {
+	status := 500
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		status = statusClientClosedRequest
+		msg = "Request cancelled"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+		msg = "Request timed out"
+	}
+
+	d.logger.Debug("Resource proxy failed", "status", status, "error", err)
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status: status,
+		Body:   []byte(fmt.Sprintf(`{"error": "%s: %v"}`, msg, err)),
+	})
+}
@@ -0,0 +1,181 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestResourceProxyCancellation(t *testing.T) {
+	tests := []struct {
+		name       string
+		delay      time.Duration
+		settings   map[string]interface{}
+		cancel     time.Duration
+		deadline   time.Duration
+		wantStatus int
+	}{
+		{name: "answered", wantStatus: 200},
+		{name: "cancelled by the caller", delay: 5 * time.Second, cancel: 100 * time.Millisecond, wantStatus: statusClientClosedRequest},
+		{name: "caller deadline", delay: 5 * time.Second, deadline: 200 * time.Millisecond, wantStatus: 504},
+		{name: "client timeout", delay: 5 * time.Second, settings: map[string]interface{}{"timeoutSeconds": 1}, wantStatus: 504},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slowServer(t, tt.delay)
+			settings := map[string]interface{}{"restUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel > 0 {
+				time.AfterFunc(tt.cancel, cancel)
+			}
+			if tt.deadline > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			rec := &resourceRecorder{}
+			start := time.Now()
+			err := ds.CallResource(ctx, &backend.CallResourceRequest{Path: "rest", URL: "rest", Method: "GET"}, rec)
+			if err != nil {
+				t.Fatalf("CallResource: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("resource call took %s, want it bounded", elapsed)
+			}
+			if len(rec.responses) == 0 {
+				t.Fatal("no response sent")
+			}
+			if got := rec.responses[0].Status; got != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", got, tt.wantStatus, rec.responses[0].Body)
+			}
+		})
+	}
+}
+
+// chunkSender forwards every sent response to a channel
+type chunkSender chan *backend.CallResourceResponse
+
+// Send forwards a response
+func (c chunkSender) Send(resp *backend.CallResourceResponse) error {
+	c <- resp
+	return nil
+}
+
+func TestResourceProxyStreaming(t *testing.T) {
+	head := bytes.Repeat([]byte("a"), 3*proxyChunkSize)
+	tail := bytes.Repeat([]byte("b"), proxyChunkSize/2)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Upstream", "yes")
+		w.Write(head)
+		w.(http.Flusher).Flush()
+		// Hold back the rest until the caller has received the start
+		<-release
+		w.Write(tail)
+	}))
+	defer srv.Close()
+	releaseOnce := sync.OnceFunc(func() { close(release) })
+	defer releaseOnce()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	sender := make(chunkSender, 16)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ds.CallResource(context.Background(), &backend.CallResourceRequest{Path: "rest", URL: "rest", Method: "GET"}, sender)
+	}()
+
+	var chunks []*backend.CallResourceResponse
+	select {
+	case chunk := <-sender:
+		chunks = append(chunks, chunk)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunk sent before the upstream body was complete")
+	}
+	releaseOnce()
+	if err := <-errs; err != nil {
+		t.Fatalf("CallResource: %v", err)
+	}
+	close(sender)
+	for chunk := range sender {
+		chunks = append(chunks, chunk)
+	}
+
+	first := chunks[0]
+	if first.Status != http.StatusOK || first.Headers["X-Upstream"][0] != "yes" {
+		t.Errorf("first chunk = %d %v, want the upstream status and headers", first.Status, first.Headers)
+	}
+	var body []byte
+	for i, chunk := range chunks {
+		if i > 0 && (chunk.Status != 0 || chunk.Headers != nil) {
+			t.Errorf("chunk %d repeats the status or headers", i)
+		}
+		if len(chunk.Body) > proxyChunkSize {
+			t.Errorf("chunk %d has %d bytes, want at most %d", i, len(chunk.Body), proxyChunkSize)
+		}
+		body = append(body, chunk.Body...)
+	}
+	if want := append(append([]byte(nil), head...), tail...); !bytes.Equal(body, want) {
+		t.Errorf("streamed %d bytes, want %d", len(body), len(want))
+	}
+}
+
+func TestResourceProxySizeLimit(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 2*proxyChunkSize)
+	tests := []struct {
+		name       string
+		chunked    bool
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "declared length", wantStatus: http.StatusBadGateway},
+		{name: "chunked", chunked: true, wantStatus: http.StatusOK, wantErr: "response exceeds limit of 40000 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.chunked {
+					w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				}
+				w.Write(body[:proxyChunkSize])
+				w.(http.Flusher).Flush()
+				w.Write(body[proxyChunkSize:])
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL, "maxResponseBytes": 40000}, nil)
+			rec := &resourceRecorder{}
+			err := ds.CallResource(context.Background(), &backend.CallResourceRequest{Path: "rest", URL: "rest", Method: "GET"}, rec)
+
+			if len(rec.responses) == 0 || rec.responses[0].Status != tt.wantStatus {
+				t.Fatalf("responses = %v, want status %d first", rec.responses, tt.wantStatus)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CallResource: %v", err)
+				}
+				return
+			}
+			// Once streaming has started the only way to fail is the stream error
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultResponseCacheSize bounds the cached results when the
+// configuration doesn't
+const defaultResponseCacheSize = 1000
+
+// cacheIgnoredKeys are query JSON members that don't change the result
+var cacheIgnoredKeys = []string{"refId", "datasource", "datasourceId", "requestId", "key", "hide", "nocache"}
+
+// responseCacheEntry is a cached successful result
+type responseCacheEntry struct {
+	key     string
+	frames  data.Frames
+	expires time.Time
+}
+
+// responseCache is a size-bounded LRU of successful query results, so
+// dashboard refreshes within the TTL don't query the backends again
+type responseCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// newResponseCache creates a cache keeping results for ttl, or nil when ttl
+// is zero and the cache is disabled
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultResponseCacheSize
+	}
+	return &responseCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// fetch returns the cached result of the query, or runs it and caches a
+// successful result. Queries with the nocache flag always run.
+func (c *responseCache) fetch(query backend.DataQuery, run func() backend.DataResponse) backend.DataResponse {
+	if c == nil {
+		return run()
+	}
+
+	key, ok := c.key(query)
+	if !ok {
+		return run()
+	}
+
+	if frames, ok := c.get(key); ok {
+		return backend.DataResponse{Frames: copyFramesMeta(frames)}
+	}
+
+	res := run()
+	if res.Error == nil && !hasWarningNotices(res.Frames) {
+		c.set(key, res.Frames)
+		// The cached frames keep their metadata while callers add notices
+		res.Frames = copyFramesMeta(res.Frames)
+	}
+	return res
+}
+
+// key identifies a query by its type, its JSON without per-panel members
+// and its time range rounded to the query interval, capped at the TTL. A
+// "now"-relative range keeps hitting while it moves less than one interval,
+// while distinct absolute ranges never share a key. It reports false for
+// nocache queries.
+func (c *responseCache) key(query backend.DataQuery) (string, bool) {
+	var model map[string]interface{}
+	if err := json.Unmarshal(query.JSON, &model); err != nil {
+		return "", false
+	}
+	if noCache, _ := model["nocache"].(bool); noCache {
+		return "", false
+	}
+	for _, k := range cacheIgnoredKeys {
+		delete(model, k)
+	}
+
+	// Maps marshal with sorted keys, so equal queries give equal keys
+	normalized, err := json.Marshal(model)
+	if err != nil {
+		return "", false
+	}
+
+	precision := query.Interval
+	if precision > c.ttl {
+		precision = c.ttl
+	}
+	return fmt.Sprintf("%v|%s|%d|%d|%d|%d", model["queryType"], normalized,
+		query.TimeRange.From.Truncate(precision).UnixMilli(), query.TimeRange.To.Truncate(precision).UnixMilli(),
+		query.Interval.Milliseconds(), query.MaxDataPoints), true
+}
+
+// hasWarningNotices reports whether any frame carries a warning or error
+// notice, such as a partial fan-out, which must not be served again from
+// the cache
+func hasWarningNotices(frames data.Frames) bool {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			continue
+		}
+		for _, notice := range frame.Meta.Notices {
+			if notice.Severity == data.NoticeSeverityWarning || notice.Severity == data.NoticeSeverityError {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// get returns unexpired cached frames and marks them recently used
+func (c *responseCache) get(key string) (data.Frames, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*responseCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.frames, true
+}
+
+// set caches frames, evicting the least recently used result when full
+func (c *responseCache) set(key string, frames data.Frames) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &responseCacheEntry{key: key, frames: frames, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).key)
+	}
+}
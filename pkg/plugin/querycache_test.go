@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestResponseCache(t *testing.T) {
+	restQuery := map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}
+	shifted := func(q backend.DataQuery) backend.DataQuery {
+		q.TimeRange.From = q.TimeRange.From.Add(5 * time.Minute)
+		q.TimeRange.To = q.TimeRange.To.Add(5 * time.Minute)
+		return q
+	}
+	jittered := func(q backend.DataQuery) backend.DataQuery {
+		q.TimeRange.From = q.TimeRange.From.Add(20 * time.Second)
+		q.TimeRange.To = q.TimeRange.To.Add(20 * time.Second)
+		return q
+	}
+
+	tests := []struct {
+		name         string
+		first        map[string]interface{}
+		second       map[string]interface{}
+		adjust       func(backend.DataQuery) backend.DataQuery
+		wantRequests int32
+	}{
+		{name: "identical query hits", first: restQuery, second: restQuery, wantRequests: 1},
+		{name: "panel members ignored", first: restQuery, second: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data", "refId": "B", "hide": false}, wantRequests: 1},
+		{name: "range moved within an interval hits", first: restQuery, second: restQuery, adjust: jittered, wantRequests: 1},
+		{name: "distinct absolute range misses", first: restQuery, second: restQuery, adjust: shifted, wantRequests: 2},
+		{name: "different query misses", first: restQuery, second: map[string]interface{}{"queryType": "rest", "restEndpoint": "/other"}, wantRequests: 2},
+		{name: "nocache bypasses", first: restQuery, second: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data", "nocache": true}, wantRequests: 2},
+		{
+			name:         "partial fan-out not cached",
+			first:        map[string]interface{}{"queryType": "rest", "restEndpoint": "/{{target}}", "fanOut": []string{"ok", "fail"}},
+			second:       map[string]interface{}{"queryType": "rest", "restEndpoint": "/{{target}}", "fanOut": []string{"ok", "fail"}},
+			wantRequests: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if strings.HasSuffix(r.URL.Path, "/fail") {
+					http.Error(w, "unavailable", http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `[{"value": 1}]`)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL, "cacheTTLSeconds": 3600}, nil)
+
+			first := runQuery(t, ds, tt.first)
+			if first.Error != nil {
+				t.Fatalf("first query failed: %v", first.Error)
+			}
+			second := testQuery(t, "A", tt.second)
+			if tt.adjust != nil {
+				second = tt.adjust(second)
+			}
+			if res := runQueries(t, ds, second).Responses["A"]; res.Error != nil {
+				t.Fatalf("second query failed: %v", res.Error)
+			}
+
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("backend requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	cache := newResponseCache(10, 50*time.Millisecond)
+	query := testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+
+	runs := 0
+	run := func() backend.DataResponse {
+		runs++
+		return backend.DataResponse{Frames: data.Frames{data.NewFrame("result")}}
+	}
+
+	cache.fetch(query, run)
+	cache.fetch(query, run)
+	if runs != 1 {
+		t.Fatalf("runs within the TTL = %d, want 1", runs)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	cache.fetch(query, run)
+	if runs != 2 {
+		t.Errorf("runs after the TTL = %d, want 2", runs)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache := newResponseCache(2, time.Hour)
+	run := func() backend.DataResponse {
+		return backend.DataResponse{Frames: data.Frames{data.NewFrame("result")}}
+	}
+
+	for _, endpoint := range []string{"/a", "/b", "/c"} {
+		cache.fetch(testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": endpoint}), run)
+	}
+	if len(cache.entries) != 2 {
+		t.Fatalf("cached %d results, want 2", len(cache.entries))
+	}
+
+	evicted := false
+	cache.fetch(testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/a"}), func() backend.DataResponse {
+		evicted = true
+		return run()
+	})
+	if !evicted {
+		t.Error("least recently used result was not evicted")
+	}
+}
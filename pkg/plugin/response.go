@@ -0,0 +1,267 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultMaxResponseBytes caps backend response bodies when unset
+const defaultMaxResponseBytes = 64 << 20
+
+// defaultMaxFramesPerQuery caps the frames returned for one query when unset
+const defaultMaxFramesPerQuery = 10000
+
+// readResponseBody reads a backend response body, enforcing the size limit.
+// Chunked responses carry no Content-Length, so the limit is also enforced
+// while reading; a stalled stream is bounded by the request context and the
+// client timeout.
+func readResponseBody(resp *http.Response, limit int64, logger log.Logger) ([]byte, error) {
+	if limit <= 0 {
+		limit = defaultMaxResponseBytes
+	}
+
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("response size %d exceeds limit of %d bytes", resp.ContentLength, limit)
+	}
+
+	start := time.Now()
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, limit))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, fmt.Errorf("response exceeds limit of %d bytes", limit)
+		}
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	logger.Debug("Read response body",
+		"bytes", len(body),
+		"chunked", resp.ContentLength < 0,
+		"duration", time.Since(start),
+	)
+
+	return body, nil
+}
+
+// readResponseBodyWithin reads a backend response body like readResponseBody,
+// but stops after maxWait for endpoints that stream and never close the
+// connection. complete reports whether the body ended before the deadline;
+// otherwise the bytes received so far are returned. A zero maxWait waits for
+// the whole body.
+func readResponseBodyWithin(resp *http.Response, limit int64, maxWait time.Duration, logger log.Logger) (body []byte, complete bool, err error) {
+	if maxWait <= 0 {
+		body, err = readResponseBody(resp, limit, logger)
+		return body, true, err
+	}
+
+	var (
+		mu      sync.Mutex
+		partial []byte
+		done    = make(chan error, 1)
+	)
+	go func() {
+		body, err := readResponseBody(&http.Response{
+			Body:          &progressReader{r: resp.Body, mu: &mu, buf: &partial},
+			ContentLength: resp.ContentLength,
+		}, limit, logger)
+		if err == nil {
+			mu.Lock()
+			partial = body
+			mu.Unlock()
+		}
+		done <- err
+	}()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, false, err
+		}
+		return partial, true, nil
+	case <-timer.C:
+	}
+
+	// Closing the body unblocks the pending read
+	resp.Body.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	logger.Debug("Response stream still open, using partial body", "bytes", len(partial), "maxWait", maxWait)
+	return partial, false, nil
+}
+
+// progressReader records everything read so far so a partial body can be
+// recovered when reading is abandoned
+type progressReader struct {
+	r   io.Reader
+	mu  *sync.Mutex
+	buf *[]byte
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.mu.Lock()
+	*p.buf = append(*p.buf, b[:n]...)
+	p.mu.Unlock()
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	return nil
+}
+
+// htmlSnippetLength bounds the body excerpt included in error messages
+const htmlSnippetLength = 200
+
+// looksLikeHTML reports whether a response is an HTML document, based on its
+// Content-Type or, for generic content types, its leading bytes
+func looksLikeHTML(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return true
+	}
+
+	lead := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(lead, "<!doctype html") || strings.HasPrefix(lead, "<html")
+}
+
+// bodySnippet returns a short, single-line excerpt of a response body,
+// cut on a rune boundary so the message stays valid UTF-8
+func bodySnippet(body []byte) string {
+	snippet := strings.ToValidUTF8(strings.Join(strings.Fields(string(body)), " "), "\uFFFD")
+	if len(snippet) > htmlSnippetLength {
+		cut := htmlSnippetLength
+		for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+			cut--
+		}
+		snippet = snippet[:cut] + "..."
+	}
+	return snippet
+}
+
+// addNotice appends a notice to every frame
+func addNotice(frames data.Frames, severity data.NoticeSeverity, text string) {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.Notices = append(frame.Meta.Notices, data.Notice{
+			Severity: severity,
+			Text:     text,
+		})
+	}
+}
+
+// setFrameMetaCustom records a debugging value in each frame's custom
+// metadata, keeping entries already there
+func setFrameMetaCustom(frames data.Frames, key string, value interface{}) {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		custom, ok := frame.Meta.Custom.(map[string]interface{})
+		if !ok {
+			custom = map[string]interface{}{}
+			frame.Meta.Custom = custom
+		}
+		custom[key] = value
+	}
+}
+
+// limitFrames truncates a query's frames to the configured maximum, noting
+// how many were dropped on the first frame kept
+func limitFrames(res backend.DataResponse, limit int) backend.DataResponse {
+	if limit <= 0 {
+		limit = defaultMaxFramesPerQuery
+	}
+	if len(res.Frames) <= limit {
+		return res
+	}
+
+	dropped := len(res.Frames) - limit
+	res.Frames = res.Frames[:limit]
+	addNotice(res.Frames[:1], data.NoticeSeverityWarning,
+		fmt.Sprintf("Query returned %d frames; only the first %d are shown (%d dropped)", limit+dropped, limit, dropped))
+	return res
+}
+
+// statusErrorResponse reports a backend's non-success HTTP status, keeping
+// the status so Grafana can tell an authentication failure from an outage
+func statusErrorResponse(status int, err error) backend.DataResponse {
+	return backend.DataResponse{
+		Error:       err,
+		Status:      backend.Status(status),
+		ErrorSource: backend.ErrorSourceFromHTTPStatus(status),
+	}
+}
+
+// requestErrorResponse reports a backend request that got no response, a
+// downstream timeout or an unreachable backend
+func requestErrorResponse(err error) backend.DataResponse {
+	status := backend.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = backend.StatusTimeout
+	}
+	return backend.DataResponse{
+		Error:       fmt.Errorf("failed to execute request: %w", err),
+		Status:      status,
+		ErrorSource: backend.ErrorSourceDownstream,
+	}
+}
+
+// joinedErrorResponse reports the joined errors of several backend requests
+// with the status and source of the first failed one
+func joinedErrorResponse(responses []backend.DataResponse, errs []error) backend.DataResponse {
+	res := backend.DataResponse{Error: errors.Join(errs...)}
+	for _, r := range responses {
+		if r.Error != nil {
+			res.Status = r.Status
+			res.ErrorSource = r.ErrorSource
+			break
+		}
+	}
+	return res
+}
+
+// classifyErrorResponse attributes an error not classified where it was
+// raised: timeouts and transport failures are downstream, anything else
+// (invalid queries, unparseable responses) is the plugin's
+func classifyErrorResponse(res backend.DataResponse) backend.DataResponse {
+	if res.Error == nil || res.ErrorSource != "" {
+		return res
+	}
+
+	var urlErr *url.Error
+	switch {
+	case errors.Is(res.Error, context.DeadlineExceeded):
+		res.ErrorSource = backend.ErrorSourceDownstream
+		if res.Status == 0 {
+			res.Status = backend.StatusTimeout
+		}
+	case errors.As(res.Error, &urlErr):
+		res.ErrorSource = backend.ErrorSourceDownstream
+		if res.Status == 0 {
+			res.Status = backend.StatusBadGateway
+		}
+	default:
+		res.ErrorSource = backend.ErrorSourcePlugin
+	}
+	return res
+}
@@ -0,0 +1,346 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// prometheusMatrix returns a range query response with n single-sample series
+func prometheusMatrix(n int) string {
+	series := make([]string, n)
+	for i := range series {
+		series[i] = fmt.Sprintf(`{"metric":{"__name__":"up","instance":"host%d"},"values":[[1704103200,"1"]]}`, i)
+	}
+	return `{"status":"success","data":{"resultType":"matrix","result":[` + strings.Join(series, ",") + `]}}`
+}
+
+func TestMaxFramesPerQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		series     int
+		limit      int
+		wantFrames int
+		wantNotice bool
+	}{
+		{name: "over the cap", series: 5, limit: 2, wantFrames: 2, wantNotice: true},
+		{name: "at the cap", series: 2, limit: 2, wantFrames: 2, wantNotice: false},
+		{name: "default cap", series: 3, limit: 0, wantFrames: 3, wantNotice: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, prometheusMatrix(tt.series))
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL, "maxFramesPerQuery": tt.limit}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up"})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Fatalf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+			if got := hasNotice(res.Frames[0], "only the first"); got != tt.wantNotice {
+				t.Errorf("truncation notice = %v, want %v", got, tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestLimitFramesKeepsErrors(t *testing.T) {
+	res := limitFrames(backend.DataResponse{Error: fmt.Errorf("boom")}, 1)
+	if res.Error == nil || len(res.Frames) != 0 {
+		t.Errorf("limitFrames changed an error response: %+v", res)
+	}
+}
+
+// hasNotice reports whether a frame carries a notice containing text
+func hasNotice(frame *data.Frame, text string) bool {
+	if frame.Meta == nil {
+		return false
+	}
+	for _, notice := range frame.Meta.Notices {
+		if strings.Contains(notice.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBodySnippet(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "short body", body: "<html>\n  <body>Bad gateway</body>\n</html>", want: "<html> <body>Bad gateway</body> </html>"},
+		{name: "ASCII truncated", body: strings.Repeat("a", 250), want: strings.Repeat("a", htmlSnippetLength) + "..."},
+		{name: "multibyte at the limit", body: strings.Repeat("a", htmlSnippetLength-1) + "ü tail", want: strings.Repeat("a", htmlSnippetLength-1) + "..."},
+		{name: "multibyte throughout", body: strings.Repeat("日", 100), want: strings.Repeat("日", htmlSnippetLength/3) + "..."},
+		{name: "invalid UTF-8 replaced", body: "bad \xff byte", want: "bad � byte"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bodySnippet([]byte(tt.body))
+			if got != tt.want {
+				t.Errorf("bodySnippet = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("bodySnippet returned invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
+
+func TestHTMLErrorPageSnippet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<!DOCTYPE html><html><body>"+strings.Repeat("Anmeldung erforderlich für Zugriff – ", 20)+"</body></html>")
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+	if res.Error == nil {
+		t.Fatal("expected an HTML page to fail the query")
+	}
+	if msg := res.Error.Error(); !utf8.ValidString(msg) || !strings.Contains(msg, "Anmeldung") {
+		t.Errorf("error = %q, want a valid UTF-8 snippet of the page", msg)
+	}
+}
+
+func TestHTMLErrorPageNamesExpectedFormat(t *testing.T) {
+	const page = "<!DOCTYPE html><html><body>Sign in</body></html>"
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "detected JSON", want: "expected JSON but received HTML"},
+		{name: "configured CSV", format: "csv", want: "expected CSV but received HTML"},
+		{name: "configured XML", format: "xml", want: "expected XML but received HTML"},
+		{name: "configured NDJSON", format: "ndjson", want: "expected NDJSON but received HTML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serveBody(t, "text/html", page, map[string]interface{}{"responseFormat": tt.format})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// chunkedServer writes body in chunks of size bytes, flushing and pausing
+// after each, then stalls for stall before finishing
+func chunkedServer(t *testing.T, body string, size int, stall time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		flusher := w.(http.Flusher)
+		for len(body) > 0 {
+			n := min(size, len(body))
+			fmt.Fprint(w, body[:n])
+			body = body[n:]
+			flusher.Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+		select {
+		case <-time.After(stall):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestChunkedResponses(t *testing.T) {
+	matrix := prometheusMatrix(50)
+
+	tests := []struct {
+		name       string
+		body       string
+		stall      time.Duration
+		settings   map[string]interface{}
+		deadline   time.Duration
+		wantErr    string
+		wantFrames int
+	}{
+		{name: "within the limit", body: matrix, wantFrames: 50},
+		{name: "over the limit", body: matrix, settings: map[string]interface{}{"maxResponseBytes": len(matrix) / 2}, wantErr: "exceeds limit"},
+		{name: "stall bounded by the query deadline", body: matrix[:len(matrix)/2], stall: 5 * time.Second, deadline: 300 * time.Millisecond, wantErr: "response"},
+		{name: "stall bounded by the client timeout", body: matrix[:len(matrix)/2], stall: 5 * time.Second, settings: map[string]interface{}{"timeoutSeconds": 1}, wantErr: "response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := chunkedServer(t, tt.body, 256, tt.stall)
+			settings := map[string]interface{}{"prometheusUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, nil)
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			start := time.Now()
+			resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{
+				testQuery(t, "A", map[string]interface{}{"queryType": "prometheus", "promQL": "up"}),
+			}})
+			if err != nil {
+				t.Fatalf("QueryData: %v", err)
+			}
+			res := resp.Responses["A"]
+
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				if elapsed := time.Since(start); elapsed > 3*time.Second {
+					t.Errorf("stalled response took %v to fail", elapsed)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Errorf("got %d frames, want %d", len(res.Frames), tt.wantFrames)
+			}
+		})
+	}
+}
+
+func TestErrorBodyIsBounded(t *testing.T) {
+	large := strings.Repeat("x", 64<<10)
+
+	tests := []struct {
+		name     string
+		settings string
+		model    map[string]interface{}
+	}{
+		{name: "prometheus", settings: "prometheusUrl", model: map[string]interface{}{"queryType": "prometheus", "promQL": "up"}},
+		{name: "loki", settings: "lokiUrl", model: map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`}},
+		{name: "rest", settings: "restUrl", model: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}},
+	}
+
+	for _, tt := range tests {
+		for size, body := range map[string]string{"small": "backend exploded", "over the limit": large} {
+			t.Run(tt.name+" "+size, func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+					// Flushing first drops the Content-Length, as with a chunked error page
+					w.(http.Flusher).Flush()
+					fmt.Fprint(w, body)
+				}))
+				defer srv.Close()
+
+				ds := newTestDatasource(t, map[string]interface{}{tt.settings: srv.URL, "maxResponseBytes": 1024}, nil)
+				res := runQuery(t, ds, tt.model)
+				if res.Error == nil {
+					t.Fatal("expected an error")
+				}
+
+				msg := res.Error.Error()
+				if len(msg) > 2048 {
+					t.Errorf("error message is %d bytes, want the body bounded by the response limit", len(msg))
+				}
+				if body != large && !strings.Contains(msg, body) {
+					t.Errorf("error = %q, want the body %q", msg, body)
+				}
+			})
+		}
+	}
+}
+
+func TestRESTStreamStillOpen(t *testing.T) {
+	const stillOpen = "Response stream was still open after 300ms"
+
+	tests := []struct {
+		name       string
+		body       string
+		stall      time.Duration
+		model      map[string]interface{}
+		wantValues []string
+		wantNotice bool
+		wantErr    string
+	}{
+		{
+			name:       "complete response",
+			body:       `[{"v": 1}, {"v": 2}]`,
+			model:      map[string]interface{}{"maxWaitMs": 300},
+			wantValues: []string{"1", "2"},
+		},
+		{
+			name:       "open NDJSON stream keeps complete lines",
+			body:       "{\"v\": 1}\n{\"v\": 2}\n{\"v\": ",
+			stall:      5 * time.Second,
+			model:      map[string]interface{}{"maxWaitMs": 300, "responseFormat": "ndjson"},
+			wantValues: []string{"1", "2"},
+			wantNotice: true,
+		},
+		{
+			name:    "open JSON stream is incomplete",
+			body:    `[{"v": 1}, {"v"`,
+			stall:   5 * time.Second,
+			model:   map[string]interface{}{"maxWaitMs": 300},
+			wantErr: "response stream was still open after 300ms and the 15 bytes received are not a complete response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := chunkedServer(t, tt.body, len(tt.body), tt.stall)
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+			model := map[string]interface{}{"queryType": "rest", "restEndpoint": "/stream"}
+			for k, v := range tt.model {
+				model[k] = v
+			}
+
+			start := time.Now()
+			res := runQuery(t, ds, model)
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("query took %v, want it bounded by the max wait", elapsed)
+			}
+
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			if got := frameColumns(res.Frames[0])["v"]; !reflect.DeepEqual(got, tt.wantValues) {
+				t.Errorf("values = %v, want %v", got, tt.wantValues)
+			}
+			if got := hasNotice(res.Frames[0], stillOpen); got != tt.wantNotice {
+				t.Errorf("still open notice = %v, want %v", got, tt.wantNotice)
+			}
+		})
+	}
+}
@@ -3,13 +3,15 @@ package plugin
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sameersah/GrafanaConnect/pkg/models"
@@ -18,10 +20,17 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
+// defaultTimeKeys are the field names recognized as timestamps in REST arrays
+var defaultTimeKeys = []string{"time", "timestamp", "date", "ts", "datetime"}
+
+// fanOutPlaceholder is replaced by each FanOut value in the REST endpoint
+const fanOutPlaceholder = "{{target}}"
+
 // RESTAPIHandler handles REST API queries
 type RESTAPIHandler struct {
 	config *models.DataSourceConfig
 	logger log.Logger
+	client *http.Client
 }
 
 // handleRESTQuery processes REST API queries
@@ -29,6 +38,7 @@ func (d *Datasource) handleRESTQuery(ctx context.Context, query backend.DataQuer
 	handler := &RESTAPIHandler{
 		config: d.config,
 		logger: d.logger,
+		client: d.client,
 	}
 
 	if queryModel.RESTEndpoint == "" {
@@ -37,9 +47,125 @@ func (d *Datasource) handleRESTQuery(ctx context.Context, query backend.DataQuer
 		}
 	}
 
+	// "auto" is accepted as an explicit spelling of the default
+	if queryModel.ForceValueType == "auto" {
+		queryModel.ForceValueType = models.ValueTypeAuto
+	}
+
+	if len(queryModel.FanOut) > 0 {
+		if !strings.Contains(queryModel.RESTEndpoint, fanOutPlaceholder) {
+			return backend.DataResponse{
+				Error: fmt.Errorf("fan-out requires a %s placeholder in the REST endpoint", fanOutPlaceholder),
+			}
+		}
+		return handler.executeFanOut(ctx, query, queryModel)
+	}
+
 	return handler.executeQuery(ctx, query, queryModel)
 }
 
+// executeFanOut runs the query once per FanOut target concurrently and merges
+// the results into frames tagged with their target
+func (h *RESTAPIHandler) executeFanOut(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
+	responses := make([]backend.DataResponse, len(queryModel.FanOut))
+
+	var wg sync.WaitGroup
+	for i, target := range queryModel.FanOut {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+
+			// Concurrency is bounded by the client's request limiter
+			targetModel := *queryModel
+			targetModel.FanOut = nil
+			targetModel.RESTEndpoint = strings.ReplaceAll(queryModel.RESTEndpoint, fanOutPlaceholder, url.PathEscape(target))
+			responses[i] = h.executeQuery(ctx, query, &targetModel)
+		}(i, target)
+	}
+	wg.Wait()
+
+	if queryModel.FanOutMerge {
+		return mergeFanOutResponses(queryModel.FanOut, responses)
+	}
+
+	var frames data.Frames
+	var errs []error
+	for i, res := range responses {
+		target := queryModel.FanOut[i]
+
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target, res.Error))
+
+			// Keep the failure visible next to the successful targets
+			frame := data.NewFrame(target)
+			frame.Meta = &data.FrameMeta{
+				Notices: []data.Notice{{
+					Severity: data.NoticeSeverityError,
+					Text:     fmt.Sprintf("Fan-out target %q failed: %v", target, res.Error),
+				}},
+			}
+			frames = append(frames, frame)
+			continue
+		}
+
+		for _, frame := range res.Frames {
+			frame.Name = target
+			for _, field := range frame.Fields {
+				if field.Type().Time() {
+					continue
+				}
+				if field.Labels == nil {
+					field.Labels = data.Labels{}
+				}
+				field.Labels["target"] = target
+			}
+			frames = append(frames, frame)
+		}
+	}
+
+	if len(errs) == len(responses) {
+		return joinedErrorResponse(responses, errs)
+	}
+
+	// The target labels only exist now, so name the series after them here
+	if h.config.SeriesNameLabel != "" {
+		h.applySeriesNames(frames)
+	}
+
+	return backend.DataResponse{
+		Frames: frames,
+	}
+}
+
+// mergeFanOutResponses merges the successful fan-out targets into a single
+// frame, reporting failed targets as notices on it
+func mergeFanOutResponses(targets []string, responses []backend.DataResponse) backend.DataResponse {
+	var okTargets []string
+	var okFrames []data.Frames
+	var errs []error
+	for i, res := range responses {
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", targets[i], res.Error))
+			continue
+		}
+		okTargets = append(okTargets, targets[i])
+		okFrames = append(okFrames, res.Frames)
+	}
+
+	if len(errs) == len(responses) {
+		return joinedErrorResponse(responses, errs)
+	}
+
+	frame := mergeFanOutFrames(okTargets, okFrames)
+	for _, err := range errs {
+		addNotice(data.Frames{frame}, data.NoticeSeverityError, fmt.Sprintf("Fan-out target failed: %v", err))
+	}
+
+	return backend.DataResponse{
+		Frames: data.Frames{frame},
+	}
+}
+
 // executeQuery executes a REST API query
 func (h *RESTAPIHandler) executeQuery(ctx context.Context, query backend.DataQuery, queryModel *models.QueryModel) backend.DataResponse {
 	// Build full URL
@@ -52,8 +178,15 @@ func (h *RESTAPIHandler) executeQuery(ctx context.Context, query backend.DataQue
 
 	// Ensure base URL doesn't end with /
 	baseURL = strings.TrimSuffix(baseURL, "/")
-	endpoint := strings.TrimPrefix(queryModel.RESTEndpoint, "/")
-	fullURL := baseURL + "/" + endpoint
+	// Time macros let endpoints, parameters, bodies and headers follow the
+	// panel's time range
+	endpoint := strings.TrimPrefix(expandTimeMacros(queryModel.RESTEndpoint, query), "/")
+	params := make(map[string]string, len(queryModel.RESTParams))
+	for k, v := range queryModel.RESTParams {
+		params[k] = expandTimeMacros(v, query)
+	}
+	rawQuery := expandTimeMacros(queryModel.RESTRawQuery, query)
+	fullURL := appendQueryString(baseURL+"/"+endpoint, params, rawQuery)
 
 	// Determine HTTP method
 	method := strings.ToUpper(queryModel.RESTMethod)
@@ -61,10 +194,40 @@ func (h *RESTAPIHandler) executeQuery(ctx context.Context, query backend.DataQue
 		method = "GET"
 	}
 
-	// Create request body if provided
+	// GraphQL queries are always POSTed as a query/variables document
+	var reqBody string
+	if queryModel.RESTGraphQL {
+		method = "POST"
+		graphQLBody, err := buildGraphQLBody(queryModel)
+		if err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
+		}
+		reqBody = graphQLBody
+	} else if queryModel.RESTBody != "" && (method == "POST" || method == "PUT" || method == "PATCH") {
+		reqBody = expandTimeMacros(interpolateJSONBody(queryModel.RESTBody, queryModel.Variables, queryModel.MultiVariables), query)
+	}
+
+	// Create request body if provided, gzipped when the query opts in
+	compress := compressionEnabled(h.config, queryModel)
 	var bodyReader io.Reader
-	if queryModel.RESTBody != "" && (method == "POST" || method == "PUT" || method == "PATCH") {
-		bodyReader = bytes.NewBufferString(queryModel.RESTBody)
+	var bodyEncoding string
+	var sentBody []byte
+	if reqBody != "" {
+		sentBody = []byte(reqBody)
+		bodyReader = bytes.NewBufferString(reqBody)
+		if compress {
+			gz, err := gzipBody([]byte(reqBody))
+			if err != nil {
+				return backend.DataResponse{
+					Error: fmt.Errorf("failed to compress request body: %w", err),
+				}
+			}
+			sentBody = gz
+			bodyReader = bytes.NewReader(gz)
+			bodyEncoding = "gzip"
+		}
 	}
 
 	// Create HTTP request
@@ -75,76 +238,209 @@ func (h *RESTAPIHandler) executeQuery(ctx context.Context, query backend.DataQue
 		}
 	}
 
-	// Add headers
+	// Add configured headers, then the query's own headers
+	setConfigHeaders(req, h.config.RESTHeaders, h.config.Headers)
 	if queryModel.RESTHeaders != nil {
 		for k, v := range queryModel.RESTHeaders {
-			req.Header.Set(k, v)
+			req.Header.Set(k, expandTimeMacros(v, query))
 		}
 	}
 
 	// Add default headers if not present
-	if req.Header.Get("Content-Type") == "" && bodyReader != nil {
+	if (req.Header.Get("Content-Type") == "" && bodyReader != nil) || queryModel.RESTGraphQL {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if bodyEncoding != "" {
+		req.Header.Set("Content-Encoding", bodyEncoding)
+	}
+	negotiateCompression(req, queryModel)
 
-	// Add authentication
+	// Add authentication and, for signed APIs, the request signature
 	h.addAuthHeaders(req)
+	signRequest(req, sentBody, h.config, time.Now())
 
 	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(ctx, h.client, req, newRetryPolicy(h.config), h.logger)
 	if err != nil {
-		return backend.DataResponse{
-			Error: fmt.Errorf("failed to execute request: %w", err),
-		}
+		return requestErrorResponse(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
+		body, _ := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+		return statusErrorResponse(resp.StatusCode, fmt.Errorf("REST API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	// Read response body, giving up on streams that stay open past MaxWait
+	maxWait := time.Duration(queryModel.MaxWaitMs) * time.Millisecond
+	body, complete, err := readResponseBodyWithin(resp, h.config.MaxResponseBytes, maxWait, h.logger)
+	if err != nil {
 		return backend.DataResponse{
-			Error: fmt.Errorf("REST API returned status %d: %s", resp.StatusCode, string(body)),
+			Error: err,
 		}
 	}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	// The query's declared format wins over a misleading Content-Type
+	format := detectResponseFormat(queryModel.ResponseFormat, resp.Header.Get("Content-Type"))
+
+	// Of a stream still open only complete NDJSON lines are usable
+	if !complete && format == models.ResponseFormatNDJSON {
+		body = body[:bytes.LastIndexByte(body, '\n')+1]
+	}
+
+	// Misconfigured proxies return login/error pages with a 200 status
+	if format != models.ResponseFormatRaw && looksLikeHTML(resp.Header.Get("Content-Type"), body) {
 		return backend.DataResponse{
-			Error: fmt.Errorf("failed to read response: %w", err),
+			Error:       fmt.Errorf("expected %s but received HTML (status %d), check authentication/URL: %s", strings.ToUpper(string(format)), resp.StatusCode, bodySnippet(body)),
+			Status:      backend.StatusBadGateway,
+			ErrorSource: backend.ErrorSourceDownstream,
 		}
 	}
 
-	// Parse JSON response
-	var jsonData interface{}
-	if err := json.Unmarshal(body, &jsonData); err != nil {
+	// Parse response
+	jsonData, err := decodeResponseBody(format, body, queryModel)
+	if err != nil {
+		if !complete {
+			err = fmt.Errorf("response stream was still open after %s and the %d bytes received are not a complete response: %w", maxWait, len(body), err)
+		}
 		return backend.DataResponse{
-			Error: fmt.Errorf("failed to parse JSON response: %w", err),
+			Error: err,
+		}
+	}
+
+	// Unwrap the GraphQL envelope, keeping partial-error messages for a notice
+	var graphQLErrors []string
+	if queryModel.RESTGraphQL {
+		jsonData, graphQLErrors, err = extractGraphQLData(jsonData)
+		if err != nil {
+			return backend.DataResponse{
+				Error:       err,
+				ErrorSource: backend.ErrorSourceDownstream,
+			}
+		}
+	}
+
+	// Point at the part of the response to tabulate
+	tableData := jsonData
+	if queryModel.RESTDataPath != "" {
+		tableData, err = selectDataPath(jsonData, queryModel.RESTDataPath)
+		if err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
 		}
 	}
 
 	// Convert to Grafana data frames
-	frames, err := h.convertToDataFrames(jsonData, query)
+	frames, err := h.convertToDataFrames(tableData, query, queryModel)
 	if err != nil {
 		return backend.DataResponse{
 			Error: fmt.Errorf("failed to convert response: %w", err),
 		}
 	}
+	if len(graphQLErrors) > 0 {
+		addNotice(frames, data.NoticeSeverityWarning, "GraphQL returned errors: "+strings.Join(graphQLErrors, "; "))
+	}
+	if !complete {
+		addNotice(frames, data.NoticeSeverityWarning,
+			fmt.Sprintf("Response stream was still open after %s; showing the data received so far", maxWait))
+	}
+
+	// Derive scaled fields from simple per-column expressions
+	if len(queryModel.FieldExpressions) > 0 {
+		if err := h.applyFieldExpressions(frames, queryModel.FieldExpressions); err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
+		}
+	}
+
+	roundFrameValues(frames, queryModel.RoundDecimals)
+
+	// Name series after the configured label when the fields carry it
+	if h.config.SeriesNameLabel != "" {
+		h.applySeriesNames(frames)
+	}
+
+	// Apply field metadata from self-describing APIs
+	if queryModel.MetaPath != "" {
+		h.applyFieldMetadata(frames, jsonData, queryModel.MetaPath)
+	}
 
 	return backend.DataResponse{
 		Frames: frames,
 	}
 }
 
+// applySeriesNames sets the display name of labeled value fields from the
+// configured series name label, keeping the existing name otherwise
+func (h *RESTAPIHandler) applySeriesNames(frames data.Frames) {
+	for _, frame := range frames {
+		var valueFields []*data.Field
+		for _, field := range frame.Fields {
+			if !field.Type().Time() {
+				valueFields = append(valueFields, field)
+			}
+		}
+
+		for _, field := range valueFields {
+			name, ok := field.Labels[h.config.SeriesNameLabel]
+			if !ok {
+				continue
+			}
+			if len(valueFields) > 1 {
+				name += " " + field.Name
+			}
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			field.Config.DisplayNameFromDS = name
+		}
+	}
+}
+
+// appendQueryString adds structured params followed by the raw query string
+// to a URL, joining with "?" or "&" depending on what the URL already has
+func appendQueryString(rawURL string, params map[string]string, rawQuery string) string {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	var parts []string
+	if encoded := values.Encode(); encoded != "" {
+		parts = append(parts, encoded)
+	}
+	if rawQuery = strings.TrimLeft(rawQuery, "?&"); rawQuery != "" {
+		parts = append(parts, rawQuery)
+	}
+	if len(parts) == 0 {
+		return rawURL
+	}
+
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+		if strings.HasSuffix(rawURL, "?") || strings.HasSuffix(rawURL, "&") {
+			sep = ""
+		}
+	}
+	return rawURL + sep + strings.Join(parts, "&")
+}
+
 // convertToDataFrames converts REST API JSON response to Grafana data frames
-func (h *RESTAPIHandler) convertToDataFrames(jsonData interface{}, query backend.DataQuery) (data.Frames, error) {
+func (h *RESTAPIHandler) convertToDataFrames(jsonData interface{}, query backend.DataQuery, queryModel *models.QueryModel) (data.Frames, error) {
 	var frames data.Frames
 
+	if queryModel.ResponseShape == models.ResponseShapeObjectSeries {
+		return h.objectSeriesToDataFrames(jsonData, queryModel)
+	}
+
 	// Handle different JSON structures
 	switch v := jsonData.(type) {
 	case []interface{}:
 		// Array of objects - treat as time series or table
-		frame, err := h.arrayToDataFrame(v, query)
+		frame, err := h.arrayToDataFrame(v, query, queryModel)
 		if err != nil {
 			return nil, err
 		}
@@ -152,7 +448,7 @@ func (h *RESTAPIHandler) convertToDataFrames(jsonData interface{}, query backend
 
 	case map[string]interface{}:
 		// Object - try to extract time series data
-		frame, err := h.objectToDataFrame(v, query)
+		frame, err := h.objectToDataFrame(v, query, queryModel)
 		if err != nil {
 			return nil, err
 		}
@@ -160,7 +456,72 @@ func (h *RESTAPIHandler) convertToDataFrames(jsonData interface{}, query backend
 
 	default:
 		// Primitive value - create simple frame
-		frame := h.primitiveToDataFrame(jsonData, query)
+		frame, err := h.primitiveToDataFrame(jsonData, query, queryModel)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+
+	// Coerce value fields to the requested type
+	if queryModel.ForceValueType != models.ValueTypeAuto {
+		for _, frame := range frames {
+			if err := coerceFrameValues(frame, queryModel.ForceValueType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return frames, nil
+}
+
+// objectSeriesToDataFrames converts an object of series, each a list of
+// [timestamp, value] pairs, into one time series frame per key
+func (h *RESTAPIHandler) objectSeriesToDataFrames(jsonData interface{}, queryModel *models.QueryModel) (data.Frames, error) {
+	obj, ok := jsonData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("objectSeries response shape requires a JSON object, got %T", jsonData)
+	}
+
+	parser := h.newTimeParser(queryModel.TimeFormats)
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	frames := make(data.Frames, 0, len(names))
+	for _, name := range names {
+		points, ok := obj[name].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("series %q is not an array of [timestamp, value] pairs", name)
+		}
+
+		times := make([]time.Time, 0, len(points))
+		values := make([]*float64, 0, len(points))
+		for i, p := range points {
+			pair, ok := p.([]interface{})
+			if !ok || len(pair) < 2 {
+				return nil, fmt.Errorf("series %q point %d is not a [timestamp, value] pair", name, i)
+			}
+
+			times = append(times, parser.parse(name, pair[0]))
+			if pair[1] == nil || !h.isNumeric(pair[1]) {
+				values = append(values, nil)
+				continue
+			}
+			v := h.toFloat64(pair[1])
+			values = append(values, &v)
+		}
+
+		frame := data.NewFrame(name,
+			data.NewField("time", nil, times),
+			data.NewField(name, nil, values),
+		)
+		frame.Meta = &data.FrameMeta{
+			Type: data.FrameTypeTimeSeriesMulti,
+		}
 		frames = append(frames, frame)
 	}
 
@@ -168,16 +529,30 @@ func (h *RESTAPIHandler) convertToDataFrames(jsonData interface{}, query backend
 }
 
 // arrayToDataFrame converts an array of objects to a data frame
-func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQuery) (*data.Frame, error) {
+func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQuery, queryModel *models.QueryModel) (*data.Frame, error) {
+	// Protect the browser from enormous tables
+	if queryModel.MaxRows > 0 && len(arr) > queryModel.MaxRows {
+		frame, err := h.arrayToDataFrame(sampleRows(arr, queryModel.MaxRows, queryModel.SampleMode), query, queryModel)
+		if err == nil {
+			addNotice(data.Frames{frame}, data.NoticeSeverityWarning,
+				fmt.Sprintf("Showing %d of %d rows (%s sampling)", queryModel.MaxRows, len(arr), sampleModeName(queryModel.SampleMode)))
+		}
+		return frame, err
+	}
+
 	if len(arr) == 0 {
-		return data.NewFrame("", data.NewField("value", nil, []interface{}{})), nil
+		return data.NewFrame("", data.NewField("value", nil, []*float64{})), nil
 	}
 
 	// Check if first element has timestamp field
 	_, ok := arr[0].(map[string]interface{})
 	if !ok {
 		// Not an array of objects, create simple array frame
-		return data.NewFrame("", data.NewField("value", nil, arr)), nil
+		field, err := buildValueField("value", arr, queryModel.ForceValueType)
+		if err != nil {
+			return nil, err
+		}
+		return data.NewFrame("", field), nil
 	}
 
 	// Try to detect time series structure
@@ -188,6 +563,39 @@ func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQ
 	var times []time.Time
 	var hasTimeField bool
 
+	timeKeys := defaultTimeKeys
+	syntheticTime := false
+	if candidates := timeFieldCandidates(queryModel); len(candidates) > 0 {
+		// Explicit candidates replace auto-detection; the first one present
+		// wins, and without any the rows get synthetic timestamps
+		timeKeys = nil
+		for _, k := range candidates {
+			if h.hasTimeKey(arr, []string{k}) {
+				timeKeys = []string{k}
+				break
+			}
+		}
+		syntheticTime = len(timeKeys) == 0
+	} else if queryModel.AutoDetectEpochTime && !h.hasTimeKey(arr, timeKeys) {
+		if epochKey := h.detectEpochColumn(arr); epochKey != "" {
+			timeKeys = append([]string{epochKey}, timeKeys...)
+		}
+	}
+	isTimeKey := func(key string) bool {
+		for _, k := range timeKeys {
+			if k == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	parser := h.newTimeParser(queryModel.TimeFormats)
+
+	// Columns are collected by key across all rows, leaving nil in rows that
+	// lack the key so they render as gaps rather than zero, false or ""
+	columns := make(map[string][]interface{})
+
 	for _, item := range arr {
 		obj, ok := item.(map[string]interface{})
 		if !ok {
@@ -196,9 +604,9 @@ func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQ
 
 		// Try to find timestamp
 		var timestamp time.Time
-		for _, timeKey := range []string{"time", "timestamp", "date", "ts", "datetime"} {
+		for _, timeKey := range timeKeys {
 			if tsVal, exists := obj[timeKey]; exists {
-				timestamp = h.parseTimestamp(tsVal)
+				timestamp = parser.parse(timeKey, tsVal)
 				hasTimeField = true
 				break
 			}
@@ -210,48 +618,56 @@ func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQ
 		}
 
 		times = append(times, timestamp)
+		row := len(times) - 1
 
-		// Extract numeric values
-		if len(valueFields) == 0 {
-			// Initialize fields on first iteration
-			for key, val := range obj {
-				if key == "time" || key == "timestamp" || key == "date" || key == "ts" || key == "datetime" {
+		for key, val := range obj {
+			switch val.(type) {
+			case float64, string, bool:
+				if isTimeKey(key) {
 					continue
 				}
-				if h.isNumeric(val) {
-					valueFields = append(valueFields, data.NewField(key, nil, []float64{}))
+				if _, exists := columns[key]; !exists {
+					columns[key] = make([]interface{}, row, len(arr))
 				}
+				columns[key] = append(columns[key], val)
 			}
 		}
-
-		// Add values to fields
-		fieldIdx := 0
-		for key, val := range obj {
-			if key == "time" || key == "timestamp" || key == "date" || key == "ts" || key == "datetime" {
-				continue
-			}
-			if h.isNumeric(val) {
-				if fieldIdx < len(valueFields) {
-					valueFields[fieldIdx].Append(h.toFloat64(val))
-					fieldIdx++
-				}
+		for key, col := range columns {
+			if len(col) == row {
+				columns[key] = append(col, nil)
 			}
 		}
 	}
 
-	if hasTimeField {
+	valueFields, err := h.buildColumnFields(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasTimeField || syntheticTime {
+		// Time series carry only numeric values; other columns are named in
+		// a notice rather than dropped silently
 		timeField = data.NewField("time", nil, times)
 		frame := data.NewFrame("", timeField)
+		var omitted []string
 		for _, f := range valueFields {
+			if !f.Type().Numeric() {
+				omitted = append(omitted, f.Name)
+				continue
+			}
 			frame.Fields = append(frame.Fields, f)
 		}
 		frame.Meta = &data.FrameMeta{
 			Type: data.FrameTypeTimeSeriesMany,
 		}
+		if len(omitted) > 0 {
+			addNotice(data.Frames{frame}, data.NoticeSeverityInfo,
+				fmt.Sprintf("Non-numeric columns are not part of the time series: %s", strings.Join(omitted, ", ")))
+		}
 		return frame, nil
 	}
 
-	// No time field - create table frame
+	// No time field - create a table frame keeping every column type
 	frame := data.NewFrame("")
 	for _, f := range valueFields {
 		frame.Fields = append(frame.Fields, f)
@@ -259,18 +675,173 @@ func (h *RESTAPIHandler) arrayToDataFrame(arr []interface{}, query backend.DataQ
 	return frame, nil
 }
 
+// timeFieldCandidates returns the configured time field names in priority order
+func timeFieldCandidates(queryModel *models.QueryModel) []string {
+	if queryModel.TimeField == "" {
+		return queryModel.TimeFieldCandidates
+	}
+	return append([]string{queryModel.TimeField}, queryModel.TimeFieldCandidates...)
+}
+
+// buildColumnFields turns the collected columns into nullable fields in key
+// order, as map iteration order is random. Columns whose values are all
+// numeric, including numeric strings, become numbers; other columns keep
+// their string or boolean type, and mixed columns become strings.
+func (h *RESTAPIHandler) buildColumnFields(columns map[string][]interface{}) ([]*data.Field, error) {
+	keys := make([]string, 0, len(columns))
+	for k := range columns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]*data.Field, 0, len(keys))
+	for _, k := range keys {
+		col := columns[k]
+
+		numeric := true
+		for _, v := range col {
+			if v != nil && !h.isNumeric(v) {
+				numeric = false
+				break
+			}
+		}
+		if numeric {
+			values := make([]*float64, len(col))
+			for i, v := range col {
+				if v != nil {
+					f := h.toFloat64(v)
+					values[i] = &f
+				}
+			}
+			fields = append(fields, data.NewField(k, nil, values))
+			continue
+		}
+
+		field, err := buildValueField(k, col, models.ValueTypeAuto)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// hasTimeKey reports whether any object in the array has one of the time keys
+func (h *RESTAPIHandler) hasTimeKey(arr []interface{}, timeKeys []string) bool {
+	for _, item := range arr {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, k := range timeKeys {
+			if _, exists := obj[k]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// detectEpochColumn picks the numeric column that most plausibly holds epoch
+// seconds or milliseconds. Only columns where every row looks like an epoch
+// qualify; ties are broken by the number of strictly increasing rows, then by
+// column name for determinism.
+func (h *RESTAPIHandler) detectEpochColumn(arr []interface{}) string {
+	first, ok := arr[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	keys := make([]string, 0, len(first))
+	for k := range first {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	best, bestScore := "", -1
+	for _, key := range keys {
+		score, prev := 0, 0.0
+		plausible := true
+		for i, item := range arr {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			v, ok := obj[key].(float64)
+			if !ok || !isPlausibleEpoch(v) {
+				plausible = false
+				break
+			}
+			if i > 0 && v > prev {
+				score++
+			}
+			prev = v
+		}
+		if plausible && score > bestScore {
+			best, bestScore = key, score
+		}
+	}
+
+	return best
+}
+
+// isPlausibleEpoch reports whether v is a whole number of seconds or
+// milliseconds between the years 2000 and 2100
+func isPlausibleEpoch(v float64) bool {
+	const minEpoch, maxEpoch = 946684800, 4102444800
+	if v != math.Trunc(v) {
+		return false
+	}
+	return (v >= minEpoch && v <= maxEpoch) || (v >= minEpoch*1000 && v <= maxEpoch*1000)
+}
+
+// sampleRows reduces arr to n rows, keeping the first rows, the last rows,
+// or rows evenly spaced across the whole array
+func sampleRows(arr []interface{}, n int, mode models.SampleMode) []interface{} {
+	switch mode {
+	case models.SampleModeTail:
+		return arr[len(arr)-n:]
+	case models.SampleModeEven:
+		sampled := make([]interface{}, 0, n)
+		step := float64(len(arr)) / float64(n)
+		for i := 0; i < n; i++ {
+			sampled = append(sampled, arr[int(float64(i)*step)])
+		}
+		return sampled
+	default:
+		return arr[:n]
+	}
+}
+
+// sampleModeName returns the display name of a sample mode
+func sampleModeName(mode models.SampleMode) string {
+	if mode == "" {
+		return string(models.SampleModeHead)
+	}
+	return string(mode)
+}
+
 // objectToDataFrame converts an object to a data frame
-func (h *RESTAPIHandler) objectToDataFrame(obj map[string]interface{}, query backend.DataQuery) (*data.Frame, error) {
+func (h *RESTAPIHandler) objectToDataFrame(obj map[string]interface{}, query backend.DataQuery, queryModel *models.QueryModel) (*data.Frame, error) {
 	frame := data.NewFrame("")
 
 	// Check if it's a time series object with data array
 	if dataArr, ok := obj["data"].([]interface{}); ok {
-		return h.arrayToDataFrame(dataArr, query)
+		return h.arrayToDataFrame(dataArr, query, queryModel)
 	}
 
-	// Otherwise, treat as single row table
-	for key, val := range obj {
-		field := data.NewField(key, nil, []interface{}{val})
+	// Otherwise, treat as single row table, in key order as map iteration
+	// order is random
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, err := buildValueField(key, []interface{}{obj[key]}, queryModel.ForceValueType)
+		if err != nil {
+			return nil, err
+		}
 		frame.Fields = append(frame.Fields, field)
 	}
 
@@ -278,11 +849,55 @@ func (h *RESTAPIHandler) objectToDataFrame(obj map[string]interface{}, query bac
 }
 
 // primitiveToDataFrame creates a simple frame from a primitive value
-func (h *RESTAPIHandler) primitiveToDataFrame(val interface{}, query backend.DataQuery) *data.Frame {
+func (h *RESTAPIHandler) primitiveToDataFrame(val interface{}, query backend.DataQuery, queryModel *models.QueryModel) (*data.Frame, error) {
 	now := time.Now()
 	timeField := data.NewField("time", nil, []time.Time{now})
-	valueField := data.NewField("value", nil, []interface{}{val})
-	return data.NewFrame("", timeField, valueField)
+	valueField, err := buildValueField("value", []interface{}{val}, queryModel.ForceValueType)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewFrame("", timeField, valueField), nil
+}
+
+// timeParser parses string timestamps using user-supplied layouts tried in
+// order. The layout that last succeeded for a column is tried first on the
+// next row, keeping a column's parsing consistent.
+type timeParser struct {
+	handler   *RESTAPIHandler
+	layouts   []string
+	preferred map[string]string
+}
+
+// newTimeParser creates a parser for the given Go time layouts
+func (h *RESTAPIHandler) newTimeParser(layouts []string) *timeParser {
+	return &timeParser{
+		handler:   h,
+		layouts:   layouts,
+		preferred: make(map[string]string),
+	}
+}
+
+// parse parses a timestamp value from the named column, falling back to
+// epoch and ISO detection when no layout matches
+func (p *timeParser) parse(column string, val interface{}) time.Time {
+	str, ok := val.(string)
+	if !ok || len(p.layouts) == 0 {
+		return p.handler.parseTimestamp(val)
+	}
+
+	if layout, ok := p.preferred[column]; ok {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t
+		}
+	}
+	for _, layout := range p.layouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			p.preferred[column] = layout
+			return t
+		}
+	}
+
+	return p.handler.parseTimestamp(val)
 }
 
 // parseTimestamp attempts to parse various timestamp formats
@@ -351,88 +966,84 @@ func (h *RESTAPIHandler) toFloat64(val interface{}) float64 {
 
 // addAuthHeaders adds authentication headers to the request
 func (h *RESTAPIHandler) addAuthHeaders(req *http.Request) {
-	if h.config.BearerToken != "" {
-		req.Header.Set("Authorization", "Bearer "+h.config.BearerToken)
-	} else if h.config.APIKey != "" {
-		req.Header.Set("X-API-Key", h.config.APIKey)
-	} else if h.config.BasicAuthUser != "" && h.config.BasicAuthPass != "" {
-		req.SetBasicAuth(h.config.BasicAuthUser, h.config.BasicAuthPass)
-	}
+	setAuthHeaders(req, h.config)
 }
 
-// handleRESTResource handles resource calls for REST API
-func (d *Datasource) handleRESTResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	// Proxy the request to REST API
-	client := &http.Client{Timeout: 30 * time.Second}
+// checkHealth requests the configured health endpoint and verifies the
+// status and, when configured, that the body contains the expected text
+func (h *RESTAPIHandler) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout(h.config))
+	defer cancel()
 
-	// Build URL
-	baseURL := d.config.RESTURL
-	if baseURL == "" {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 400,
-			Body:   []byte(`{"error": "REST API base URL not configured"}`),
-		})
+	// Without a health endpoint a HEAD of the base URL checks reachability
+	method := "HEAD"
+	healthURL := h.config.RESTURL
+	if h.config.RESTHealthEndpoint != "" {
+		method = "GET"
+		healthURL = strings.TrimSuffix(h.config.RESTURL, "/") + "/" + strings.TrimPrefix(h.config.RESTHealthEndpoint, "/")
 	}
 
-	baseURL = strings.TrimSuffix(baseURL, "/")
-	path := strings.TrimPrefix(req.Path, "/")
-	targetURL := baseURL + "/" + path
-
-	if len(req.URL) > 0 && req.URL != req.Path {
-		// Parse URL to extract query string if present
-		if parsedURL, err := url.Parse(req.URL); err == nil && parsedURL.RawQuery != "" {
-			targetURL += "?" + parsedURL.RawQuery
-		}
+	req, err := http.NewRequestWithContext(ctx, method, healthURL, nil)
+	if err != nil {
+		return err
 	}
 
-	var bodyReader io.Reader
-	if len(req.Body) > 0 {
-		bodyReader = bytes.NewReader(req.Body)
-	}
+	setConfigHeaders(req, h.config.RESTHeaders, h.config.Headers)
+	h.addAuthHeaders(req)
 
-	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, targetURL, bodyReader)
+	resp, err := h.client.Do(req)
 	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to create request: %v"}`, err)),
-		})
+		return err
 	}
+	defer resp.Body.Close()
 
-	// Copy headers
-	for k, v := range req.Headers {
-		proxyReq.Header[k] = v
+	// Call out auth failures so they aren't mistaken for connectivity issues
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%s %s was rejected with status %d, check the authentication settings", method, req.URL.Redacted(), resp.StatusCode)
 	}
 
-	// Add auth
-	if d.config.BearerToken != "" {
-		proxyReq.Header.Set("Authorization", "Bearer "+d.config.BearerToken)
-	} else if d.config.APIKey != "" {
-		proxyReq.Header.Set("X-API-Key", d.config.APIKey)
-	} else if d.config.BasicAuthUser != "" && d.config.BasicAuthPass != "" {
-		proxyReq.SetBasicAuth(d.config.BasicAuthUser, d.config.BasicAuthPass)
+	// A health endpoint must succeed, while the base URL only has to answer:
+	// many healthy APIs return 404 or 405 for a HEAD of /
+	switch expected := h.config.RESTHealthExpectedStatus; {
+	case expected != 0:
+		if resp.StatusCode != expected {
+			return fmt.Errorf("expected status %d, got %d", expected, resp.StatusCode)
+		}
+	case h.config.RESTHealthEndpoint != "":
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("expected a 2xx status, got %d", resp.StatusCode)
+		}
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%s %s returned server error status %d", method, req.URL.Redacted(), resp.StatusCode)
 	}
 
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Request failed: %v"}`, err)),
-		})
+	if expected := h.config.RESTHealthExpectedBody; expected != "" && method == "GET" {
+		body, err := readResponseBody(resp, h.config.MaxResponseBytes, h.logger)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(body), expected) {
+			return fmt.Errorf("expected body to contain %q, got %q", expected, bodySnippet(body))
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	return nil
+}
+
+// handleRESTResource handles resource calls for REST API
+func (d *Datasource) handleRESTResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	// Build URL
+	baseURL := d.config.RESTURL
+	if baseURL == "" {
 		return sender.Send(&backend.CallResourceResponse{
-			Status: 500,
-			Body:   []byte(fmt.Sprintf(`{"error": "Failed to read response: %v"}`, err)),
+			Status: 400,
+			Body:   []byte(`{"error": "REST API base URL not configured"}`),
 		})
 	}
 
-	return sender.Send(&backend.CallResourceResponse{
-		Status: resp.StatusCode,
-		Headers: resp.Header,
-		Body:   body,
-	})
-}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	path := strings.TrimPrefix(req.Path, "/")
 
+	// Proxy the request to REST API
+	return d.proxyResource(ctx, proxyTarget{URL: baseURL + "/" + path, Headers: d.config.RESTHeaders, Sign: true}, req, sender)
+}
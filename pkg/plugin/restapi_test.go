@@ -0,0 +1,641 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestArrayConversionIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       map[string][]string
+		wantOrder  []string
+		wantNotice string
+	}{
+		{
+			name: "table",
+			body: `[
+				{"cpu": 1, "mem": 10, "disk": 100, "net": 1000, "host": "a", "up": true},
+				{"cpu": 2, "mem": 20, "disk": 200, "net": 2000, "host": "b", "up": false},
+				{"cpu": 3, "mem": 30, "disk": 300, "net": 3000, "host": "c", "up": true}
+			]`,
+			want: map[string][]string{
+				"cpu":  {"1", "2", "3"},
+				"mem":  {"10", "20", "30"},
+				"disk": {"100", "200", "300"},
+				"net":  {"1000", "2000", "3000"},
+				"host": {"a", "b", "c"},
+				"up":   {"true", "false", "true"},
+			},
+		},
+		{
+			name: "single object",
+			body: `{"net": 1000, "cpu": 1, "host": "a", "up": true, "mem": 10, "disk": 100}`,
+			want: map[string][]string{
+				"cpu":  {"1"},
+				"mem":  {"10"},
+				"disk": {"100"},
+				"net":  {"1000"},
+				"host": {"a"},
+				"up":   {"true"},
+			},
+			wantOrder: []string{"cpu", "disk", "host", "mem", "net", "up"},
+		},
+		{
+			name: "time series",
+			body: `[
+				{"time": "2024-01-01T10:00:00Z", "cpu": 1, "mem": 10, "disk": 100, "net": 1000, "host": "a", "up": true},
+				{"time": "2024-01-01T10:01:00Z", "cpu": 2, "mem": 20, "disk": 200, "net": 2000, "host": "b", "up": false},
+				{"time": "2024-01-01T10:02:00Z", "cpu": 3, "mem": 30, "disk": 300, "net": 3000, "host": "c", "up": true}
+			]`,
+			want: map[string][]string{
+				"time": {"2024-01-01 10:00:00 +0000 UTC", "2024-01-01 10:01:00 +0000 UTC", "2024-01-01 10:02:00 +0000 UTC"},
+				"cpu":  {"1", "2", "3"},
+				"mem":  {"10", "20", "30"},
+				"disk": {"100", "200", "300"},
+				"net":  {"1000", "2000", "3000"},
+			},
+			wantNotice: "host, up",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var firstOrder []string
+			for run := 0; run < 50; run++ {
+				frame, err := serveBody(t, "application/json", tt.body, nil)
+				if err != nil {
+					t.Fatalf("run %d: query failed: %v", run, err)
+				}
+
+				var order []string
+				for _, field := range frame.Fields {
+					order = append(order, field.Name)
+				}
+				if firstOrder == nil {
+					firstOrder = order
+					if tt.wantOrder != nil && !reflect.DeepEqual(order, tt.wantOrder) {
+						t.Fatalf("field order %v, want %v", order, tt.wantOrder)
+					}
+				} else if !reflect.DeepEqual(order, firstOrder) {
+					t.Fatalf("run %d: field order %v, first run had %v", run, order, firstOrder)
+				}
+
+				if got := frameColumns(frame); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("run %d: columns = %v, want %v", run, got, tt.want)
+				}
+				if tt.wantNotice != "" && !hasNotice(frame, tt.wantNotice) {
+					t.Fatalf("run %d: frame lacks notice %q", run, tt.wantNotice)
+				}
+			}
+		})
+	}
+}
+
+func TestArrayConversionAlignsHeterogeneousRows(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string][]string
+	}{
+		{
+			name: "later rows omit keys",
+			body: `[{"a": 1, "b": 2, "c": "x"}, {"a": 3}, {"b": 4, "c": "y"}]`,
+			want: map[string][]string{
+				"a": {"1", "3", "null"},
+				"b": {"2", "null", "4"},
+				"c": {"x", "null", "y"},
+			},
+		},
+		{
+			name: "later rows add keys",
+			body: `[{"a": 1}, {"a": 2, "b": 5}, {"a": 3, "c": true}]`,
+			want: map[string][]string{
+				"a": {"1", "2", "3"},
+				"b": {"null", "5", "null"},
+				"c": {"null", "null", "true"},
+			},
+		},
+		{
+			name: "explicit nulls and nested values",
+			body: `[{"a": null, "b": {"x": 1}}, {"a": 2, "b": [1]}]`,
+			want: map[string][]string{
+				"a": {"null", "2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, nil)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+
+			// Rows without timestamps get synthetic ones
+			columns := frameColumns(frame)
+			delete(columns, "time")
+
+			rows := -1
+			for _, field := range frame.Fields {
+				if rows == -1 {
+					rows = field.Len()
+				} else if field.Len() != rows {
+					t.Errorf("field %s has %d values, want %d", field.Name, field.Len(), rows)
+				}
+			}
+			if !reflect.DeepEqual(columns, tt.want) {
+				t.Errorf("columns = %v, want %v", columns, tt.want)
+			}
+		})
+	}
+}
+
+func TestArraySampling(t *testing.T) {
+	body := `[{"n": 0}, {"n": 1}, {"n": 2}, {"n": 3}, {"n": 4}, {"n": 5}, {"n": 6}, {"n": 7}, {"n": 8}, {"n": 9}]`
+
+	tests := []struct {
+		name       string
+		mode       string
+		want       []string
+		wantNotice string
+		wantErr    string
+	}{
+		{name: "default", want: []string{"0", "1", "2"}, wantNotice: "Showing 3 of 10 rows (head sampling)"},
+		{name: "head", mode: "head", want: []string{"0", "1", "2"}, wantNotice: "Showing 3 of 10 rows (head sampling)"},
+		{name: "tail", mode: "tail", want: []string{"7", "8", "9"}, wantNotice: "Showing 3 of 10 rows (tail sampling)"},
+		{name: "even", mode: "even", want: []string{"0", "3", "6"}, wantNotice: "Showing 3 of 10 rows (even sampling)"},
+		{name: "invalid", mode: "random", wantErr: `invalid sample mode "random"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", body, map[string]interface{}{"maxRows": 3, "sampleMode": tt.mode})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+
+			if got := frameColumns(frame)["n"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("n = %v, want %v", got, tt.want)
+			}
+			if !hasNotice(frame, tt.wantNotice) {
+				t.Errorf("missing notice %q", tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestAppendQueryString(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		params   map[string]string
+		rawQuery string
+		want     string
+	}{
+		{name: "nothing to add", url: "http://api/data", want: "http://api/data"},
+		{name: "params are encoded", url: "http://api/data", params: map[string]string{"q": "a b&c", "env": "prod"}, want: "http://api/data?env=prod&q=a+b%26c"},
+		{name: "raw query keeps repeated keys", url: "http://api/data", rawQuery: "?tag=a&tag=b", want: "http://api/data?tag=a&tag=b"},
+		{name: "params before raw query", url: "http://api/data", params: map[string]string{"tag": "x"}, rawQuery: "tag=y", want: "http://api/data?tag=x&tag=y"},
+		{name: "endpoint with a query", url: "http://api/data?v=2", params: map[string]string{"env": "prod"}, want: "http://api/data?v=2&env=prod"},
+		{name: "endpoint ending in a separator", url: "http://api/data?", rawQuery: "&env=prod", want: "http://api/data?env=prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendQueryString(tt.url, tt.params, tt.rawQuery); got != tt.want {
+				t.Errorf("appendQueryString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRESTQueryParamsPassThrough(t *testing.T) {
+	var rawQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{
+		"queryType":    "rest",
+		"restEndpoint": "/data?v=2",
+		"restParams":   map[string]string{"from": "${__from}"},
+		"restRawQuery": "tag=a&tag=b",
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+
+	if want := "v=2&from=1704103200000&tag=a&tag=b"; rawQuery != want {
+		t.Errorf("query = %q, want %q", rawQuery, want)
+	}
+}
+
+// frameTimes returns the name and UTC values of a frame's time field
+func frameTimes(t *testing.T, frame *data.Frame) (string, []time.Time) {
+	t.Helper()
+
+	for _, field := range frame.Fields {
+		if !field.Type().Time() {
+			continue
+		}
+		times := make([]time.Time, field.Len())
+		for i := range times {
+			switch v := field.At(i).(type) {
+			case time.Time:
+				times[i] = v.UTC()
+			case *time.Time:
+				if v != nil {
+					times[i] = v.UTC()
+				}
+			}
+		}
+		return field.Name, times
+	}
+	t.Fatal("frame has no time field")
+	return "", nil
+}
+
+func TestEpochTimeDetection(t *testing.T) {
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		body      string
+		wantTimes []time.Time
+		wantValue []string
+	}{
+		{
+			name:      "epoch seconds",
+			body:      `[{"created": 1704103200, "cpu": 1}, {"created": 1704103260, "cpu": 2}]`,
+			wantTimes: []time.Time{first, second},
+			wantValue: []string{"1", "2"},
+		},
+		{
+			name:      "epoch milliseconds",
+			body:      `[{"created": 1704103200000, "cpu": 1}, {"created": 1704103260000, "cpu": 2}]`,
+			wantTimes: []time.Time{first, second},
+			wantValue: []string{"1", "2"},
+		},
+		{
+			name:      "increasing column wins",
+			body:      `[{"expires": 1704200000, "seen": 1704103200, "cpu": 1}, {"expires": 1704200000, "seen": 1704103260, "cpu": 2}]`,
+			wantTimes: []time.Time{first, second},
+			wantValue: []string{"1", "2"},
+		},
+		{
+			name:      "named time key takes precedence",
+			body:      `[{"time": "2024-01-01T10:00:00Z", "seen": 1, "cpu": 1}, {"time": "2024-01-01T10:01:00Z", "seen": 2, "cpu": 2}]`,
+			wantTimes: []time.Time{first, second},
+			wantValue: []string{"1", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, map[string]interface{}{"autoDetectEpochTime": true})
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+
+			_, times := frameTimes(t, frame)
+			if !reflect.DeepEqual(times, tt.wantTimes) {
+				t.Errorf("times = %v, want %v", times, tt.wantTimes)
+			}
+			if got := frameColumns(frame)["cpu"]; !reflect.DeepEqual(got, tt.wantValue) {
+				t.Errorf("cpu = %v, want %v", got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestEpochTimeDetectionDisabled(t *testing.T) {
+	frame, err := serveBody(t, "application/json", `[{"created": 1704103200, "cpu": 1}, {"created": 1704103260, "cpu": 2}]`, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	// Without detection the epoch stays a number next to synthetic times
+	if got := frameColumns(frame)["created"]; !reflect.DeepEqual(got, []string{"1.7041032e+09", "1.70410326e+09"}) {
+		t.Errorf("created = %v, want the raw numbers", got)
+	}
+}
+
+func TestObjectSeriesShape(t *testing.T) {
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	model := map[string]interface{}{"responseShape": "objectSeries"}
+
+	res := serveREST(t, "application/json", `{"mem": [[1704103200, "5"]], "cpu": [[1704103200, 1], [1704103260, null]]}`, model)
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames, want one per key", len(res.Frames))
+	}
+
+	want := []struct {
+		name   string
+		times  []time.Time
+		values []string
+	}{
+		{name: "cpu", times: []time.Time{first, first.Add(time.Minute)}, values: []string{"1", "null"}},
+		{name: "mem", times: []time.Time{first}, values: []string{"5"}},
+	}
+	for i, w := range want {
+		frame := res.Frames[i]
+		if frame.Name != w.name {
+			t.Errorf("frame %d = %q, want %q", i, frame.Name, w.name)
+		}
+		if _, times := frameTimes(t, frame); !reflect.DeepEqual(times, w.times) {
+			t.Errorf("%s times = %v, want %v", w.name, times, w.times)
+		}
+		if got := frameColumns(frame)[w.name]; !reflect.DeepEqual(got, w.values) {
+			t.Errorf("%s values = %v, want %v", w.name, got, w.values)
+		}
+	}
+}
+
+func TestObjectSeriesShapeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "not an object", body: `[[1704103200, 1]]`, wantErr: "requires a JSON object"},
+		{name: "series not an array", body: `{"cpu": 1}`, wantErr: `series "cpu" is not an array`},
+		{name: "point not a pair", body: `{"cpu": [[1704103200]]}`, wantErr: `series "cpu" point 0 is not a [timestamp, value] pair`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveREST(t, "application/json", tt.body, map[string]interface{}{"responseShape": "objectSeries"})
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", res.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRESTSeriesNameLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		label     string
+		wantNames map[string]string
+	}{
+		{name: "single value field", body: `[{"value": 1}]`, label: "target", wantNames: map[string]string{"value": "eu"}},
+		{name: "several value fields", body: `[{"cpu": 1, "mem": 2}]`, label: "target", wantNames: map[string]string{"cpu": "eu cpu", "mem": "eu mem"}},
+		{name: "label absent", body: `[{"value": 1}]`, label: "region", wantNames: map[string]string{"value": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			// Fan-out labels each value field with its target
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL, "seriesNameLabel": tt.label}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/{{target}}/data", "fanOut": []string{"eu"}})
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			names := map[string]string{}
+			for _, field := range res.Frames[0].Fields {
+				if field.Type().Time() {
+					continue
+				}
+				names[field.Name] = ""
+				if field.Config != nil {
+					names[field.Name] = field.Config.DisplayNameFromDS
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("display names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestRESTTimeFormats(t *testing.T) {
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		body      string
+		formats   []string
+		wantTimes []time.Time
+	}{
+		{
+			name:      "single layout",
+			body:      `[{"time": "2024-01-01 10:00:00", "v": 1}, {"time": "2024-01-01 10:01:00", "v": 2}]`,
+			formats:   []string{"2006-01-02 15:04:05"},
+			wantTimes: []time.Time{first, second},
+		},
+		{
+			name:      "layouts tried in order",
+			body:      `[{"time": "01/01/2024 10:00", "v": 1}, {"time": "2024-01-01 10:01:00", "v": 2}]`,
+			formats:   []string{"2006-01-02 15:04:05", "01/02/2006 15:04"},
+			wantTimes: []time.Time{first, second},
+		},
+		{
+			name:      "falls back to RFC 3339 and epochs",
+			body:      `[{"time": "2024-01-01T10:00:00Z", "v": 1}, {"time": "1704103260", "v": 2}]`,
+			formats:   []string{"01/02/2006 15:04"},
+			wantTimes: []time.Time{first, second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, map[string]interface{}{"timeFormats": tt.formats})
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+
+			if _, times := frameTimes(t, frame); !reflect.DeepEqual(times, tt.wantTimes) {
+				t.Errorf("times = %v, want %v", times, tt.wantTimes)
+			}
+		})
+	}
+}
+
+func TestRESTNullableColumns(t *testing.T) {
+	const body = `[
+		{"name": "a", "enabled": true, "count": 1},
+		{"enabled": false},
+		{"name": "c", "count": "3"},
+		{"name": null, "enabled": null}
+	]`
+
+	frame, err := serveBody(t, "application/json", body, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantTypes := map[string]data.FieldType{
+		"count":   data.FieldTypeNullableFloat64,
+		"enabled": data.FieldTypeNullableBool,
+		"name":    data.FieldTypeNullableString,
+	}
+	for _, field := range frame.Fields {
+		if field.Type() != wantTypes[field.Name] {
+			t.Errorf("field %s type = %s, want %s", field.Name, field.Type(), wantTypes[field.Name])
+		}
+	}
+
+	// Missing keys are gaps, not false or empty strings
+	want := map[string][]string{
+		"count":   {"1", "null", "3", "null"},
+		"enabled": {"true", "false", "null", "null"},
+		"name":    {"a", "null", "c", "null"},
+	}
+	if got := frameColumns(frame); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+}
+
+func TestTimeFieldCandidates(t *testing.T) {
+	const body = `[
+		{"created_at": "2024-01-01T09:00:00Z", "updated_at": "2024-01-01T10:30:00Z", "value": 1},
+		{"created_at": "2024-01-01T09:05:00Z", "updated_at": "2024-01-01T10:35:00Z", "value": 2}
+	]`
+	at := func(clock string) time.Time {
+		ts, _ := time.Parse(time.RFC3339, "2024-01-01T"+clock+"Z")
+		return ts
+	}
+
+	tests := []struct {
+		name      string
+		model     map[string]interface{}
+		wantTimes []time.Time
+	}{
+		{
+			name:      "first present candidate",
+			model:     map[string]interface{}{"timeFieldCandidates": []string{"deleted_at", "updated_at", "created_at"}},
+			wantTimes: []time.Time{at("10:30:00"), at("10:35:00")},
+		},
+		{
+			name:      "time field comes first",
+			model:     map[string]interface{}{"timeField": "created_at", "timeFieldCandidates": []string{"updated_at"}},
+			wantTimes: []time.Time{at("09:00:00"), at("09:05:00")},
+		},
+		{
+			name:      "missing time field falls back to candidates",
+			model:     map[string]interface{}{"timeField": "deleted_at", "timeFieldCandidates": []string{"updated_at"}},
+			wantTimes: []time.Time{at("10:30:00"), at("10:35:00")},
+		},
+		{
+			name:      "no candidate present uses synthetic times",
+			model:     map[string]interface{}{"timeFieldCandidates": []string{"deleted_at"}},
+			wantTimes: []time.Time{at("10:00:00"), at("10:01:00")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", body, tt.model)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, times := frameTimes(t, frame)
+			if !reflect.DeepEqual(times, tt.wantTimes) {
+				t.Errorf("times = %v, want %v", times, tt.wantTimes)
+			}
+			if got := frameColumns(frame)["value"]; !reflect.DeepEqual(got, []string{"1", "2"}) {
+				t.Errorf("values = %v, want [1 2]", got)
+			}
+		})
+	}
+}
+
+func TestRESTMixedTypeColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantTypes  map[string]data.FieldType
+		wantCols   map[string][]string
+		wantNotice string
+	}{
+		{
+			name: "table keeps every column",
+			body: `[
+				{"name": "api", "active": true, "count": 3, "code": 200},
+				{"name": "web", "active": false, "count": 5, "code": "n/a"}
+			]`,
+			wantTypes: map[string]data.FieldType{
+				"active": data.FieldTypeNullableBool,
+				"code":   data.FieldTypeNullableString,
+				"count":  data.FieldTypeNullableFloat64,
+				"name":   data.FieldTypeNullableString,
+			},
+			wantCols: map[string][]string{
+				"active": {"true", "false"},
+				"code":   {"200", "n/a"},
+				"count":  {"3", "5"},
+				"name":   {"api", "web"},
+			},
+		},
+		{
+			name: "time series keeps numbers",
+			body: `[
+				{"time": "2024-01-01T10:00:00Z", "name": "api", "active": true, "count": 3},
+				{"time": "2024-01-01T10:01:00Z", "name": "web", "active": false, "count": 5}
+			]`,
+			wantTypes: map[string]data.FieldType{
+				"time":  data.FieldTypeTime,
+				"count": data.FieldTypeNullableFloat64,
+			},
+			wantNotice: "Non-numeric columns are not part of the time series: active, name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotTypes := make(map[string]data.FieldType, len(frame.Fields))
+			for _, field := range frame.Fields {
+				gotTypes[field.Name] = field.Type()
+			}
+			if !reflect.DeepEqual(gotTypes, tt.wantTypes) {
+				t.Errorf("field types = %v, want %v", gotTypes, tt.wantTypes)
+			}
+			if tt.wantCols != nil {
+				if got := frameColumns(frame); !reflect.DeepEqual(got, tt.wantCols) {
+					t.Errorf("columns = %v, want %v", got, tt.wantCols)
+				}
+			}
+			if tt.wantNotice != "" && !hasNotice(frame, tt.wantNotice) {
+				t.Errorf("missing notice %q", tt.wantNotice)
+			}
+		})
+	}
+}
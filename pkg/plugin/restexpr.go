@@ -0,0 +1,268 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// errDivisionByZero is returned when an expression divides by zero
+var errDivisionByZero = errors.New("division by zero")
+
+// exprNode is a node of a parsed field expression
+type exprNode interface {
+	eval(lookup func(column string) (float64, bool)) (float64, error)
+	columns() []string
+}
+
+type numberNode float64
+
+func (n numberNode) eval(func(string) (float64, bool)) (float64, error) { return float64(n), nil }
+func (n numberNode) columns() []string                                  { return nil }
+
+type columnNode string
+
+func (n columnNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	v, ok := lookup(string(n))
+	if !ok {
+		return 0, fmt.Errorf("unknown column %q", string(n))
+	}
+	return v, nil
+}
+func (n columnNode) columns() []string { return []string{string(n)} }
+
+type negateNode struct{ operand exprNode }
+
+func (n negateNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	v, err := n.operand.eval(lookup)
+	return -v, err
+}
+func (n negateNode) columns() []string { return n.operand.columns() }
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	l, err := n.left.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	default:
+		if r == 0 {
+			return 0, errDivisionByZero
+		}
+		return l / r, nil
+	}
+}
+func (n binaryNode) columns() []string { return append(n.left.columns(), n.right.columns()...) }
+
+// exprParser is a recursive descent parser for field expressions. Only
+// numbers, column references, parentheses and + - * / are accepted.
+type exprParser struct {
+	input string
+	pos   int
+}
+
+// parseFieldExpression parses an arithmetic expression such as "bytes / 1024"
+func parseFieldExpression(input string) (exprNode, error) {
+	p := &exprParser{input: input}
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return node, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '*' && p.input[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '-' {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return numberNode(v), nil
+
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '_' || p.input[p.pos] == '.' ||
+			unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		return columnNode(p.input[start:p.pos]), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos)
+}
+
+// applyFieldExpressions adds a derived nullable float field per expression to
+// each frame containing the referenced columns. Rows that divide by zero or
+// reference a null value produce null.
+func (h *RESTAPIHandler) applyFieldExpressions(frames data.Frames, expressions map[string]string) error {
+	names := make([]string, 0, len(expressions))
+	for name := range expressions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		expr, err := parseFieldExpression(expressions[name])
+		if err != nil {
+			return fmt.Errorf("invalid expression for field %q: %w", name, err)
+		}
+
+		for _, frame := range frames {
+			rows, err := frame.RowLen()
+			if err != nil {
+				return err
+			}
+
+			columns := make(map[string]*data.Field)
+			missing := false
+			for _, col := range expr.columns() {
+				field, _ := frame.FieldByName(col)
+				if field == nil || !field.Type().Numeric() {
+					missing = true
+					break
+				}
+				columns[col] = field
+			}
+			if missing {
+				continue
+			}
+
+			values := make([]*float64, rows)
+			divByZero := 0
+			for i := 0; i < rows; i++ {
+				v, err := expr.eval(func(col string) (float64, bool) {
+					field, ok := columns[col]
+					if !ok {
+						return 0, false
+					}
+					f, err := field.FloatAt(i)
+					return f, err == nil
+				})
+				if errors.Is(err, errDivisionByZero) {
+					divByZero++
+					continue
+				}
+				if err != nil || math.IsNaN(v) {
+					continue
+				}
+				values[i] = &v
+			}
+
+			frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+			if divByZero > 0 {
+				addNotice(data.Frames{frame}, data.NoticeSeverityWarning,
+					fmt.Sprintf("Expression %q divided by zero in %d rows; those values are null", strings.TrimSpace(expressions[name]), divByZero))
+			}
+		}
+	}
+
+	return nil
+}
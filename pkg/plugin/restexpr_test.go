@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldExpression(t *testing.T) {
+	columns := map[string]float64{"bytes": 2048, "total": 4096, "disk.used": 10}
+	lookup := func(col string) (float64, bool) {
+		v, ok := columns[col]
+		return v, ok
+	}
+
+	tests := []struct {
+		expr    string
+		want    float64
+		wantErr string
+	}{
+		{expr: "bytes / 1024", want: 2},
+		{expr: "1 + 2 * 3", want: 7},
+		{expr: "(1 + 2) * 3", want: 9},
+		{expr: "-bytes + total", want: 2048},
+		{expr: "bytes / total * 100", want: 50},
+		{expr: "disk.used * 0.5", want: 5},
+		{expr: "bytes / 0", wantErr: "division by zero"},
+		{expr: "missing * 2", wantErr: `unknown column "missing"`},
+		{expr: "(bytes + 1", wantErr: "missing closing parenthesis"},
+		{expr: "bytes +", wantErr: "unexpected end of expression"},
+		{expr: "bytes % 2", wantErr: `unexpected '%' at position 6`},
+		{expr: "1..2", wantErr: `invalid number "1..2"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := parseFieldExpression(tt.expr)
+			if err == nil {
+				var v float64
+				v, err = node.eval(lookup)
+				if err == nil && v != tt.want {
+					t.Errorf("%s = %v, want %v", tt.expr, v, tt.want)
+				}
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyFieldExpressions(t *testing.T) {
+	body := `[{"bytes": 2048, "total": 4096}, {"bytes": 1024, "total": 0}, {"bytes": null, "total": 10}]`
+
+	frame, err := serveBody(t, "application/json", body, map[string]interface{}{
+		"fieldExpressions": map[string]string{
+			"kb":      "bytes / 1024",
+			"pct":     "bytes / total * 100",
+			"ignored": "latency * 2",
+		},
+	})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	columns := frameColumns(frame)
+	if got, want := columns["kb"], []string{"2", "1", "null"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kb = %v, want %v", got, want)
+	}
+	if got, want := columns["pct"], []string{"50", "null", "null"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pct = %v, want %v", got, want)
+	}
+	if _, ok := columns["ignored"]; ok {
+		t.Error("expression over a missing column added a field")
+	}
+	if !hasNotice(frame, `Expression "bytes / total * 100" divided by zero in 1 rows`) {
+		t.Error("missing division by zero notice")
+	}
+}
+
+func TestApplyFieldExpressionsInvalid(t *testing.T) {
+	_, err := serveBody(t, "application/json", `[{"bytes": 1}]`, map[string]interface{}{
+		"fieldExpressions": map[string]string{"kb": "bytes /"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `invalid expression for field "kb"`) {
+		t.Errorf("error = %v, want an invalid expression error", err)
+	}
+}
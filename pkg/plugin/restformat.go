@@ -0,0 +1,322 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// detectResponseFormat returns the query's explicit response format, or
+// infers one from the Content-Type, defaulting to JSON since many servers
+// send JSON with a generic type
+func detectResponseFormat(explicit models.ResponseFormat, contentType string) models.ResponseFormat {
+	if explicit != models.ResponseFormatAuto {
+		return explicit
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-ndjson" || mediaType == "application/jsonl" || mediaType == "application/json-seq":
+		return models.ResponseFormatNDJSON
+	case mediaType == "text/csv":
+		return models.ResponseFormatCSV
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return models.ResponseFormatXML
+	}
+	return models.ResponseFormatJSON
+}
+
+// decodeResponseBody decodes a REST body into the generic JSON shape the
+// frame conversion works on. Raw bodies become a single string value.
+func decodeResponseBody(format models.ResponseFormat, body []byte, queryModel *models.QueryModel) (interface{}, error) {
+	switch format {
+	case models.ResponseFormatJSON:
+		var jsonData interface{}
+		if err := json.Unmarshal(body, &jsonData); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+		return jsonData, nil
+
+	case models.ResponseFormatNDJSON:
+		return decodeNDJSON(body)
+
+	case models.ResponseFormatCSV:
+		return decodeCSV(body, queryModel.CSVDelimiter, !queryModel.CSVNoHeader)
+
+	case models.ResponseFormatXML:
+		doc, err := decodeXML(body)
+		if err != nil {
+			return nil, err
+		}
+		// An explicit data path selects the rows itself
+		if queryModel.RESTDataPath != "" {
+			return doc, nil
+		}
+		return repeatedXMLElement(doc)
+
+	case models.ResponseFormatRaw:
+		return string(body), nil
+	}
+
+	return nil, fmt.Errorf("unsupported response format %q", format)
+}
+
+// decodeNDJSON decodes newline-delimited JSON into an array of values,
+// skipping blank lines
+func decodeNDJSON(body []byte) (interface{}, error) {
+	var rows []interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var row interface{}
+		if err := json.Unmarshal(text, &row); err != nil {
+			return nil, fmt.Errorf("failed to parse NDJSON line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON response: %w", err)
+	}
+	if rows == nil {
+		rows = []interface{}{}
+	}
+	return rows, nil
+}
+
+// decodeCSV decodes a CSV body into an array of row objects keyed by the
+// header row (or column1, column2, ... without one). Repeated header names
+// get a numeric suffix, e.g. value and value_2. Cells stay strings so the
+// column type inference decides between numbers and text; spaces around
+// unquoted cells are trimmed and empty cells become nulls.
+func decodeCSV(body []byte, delimiter string, header bool) (interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	if delimiter != "" {
+		if delimiter == `\t` {
+			delimiter = "\t"
+		}
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == utf8.RuneError {
+			return nil, fmt.Errorf("CSV delimiter must be a single character, got %q", delimiter)
+		}
+		reader.Comma = r
+	}
+
+	// The reader unquotes cells, so quoting is looked up in the body
+	lineStarts := []int{0}
+	for i, b := range body {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	quoted := func(field int) bool {
+		line, column := reader.FieldPos(field)
+		offset := lineStarts[line-1] + column - 1
+		return offset < len(body) && body[offset] == '"'
+	}
+
+	var names []string
+	rows := []interface{}{}
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV response: %w", err)
+		}
+		line++
+
+		if header && names == nil {
+			names = make([]string, len(record))
+			for i, name := range record {
+				name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+				if name == "" {
+					name = fmt.Sprintf("column%d", i+1)
+				}
+				names[i] = name
+			}
+			names = uniqueNames(names)
+			continue
+		}
+
+		row := make(map[string]interface{}, len(record))
+		for i, cell := range record {
+			name := fmt.Sprintf("column%d", i+1)
+			if i < len(names) {
+				name = names[i]
+			} else if header {
+				return nil, fmt.Errorf("CSV line %d has %d fields but the header has %d", line, len(record), len(names))
+			}
+			if !quoted(i) {
+				cell = strings.TrimSpace(cell)
+			}
+			if cell == "" {
+				row[name] = nil
+				continue
+			}
+			row[name] = cell
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// uniqueNames suffixes repeated names with _2, _3, ... so no column
+// overwrites another
+func uniqueNames(names []string) []string {
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = true
+	}
+
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s_%d", name, n)
+			if !taken[candidate] {
+				names[i] = candidate
+				taken[candidate] = true
+				seen[candidate] = true
+				break
+			}
+		}
+	}
+	return names
+}
+
+// maxXMLDepth bounds element nesting so pathological documents fail fast
+const maxXMLDepth = 64
+
+// decodeXML decodes an XML body into the generic JSON shape, keyed by the
+// root element: elements become objects of their attributes and children,
+// repeated children become arrays and text-only elements become strings.
+// Namespaces are dropped.
+func decodeXML(body []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("XML response has no root element")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse XML response: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root, err := decodeXMLElement(decoder, start, 1)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
+	}
+}
+
+// decodeXMLElement decodes the element opened by start
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > maxXMLDepth {
+		return nil, fmt.Errorf("XML response nests deeper than %d elements", maxXMLDepth)
+	}
+
+	node := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		node[attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse XML response: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(existing, child)
+			default:
+				node[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				if content == "" {
+					return nil, nil
+				}
+				return content, nil
+			}
+			if content != "" {
+				node["#text"] = content
+			}
+			return node, nil
+		}
+	}
+}
+
+// repeatedXMLElement descends through single-child wrapper elements to the
+// first repeated element, the usual <rows><row/>...</rows> layout. A lone
+// flat element is returned as is; anything else needs an explicit data path.
+func repeatedXMLElement(doc interface{}) (interface{}, error) {
+	node := doc
+	for {
+		m, ok := node.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			break
+		}
+		var child interface{}
+		for _, v := range m {
+			child = v
+		}
+		if _, ok := child.([]interface{}); ok {
+			return child, nil
+		}
+		if _, ok := child.(map[string]interface{}); !ok {
+			break
+		}
+		node = child
+	}
+
+	if m, ok := node.(map[string]interface{}); ok {
+		for _, v := range m {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, errors.New("XML response has no repeated element to tabulate; set the data path to the repeating element (e.g. \"rows.row\")")
+			}
+		}
+	}
+	return node, nil
+}
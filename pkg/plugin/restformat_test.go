@@ -0,0 +1,294 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameColumns returns the frame's values by field name, formatted with
+// pointers dereferenced and nulls as "null"
+func frameColumns(frame *data.Frame) map[string][]string {
+	columns := make(map[string][]string, len(frame.Fields))
+	for _, field := range frame.Fields {
+		values := make([]string, field.Len())
+		for i := range values {
+			v := reflect.ValueOf(field.At(i))
+			switch {
+			case v.Kind() == reflect.Ptr && v.IsNil():
+				values[i] = "null"
+			case v.Kind() == reflect.Ptr:
+				values[i] = fmt.Sprint(v.Elem().Interface())
+			default:
+				values[i] = fmt.Sprint(v.Interface())
+			}
+		}
+		columns[field.Name] = values
+	}
+	return columns
+}
+
+// serveREST runs a REST query against a server answering with body
+func serveREST(t *testing.T, contentType, body string, model map[string]interface{}) backend.DataResponse {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	query := map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}
+	for k, v := range model {
+		query[k] = v
+	}
+	return runQuery(t, ds, query)
+}
+
+// serveBody runs a REST query like serveREST and returns its single frame
+func serveBody(t *testing.T, contentType, body string, model map[string]interface{}) (*data.Frame, error) {
+	t.Helper()
+
+	res := serveREST(t, contentType, body, model)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+	return res.Frames[0], nil
+}
+
+func TestDecodeCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		model map[string]interface{}
+		want  map[string][]string
+	}{
+		{
+			name: "numbers and text",
+			body: "host,load\nweb-1,0.5\nweb-2,1.25\n",
+			want: map[string][]string{"host": {"web-1", "web-2"}, "load": {"0.5", "1.25"}},
+		},
+		{
+			name: "empty body",
+			body: "",
+			want: map[string][]string{"value": {}},
+		},
+		{
+			name: "header only",
+			body: "host,load\n",
+			want: map[string][]string{"value": {}},
+		},
+		{
+			name: "unquoted cells trimmed, quoted kept",
+			body: "name,note\n  a  ,\" padded \"\nb,\"x, y\"\n",
+			want: map[string][]string{"name": {"a", "b"}, "note": {" padded ", "x, y"}},
+		},
+		{
+			name: "empty cells are nulls",
+			body: "host,load\nweb-1,\nweb-2, 2\n",
+			want: map[string][]string{"host": {"web-1", "web-2"}, "load": {"null", "2"}},
+		},
+		{
+			name: "duplicate headers kept apart",
+			body: "value,value,value_2\n1,2,3\n",
+			want: map[string][]string{"value": {"1"}, "value_3": {"2"}, "value_2": {"3"}},
+		},
+		{
+			name:  "custom delimiter",
+			body:  "host;load\nweb-1;0,5\n",
+			model: map[string]interface{}{"csvDelimiter": ";"},
+			want:  map[string][]string{"host": {"web-1"}, "load": {"0,5"}},
+		},
+		{
+			name:  "tab delimiter without header",
+			body:  "web-1\t3\nweb-2\t4\n",
+			model: map[string]interface{}{"csvDelimiter": `\t`, "csvNoHeader": true},
+			want:  map[string][]string{"column1": {"web-1", "web-2"}, "column2": {"3", "4"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "text/csv", tt.body, tt.model)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if got := frameColumns(frame); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		model map[string]interface{}
+	}{
+		{name: "more cells than the header", body: "a,b\n1,2,3\n"},
+		{name: "unterminated quote", body: "a\n\"open\n"},
+		{name: "multi-character delimiter", body: "a\n1\n", model: map[string]interface{}{"csvDelimiter": "::"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := serveBody(t, "text/csv", tt.body, tt.model); err == nil {
+				t.Error("expected the query to fail")
+			}
+		})
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		model map[string]interface{}
+		want  map[string][]string
+	}{
+		{
+			name: "rows of child elements",
+			body: `<rows><row><host>a</host><load>0.5</load></row><row><host>b</host><load>1.5</load></row></rows>`,
+			want: map[string][]string{"host": {"a", "b"}, "load": {"0.5", "1.5"}},
+		},
+		{
+			name: "rows of attributes",
+			body: `<?xml version="1.0"?><rows><row host="a" load="1"/><row host="b" load="2"/></rows>`,
+			want: map[string][]string{"host": {"a", "b"}, "load": {"1", "2"}},
+		},
+		{
+			name: "wrapped rows",
+			body: `<response><data><rows><row><v>1</v></row><row><v>2</v></row></rows></data></response>`,
+			want: map[string][]string{"v": {"1", "2"}},
+		},
+		{
+			name: "namespaces dropped",
+			body: `<ns:rows xmlns:ns="urn:x"><ns:row><ns:v>1</ns:v></ns:row><ns:row><ns:v>2</ns:v></ns:row></ns:rows>`,
+			want: map[string][]string{"v": {"1", "2"}},
+		},
+		{
+			name: "missing elements are nulls",
+			body: `<rows><row><a>1</a><b>2</b></row><row><a>3</a></row></rows>`,
+			want: map[string][]string{"a": {"1", "3"}, "b": {"2", "null"}},
+		},
+		{
+			name:  "explicit data path",
+			body:  `<result><meta><count>2</count></meta><items><item><v>1</v></item><item><v>2</v></item></items></result>`,
+			model: map[string]interface{}{"restDataPath": "result.items.item"},
+			want:  map[string][]string{"v": {"1", "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/xml", tt.body, tt.model)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if got := frameColumns(frame); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "empty body", body: "", wantErr: "no root element"},
+		{name: "malformed", body: `<rows><row></rows>`, wantErr: "failed to parse XML"},
+		{name: "no repeated element", body: `<result><meta><count>2</count></meta><items><item><v>1</v></item><item><v>2</v></item></items></result>`, wantErr: "no repeated element"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serveBody(t, "text/xml", tt.body, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectResponseFormat(t *testing.T) {
+	tests := []struct {
+		explicit    models.ResponseFormat
+		contentType string
+		want        models.ResponseFormat
+	}{
+		{contentType: "application/json", want: models.ResponseFormatJSON},
+		{contentType: "text/plain; charset=utf-8", want: models.ResponseFormatJSON},
+		{contentType: "", want: models.ResponseFormatJSON},
+		{contentType: "application/x-ndjson", want: models.ResponseFormatNDJSON},
+		{contentType: "text/csv; header=present", want: models.ResponseFormatCSV},
+		{contentType: "application/atom+xml", want: models.ResponseFormatXML},
+		{explicit: models.ResponseFormatCSV, contentType: "application/json", want: models.ResponseFormatCSV},
+		{explicit: models.ResponseFormatRaw, contentType: "text/csv", want: models.ResponseFormatRaw},
+	}
+
+	for _, tt := range tests {
+		if got := detectResponseFormat(tt.explicit, tt.contentType); got != tt.want {
+			t.Errorf("detectResponseFormat(%q, %q) = %q, want %q", tt.explicit, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestResponseFormatOverride(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		format      string
+		want        map[string][]string
+		wantErr     string
+	}{
+		{name: "JSON sent as text", contentType: "text/plain", body: `[{"name": "a"}]`, want: map[string][]string{"name": {"a"}}},
+		{name: "CSV sent as text", contentType: "text/plain", body: "name\na\nb\n", format: "csv", want: map[string][]string{"name": {"a", "b"}}},
+		{name: "NDJSON sent as JSON", contentType: "application/json", body: "{\"name\": \"a\"}\n{\"name\": \"b\"}\n", format: "ndjson", want: map[string][]string{"name": {"a", "b"}}},
+		{name: "XML sent as JSON", contentType: "application/json", body: `<items><item><name>a</name></item><item><name>b</name></item></items>`, format: "xml", want: map[string][]string{"name": {"a", "b"}}},
+		{name: "JSON sent as CSV", contentType: "text/csv", body: `[{"name": "a"}]`, format: "json", want: map[string][]string{"name": {"a"}}},
+		{name: "raw body", contentType: "application/json", body: `{"not": "parsed"`, format: "raw", want: map[string][]string{"value": {`{"not": "parsed"`}}},
+		{name: "misleading type without override", contentType: "text/csv", body: `[{"name": "a"}]`, wantErr: "failed to parse CSV response"},
+		{name: "unknown format", contentType: "application/json", body: `[]`, format: "yaml", wantErr: `unsupported response format "yaml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, tt.contentType, tt.body, map[string]interface{}{"responseFormat": tt.format})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := frameColumns(frame)
+			for _, field := range frame.Fields {
+				if field.Type().Time() {
+					delete(got, field.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
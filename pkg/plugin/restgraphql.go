@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+)
+
+// graphQLRequest is the standard GraphQL-over-HTTP POST body
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// buildGraphQLBody wraps the query's GraphQL document and variables into a
+// request body
+func buildGraphQLBody(queryModel *models.QueryModel) (string, error) {
+	if strings.TrimSpace(queryModel.GraphQLQuery) == "" {
+		return "", fmt.Errorf("GraphQL query is required")
+	}
+
+	body, err := json.Marshal(graphQLRequest{
+		Query:     queryModel.GraphQLQuery,
+		Variables: queryModel.GraphQLVariables,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode GraphQL request: %w", err)
+	}
+	return string(body), nil
+}
+
+// extractGraphQLData returns the data member of a GraphQL response together
+// with any reported error messages. It fails when errors are reported
+// without data.
+func extractGraphQLData(jsonData interface{}) (interface{}, []string, error) {
+	envelope, ok := jsonData.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("GraphQL response is not an object")
+	}
+
+	var messages []string
+	if errs, ok := envelope["errors"].([]interface{}); ok {
+		for _, e := range errs {
+			if obj, ok := e.(map[string]interface{}); ok {
+				if msg, ok := obj["message"].(string); ok {
+					messages = append(messages, msg)
+					continue
+				}
+			}
+			messages = append(messages, fmt.Sprint(e))
+		}
+	}
+
+	data := envelope["data"]
+	if data == nil {
+		if len(messages) > 0 {
+			return nil, nil, fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+		}
+		return nil, nil, fmt.Errorf("GraphQL response has no data")
+	}
+
+	return data, messages, nil
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestRESTGraphQL(t *testing.T) {
+	const query = `query($limit: Int) { users(limit: $limit) { name } }`
+
+	tests := []struct {
+		name       string
+		model      map[string]interface{}
+		wantVars   map[string]interface{}
+		response   string
+		want       map[string][]string
+		wantNotice string
+		wantErr    string
+	}{
+		{
+			name:     "data",
+			model:    map[string]interface{}{"graphQLQuery": query, "graphQLVariables": map[string]interface{}{"limit": 2}, "restDataPath": "users"},
+			wantVars: map[string]interface{}{"limit": float64(2)},
+			response: `{"data":{"users":[{"name":"ann"},{"name":"bob"}]}}`,
+			want:     map[string][]string{"name": {"ann", "bob"}},
+		},
+		{
+			name:       "partial errors",
+			model:      map[string]interface{}{"graphQLQuery": query, "restDataPath": "users"},
+			response:   `{"data":{"users":[{"name":"ann"}]},"errors":[{"message":"user 2 is private"}]}`,
+			want:       map[string][]string{"name": {"ann"}},
+			wantNotice: "GraphQL returned errors: user 2 is private",
+		},
+		{
+			name:     "errors without data",
+			model:    map[string]interface{}{"graphQLQuery": query},
+			response: `{"data":null,"errors":[{"message":"Cannot query field \"users\""},{"message":"rate limited"}]}`,
+			wantErr:  `GraphQL query failed: Cannot query field "users"; rate limited`,
+		},
+		{
+			name:     "no data",
+			model:    map[string]interface{}{"graphQLQuery": query},
+			response: `{}`,
+			wantErr:  "GraphQL response has no data",
+		},
+		{
+			name:     "not an object",
+			model:    map[string]interface{}{"graphQLQuery": query},
+			response: `[]`,
+			wantErr:  "GraphQL response is not an object",
+		},
+		{
+			name:    "missing query",
+			model:   map[string]interface{}{"graphQLQuery": "  "},
+			wantErr: "GraphQL query is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType string
+			var body graphQLRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, contentType = r.Method, r.Header.Get("Content-Type")
+				raw, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(raw, &body); err != nil {
+					t.Errorf("request body %s: %v", raw, err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tt.response)
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+			model := map[string]interface{}{
+				"queryType":    "rest",
+				"restEndpoint": "/graphql",
+				"restMethod":   "GET",
+				"restGraphQL":  true,
+			}
+			for k, v := range tt.model {
+				model[k] = v
+			}
+			res := runQuery(t, ds, model)
+
+			if tt.wantErr != "" {
+				if res.Error == nil || res.Error.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				if tt.response != "" && res.ErrorSource != backend.ErrorSourceDownstream {
+					t.Errorf("error source = %q, want downstream", res.ErrorSource)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			if method != "POST" || contentType != "application/json" {
+				t.Errorf("request = %s %s, want a JSON POST", method, contentType)
+			}
+			if body.Query != query || !reflect.DeepEqual(body.Variables, tt.wantVars) {
+				t.Errorf("request body = %+v, want the query and variables", body)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+			if got := frameColumns(res.Frames[0]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+			if tt.wantNotice != "" && !hasNotice(res.Frames[0], tt.wantNotice) {
+				t.Errorf("notices = %+v, want %q", res.Frames[0].Meta, tt.wantNotice)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// mergeFanOutFrames combines per-target frames into one long-format frame
+// with a leading "target" column. Columns are the union of all frames' fields
+// in first-seen order, nullable so targets lacking a column get nulls; a
+// column whose type differs between targets is rendered as strings.
+func mergeFanOutFrames(targets []string, frames []data.Frames) *data.Frame {
+	var names []string
+	types := map[string]data.FieldType{}
+	rows := 0
+	for _, targetFrames := range frames {
+		for _, frame := range targetFrames {
+			rowCount, _ := frame.RowLen()
+			rows += rowCount
+			for _, field := range frame.Fields {
+				fieldType := field.Type().NullableType()
+				existing, seen := types[field.Name]
+				switch {
+				case !seen:
+					names = append(names, field.Name)
+					types[field.Name] = fieldType
+				case existing != fieldType:
+					types[field.Name] = data.FieldTypeNullableString
+				}
+			}
+		}
+	}
+
+	targetField := data.NewFieldFromFieldType(data.FieldTypeString, rows)
+	targetField.Name = "target"
+	columns := make(map[string]*data.Field, len(names))
+	merged := data.NewFrame("", targetField)
+	for _, name := range names {
+		column := data.NewFieldFromFieldType(types[name], rows)
+		column.Name = name
+		columns[name] = column
+		merged.Fields = append(merged.Fields, column)
+	}
+
+	row := 0
+	for i, targetFrames := range frames {
+		for _, frame := range targetFrames {
+			rowCount, _ := frame.RowLen()
+			for r := 0; r < rowCount; r++ {
+				targetField.Set(row+r, targets[i])
+			}
+			for _, field := range frame.Fields {
+				column := columns[field.Name]
+				for r := 0; r < field.Len(); r++ {
+					value, ok := field.ConcreteAt(r)
+					if !ok {
+						continue
+					}
+					if column.Type() == data.FieldTypeNullableString && field.Type().NonNullableType() != data.FieldTypeString {
+						value = fmt.Sprint(value)
+					}
+					column.SetConcrete(row+r, value)
+				}
+			}
+			row += rowCount
+		}
+	}
+
+	return merged
+}
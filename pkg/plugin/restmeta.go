@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// fieldMetadata describes a single field in a REST metadata block
+type fieldMetadata struct {
+	Name        string
+	Unit        string
+	DisplayName string
+	Type        string
+}
+
+// lookupPath resolves a dotted path such as "result.items.0" against decoded JSON
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	current := value
+	for _, part := range strings.Split(strings.Trim(path, "."), ".") {
+		if part == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			current = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonPathIndexPattern matches [0] and ['key'] JSONPath subscripts
+var jsonPathIndexPattern = regexp.MustCompile(`\[\s*(?:'([^']*)'|"([^"]*)"|(\d+))\s*\]`)
+
+// selectDataPath resolves a dotted path or a simple JSONPath such as
+// $.result.items[0] and requires it to point at an array or object
+func selectDataPath(value interface{}, path string) (interface{}, error) {
+	dotted := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	dotted = jsonPathIndexPattern.ReplaceAllString(dotted, ".$1$2$3")
+
+	selected, ok := lookupPath(value, dotted)
+	if !ok {
+		return nil, fmt.Errorf("data path %q not found in response", path)
+	}
+	switch selected.(type) {
+	case []interface{}, map[string]interface{}:
+		return selected, nil
+	}
+	return nil, fmt.Errorf("data path %q resolves to %T, expected an array or object", path, selected)
+}
+
+// parseFieldMetadata accepts either an object keyed by field name or an array
+// of objects carrying a "name" key
+func parseFieldMetadata(block interface{}) []fieldMetadata {
+	var metas []fieldMetadata
+
+	toMeta := func(name string, raw interface{}) {
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		meta := fieldMetadata{Name: name}
+		if v, ok := obj["name"].(string); ok && meta.Name == "" {
+			meta.Name = v
+		}
+		meta.Unit, _ = obj["unit"].(string)
+		if v, ok := obj["displayName"].(string); ok {
+			meta.DisplayName = v
+		} else if v, ok := obj["label"].(string); ok {
+			meta.DisplayName = v
+		}
+		meta.Type, _ = obj["type"].(string)
+		if meta.Name != "" {
+			metas = append(metas, meta)
+		}
+	}
+
+	switch v := block.(type) {
+	case map[string]interface{}:
+		for name, raw := range v {
+			toMeta(name, raw)
+		}
+	case []interface{}:
+		for _, raw := range v {
+			toMeta("", raw)
+		}
+	}
+
+	return metas
+}
+
+// applyFieldMetadata sets units, display names and checks declared types on
+// frame fields using the metadata block found at metaPath. Fields declared
+// but not present, or present with a different type, are reported as
+// notices instead of failing the query.
+func (h *RESTAPIHandler) applyFieldMetadata(frames data.Frames, jsonData interface{}, metaPath string) {
+	block, ok := lookupPath(jsonData, metaPath)
+	if !ok {
+		addNotice(frames, data.NoticeSeverityWarning, fmt.Sprintf("Metadata path %q not found in response", metaPath))
+		return
+	}
+
+	var mismatches, missing []string
+	for _, meta := range parseFieldMetadata(block) {
+		found := false
+		for _, frame := range frames {
+			field, _ := frame.FieldByName(meta.Name)
+			if field == nil {
+				continue
+			}
+			found = true
+
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			if meta.Unit != "" {
+				field.Config.Unit = meta.Unit
+			}
+			if meta.DisplayName != "" {
+				field.Config.DisplayNameFromDS = meta.DisplayName
+			}
+			if meta.Type != "" && !fieldMatchesType(field, meta.Type) {
+				mismatches = append(mismatches, fmt.Sprintf("%s declared %s but is %s", meta.Name, meta.Type, field.Type().ItemTypeString()))
+			}
+		}
+		if !found {
+			missing = append(missing, meta.Name)
+		}
+	}
+
+	// Metadata objects are unordered, so sort for a stable message
+	sort.Strings(missing)
+	sort.Strings(mismatches)
+
+	if len(missing) > 0 {
+		addNotice(frames, data.NoticeSeverityWarning, "Field metadata describes fields missing from the response: "+strings.Join(missing, ", "))
+	}
+
+	if len(mismatches) > 0 {
+		addNotice(frames, data.NoticeSeverityInfo, "Field metadata type mismatch: "+strings.Join(mismatches, ", "))
+	}
+}
+
+// fieldMatchesType reports whether a field's type agrees with a declared type name
+func fieldMatchesType(field *data.Field, declared string) bool {
+	ft := field.Type()
+	switch strings.ToLower(declared) {
+	case "number", "numeric", "float", "int", "integer":
+		return ft.Numeric()
+	case "string", "text":
+		return ft == data.FieldTypeString || ft == data.FieldTypeNullableString
+	case "bool", "boolean":
+		return ft == data.FieldTypeBool || ft == data.FieldTypeNullableBool
+	case "time", "timestamp":
+		return ft.Time()
+	}
+	return true
+}
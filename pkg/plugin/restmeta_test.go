@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyFieldMetadata(t *testing.T) {
+	const items = `"items": [{"cpu": 1.5, "host": "a"}, {"cpu": 2, "host": "b"}]`
+
+	tests := []struct {
+		name            string
+		body            string
+		metaPath        string
+		wantUnit        string
+		wantDisplayName string
+		wantNotices     []string
+	}{
+		{
+			name:            "object keyed by field",
+			body:            `{` + items + `, "meta": {"cpu": {"unit": "percent", "displayName": "CPU"}}}`,
+			metaPath:        "meta",
+			wantUnit:        "percent",
+			wantDisplayName: "CPU",
+		},
+		{
+			name:            "array with label",
+			body:            `{` + items + `, "meta": [{"name": "cpu", "unit": "percent", "label": "CPU usage"}]}`,
+			metaPath:        "meta",
+			wantUnit:        "percent",
+			wantDisplayName: "CPU usage",
+		},
+		{
+			name:        "type mismatch",
+			body:        `{` + items + `, "meta": {"host": {"type": "number"}, "cpu": {"type": "number"}}}`,
+			metaPath:    "meta",
+			wantNotices: []string{"Field metadata type mismatch: host declared number but is *string"},
+		},
+		{
+			name:        "missing fields",
+			body:        `{` + items + `, "meta": {"mem": {"unit": "bytes"}, "disk": {"unit": "bytes"}, "cpu": {"unit": "percent"}}}`,
+			metaPath:    "meta",
+			wantUnit:    "percent",
+			wantNotices: []string{"Field metadata describes fields missing from the response: disk, mem"},
+		},
+		{
+			name:        "path not found",
+			body:        `{` + items + `}`,
+			metaPath:    "meta",
+			wantNotices: []string{`Metadata path "meta" not found in response`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, map[string]interface{}{
+				"restDataPath": "items",
+				"metaPath":     tt.metaPath,
+			})
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+
+			field, _ := frame.FieldByName("cpu")
+			if field == nil {
+				t.Fatalf("missing cpu field")
+			}
+			var unit, displayName string
+			if field.Config != nil {
+				unit, displayName = field.Config.Unit, field.Config.DisplayNameFromDS
+			}
+			if unit != tt.wantUnit {
+				t.Errorf("unit = %q, want %q", unit, tt.wantUnit)
+			}
+			if displayName != tt.wantDisplayName {
+				t.Errorf("display name = %q, want %q", displayName, tt.wantDisplayName)
+			}
+
+			for _, notice := range tt.wantNotices {
+				if !hasNotice(frame, notice) {
+					t.Errorf("missing notice %q", notice)
+				}
+			}
+			if tt.wantNotices == nil && frame.Meta != nil && len(frame.Meta.Notices) > 0 {
+				t.Errorf("unexpected notices %v", frame.Meta.Notices)
+			}
+		})
+	}
+}
+
+func TestRESTDataPath(t *testing.T) {
+	const body = `{
+		"result": {
+			"items": [{"host": "a", "cpu": 1}, {"host": "b", "cpu": 2}],
+			"pages": [
+				{"items": [{"host": "c", "cpu": 3}]},
+				{"items": [{"host": "d", "cpu": 4}]}
+			],
+			"by zone": {"eu": [{"host": "e", "cpu": 5}]},
+			"count": 2
+		}
+	}`
+
+	tests := []struct {
+		name     string
+		path     string
+		wantHost []string
+		wantErr  string
+	}{
+		{name: "dotted path", path: "result.items", wantHost: []string{"a", "b"}},
+		{name: "jsonpath", path: "$.result.items", wantHost: []string{"a", "b"}},
+		{name: "array index", path: "$.result.pages[1].items", wantHost: []string{"d"}},
+		{name: "dotted array index", path: "result.pages.0.items", wantHost: []string{"c"}},
+		{name: "quoted key", path: "$.result['by zone'].eu", wantHost: []string{"e"}},
+		{name: "missing key", path: "result.rows", wantErr: `data path "result.rows" not found in response`},
+		{name: "index out of range", path: "result.pages[2]", wantErr: `data path "result.pages[2]" not found in response`},
+		{name: "scalar", path: "result.count", wantErr: `data path "result.count" resolves to float64, expected an array or object`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", body, map[string]interface{}{"restDataPath": tt.path})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if got := frameColumns(frame)["host"]; !reflect.DeepEqual(got, tt.wantHost) {
+				t.Errorf("host = %q, want %q", got, tt.wantHost)
+			}
+		})
+	}
+}
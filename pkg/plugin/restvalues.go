@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// buildValueField creates a typed nullable field from decoded JSON values.
+// In auto mode the type is inferred: numbers, booleans and strings keep their
+// type when every non-null value agrees, anything else is rendered as text.
+// Other modes coerce every value, failing when a value can't be converted.
+func buildValueField(name string, values []interface{}, valueType models.ValueType) (*data.Field, error) {
+	if valueType == models.ValueTypeAuto {
+		valueType = inferValueType(values)
+	}
+
+	switch valueType {
+	case models.ValueTypeNumber:
+		out := make([]*float64, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			f, err := coerceNumber(v)
+			if err != nil {
+				return nil, fmt.Errorf("field %q row %d: %w", name, i, err)
+			}
+			out[i] = &f
+		}
+		return data.NewField(name, nil, out), nil
+
+	case models.ValueTypeBool:
+		out := make([]*bool, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			b, err := coerceBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("field %q row %d: %w", name, i, err)
+			}
+			out[i] = &b
+		}
+		return data.NewField(name, nil, out), nil
+
+	case models.ValueTypeString:
+		out := make([]*string, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			s := coerceString(v)
+			out[i] = &s
+		}
+		return data.NewField(name, nil, out), nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type %q", valueType)
+}
+
+// inferValueType picks the type shared by all non-null values
+func inferValueType(values []interface{}) models.ValueType {
+	inferred := models.ValueTypeAuto
+	for _, v := range values {
+		var t models.ValueType
+		switch v.(type) {
+		case nil:
+			continue
+		case float64:
+			t = models.ValueTypeNumber
+		case bool:
+			t = models.ValueTypeBool
+		default:
+			return models.ValueTypeString
+		}
+		if inferred != models.ValueTypeAuto && inferred != t {
+			return models.ValueTypeString
+		}
+		inferred = t
+	}
+	if inferred == models.ValueTypeAuto {
+		return models.ValueTypeString
+	}
+	return inferred
+}
+
+// coerceNumber converts a JSON value to a float
+func coerceNumber(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to a number", t)
+		}
+		return f, nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to a number", v)
+}
+
+// coerceBool converts a JSON value to a boolean
+func coerceBool(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		b, err := strconv.ParseBool(t)
+		if err != nil {
+			return false, fmt.Errorf("cannot convert %q to a boolean", t)
+		}
+		return b, nil
+	case float64:
+		if t == 0 || t == 1 {
+			return t == 1, nil
+		}
+		return false, fmt.Errorf("cannot convert %v to a boolean", t)
+	}
+	return false, fmt.Errorf("cannot convert %T to a boolean", v)
+}
+
+// coerceString renders a JSON value as text, encoding objects and arrays
+func coerceString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// coerceFrameValues converts every non-time field of a frame to valueType
+func coerceFrameValues(frame *data.Frame, valueType models.ValueType) error {
+	for i, field := range frame.Fields {
+		if field.Type().Time() {
+			continue
+		}
+
+		values := make([]interface{}, field.Len())
+		for row := range values {
+			values[row] = jsonValueAt(field, row)
+		}
+
+		coerced, err := buildValueField(field.Name, values, valueType)
+		if err != nil {
+			return err
+		}
+		coerced.Labels = field.Labels
+		coerced.Config = field.Config
+		frame.Fields[i] = coerced
+	}
+	return nil
+}
+
+// jsonValueAt returns a field value as its decoded-JSON equivalent
+func jsonValueAt(field *data.Field, row int) interface{} {
+	rv := reflect.ValueOf(field.At(row))
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	}
+	return rv.Interface()
+}
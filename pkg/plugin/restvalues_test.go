@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestForceValueType(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		valueType string
+		wantType  data.FieldType
+		want      map[string][]string
+		wantErr   string
+	}{
+		{name: "auto keeps numbers", body: `[200, 404]`, wantType: data.FieldTypeNullableFloat64, want: map[string][]string{"value": {"200", "404"}}},
+		{name: "explicit auto", body: `[200, 404]`, valueType: "auto", wantType: data.FieldTypeNullableFloat64, want: map[string][]string{"value": {"200", "404"}}},
+		{name: "auto mixed is text", body: `[200, "n/a"]`, wantType: data.FieldTypeNullableString, want: map[string][]string{"value": {"200", "n/a"}}},
+		{name: "numbers as strings", body: `[200, null, 404]`, valueType: "string", wantType: data.FieldTypeNullableString, want: map[string][]string{"value": {"200", "null", "404"}}},
+		{name: "strings as numbers", body: `["1", "2.5", true]`, valueType: "number", wantType: data.FieldTypeNullableFloat64, want: map[string][]string{"value": {"1", "2.5", "1"}}},
+		{name: "strings as bools", body: `["true", 0, false]`, valueType: "bool", wantType: data.FieldTypeNullableBool, want: map[string][]string{"value": {"true", "false", "false"}}},
+		{name: "primitive as string", body: `503`, valueType: "string", wantType: data.FieldTypeNullableString, want: map[string][]string{"value": {"503"}}},
+		{name: "object fields as numbers", body: `{"up": "1", "latency": "0.25"}`, valueType: "number", wantType: data.FieldTypeNullableFloat64, want: map[string][]string{"latency": {"0.25"}, "up": {"1"}}},
+		{name: "table columns as strings", body: `[{"code": 200, "ok": true}]`, valueType: "string", wantType: data.FieldTypeNullableString, want: map[string][]string{"code": {"200"}, "ok": {"true"}}},
+		{name: "unparsable number", body: `["1", "abc"]`, valueType: "number", wantErr: `field "value" row 1: cannot convert "abc" to a number`},
+		{name: "number out of bool range", body: `[1, 2]`, valueType: "bool", wantErr: `field "value" row 1: cannot convert 2 to a boolean`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := serveBody(t, "application/json", tt.body, map[string]interface{}{"forceValueType": tt.valueType})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := frameColumns(frame)
+			for _, field := range frame.Fields {
+				if field.Type().Time() {
+					// Primitive responses are stamped with the request time
+					delete(got, field.Name)
+					continue
+				}
+				if field.Type() != tt.wantType {
+					t.Errorf("field %s type = %s, want %s", field.Name, field.Type(), tt.wantType)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
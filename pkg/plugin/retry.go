@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	// defaultRetryBackoff is the base delay before the first retry
+	defaultRetryBackoff = 100 * time.Millisecond
+
+	// maxRetryBackoff caps the exponential backoff of a single attempt
+	maxRetryBackoff = 10 * time.Second
+)
+
+// defaultRetryableStatusCodes are retried when no codes are configured
+var defaultRetryableStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy decides which failures are retried and how long to wait
+type retryPolicy struct {
+	maxRetries    int
+	baseBackoff   time.Duration
+	statusCodes   map[int]bool
+	networkErrors bool
+}
+
+// newRetryPolicy builds the retry policy from the datasource configuration.
+// Only 5xx codes are honored: a 4xx is the client's fault and fails the same
+// way when repeated.
+func newRetryPolicy(config *models.DataSourceConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries:    config.MaxRetries,
+		baseBackoff:   time.Duration(config.RetryBackoffMs) * time.Millisecond,
+		statusCodes:   make(map[int]bool),
+		networkErrors: !config.DisableNetworkErrorRetry,
+	}
+	if policy.baseBackoff <= 0 {
+		policy.baseBackoff = defaultRetryBackoff
+	}
+
+	codes := config.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		if code >= 500 && code < 600 {
+			policy.statusCodes[code] = true
+		}
+	}
+
+	return policy
+}
+
+// backoff returns a full-jitter delay for the given attempt, uniformly
+// distributed between zero and the capped exponential backoff
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.baseBackoff << attempt
+	if ceiling <= 0 || ceiling > maxRetryBackoff {
+		ceiling = maxRetryBackoff
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isIdempotent reports whether a request can safely be sent more than once
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.GetBody != nil
+	}
+	return false
+}
+
+// doWithRetry executes a request, retrying idempotent requests on the
+// configured conditions. The total time spent is bounded by the context
+// deadline: a retry whose delay would overrun it is not attempted.
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy retryPolicy, logger log.Logger) (*http.Response, error) {
+	if policy.maxRetries <= 0 || !isIdempotent(req) {
+		return client.Do(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to reset request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := client.Do(req)
+
+		retryable := false
+		reason := ""
+		switch {
+		case err != nil:
+			retryable = policy.networkErrors && ctx.Err() == nil
+			reason = err.Error()
+		case policy.statusCodes[resp.StatusCode]:
+			retryable = true
+			reason = resp.Status
+		}
+		if !retryable || attempt >= policy.maxRetries {
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		logger.Debug("Retrying request", "url", req.URL.Redacted(), "attempt", attempt+1, "reason", reason, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
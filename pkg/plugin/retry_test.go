@@ -0,0 +1,256 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// flappingServer fails its first failures requests with status, then
+// answers REST, Prometheus and Loki queries successfully
+type flappingServer struct {
+	failures int32
+	status   int
+	attempts int32
+}
+
+func (f *flappingServer) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&f.attempts, 1) <= f.failures {
+			w.WriteHeader(f.status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/query", "/api/v1/query_range":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		case "/loki/api/v1/query_range":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"streams","result":[]}}`)
+		default:
+			fmt.Fprint(w, `[{"value": 1}]`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryDeadlineAbortsSlowBackend(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+	}{
+		{name: "single attempt", maxRetries: 0},
+		{name: "with retries", maxRetries: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(5 * time.Second):
+				case <-r.Context().Done():
+				}
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":        srv.URL,
+				"timeoutSeconds": 30,
+				"maxRetries":     tt.maxRetries,
+			}, nil)
+
+			const deadline = 200 * time.Millisecond
+			ctx, cancel := context.WithTimeout(context.Background(), deadline)
+			defer cancel()
+
+			start := time.Now()
+			resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{
+				testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": "/slow"}),
+			}})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("QueryData: %v", err)
+			}
+
+			if res := resp.Responses["A"]; res.Error == nil {
+				t.Fatal("expected the query to fail at the deadline")
+			}
+			if elapsed > deadline+time.Second {
+				t.Errorf("query took %v, want about %v", elapsed, deadline)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	policy := newRetryPolicy(&models.DataSourceConfig{MaxRetries: 10, RetryBackoffMs: 100})
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 0, ceiling: 100 * time.Millisecond},
+		{attempt: 1, ceiling: 200 * time.Millisecond},
+		{attempt: 3, ceiling: 800 * time.Millisecond},
+		{attempt: 10, ceiling: maxRetryBackoff},
+		{attempt: 62, ceiling: maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			distinct := make(map[time.Duration]bool)
+			for i := 0; i < 200; i++ {
+				delay := policy.backoff(tt.attempt)
+				if delay < 0 || delay > tt.ceiling {
+					t.Fatalf("delay %v outside [0, %v]", delay, tt.ceiling)
+				}
+				distinct[delay] = true
+			}
+			if len(distinct) < 10 {
+				t.Errorf("only %d distinct delays, want jitter", len(distinct))
+			}
+		})
+	}
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		status       int
+		wantAttempts int32
+	}{
+		{name: "default code retried", status: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "default excludes 500", status: http.StatusInternalServerError, wantAttempts: 1},
+		{name: "configured code retried", codes: []int{http.StatusInternalServerError}, status: http.StatusInternalServerError, wantAttempts: 3},
+		{name: "unconfigured code not retried", codes: []int{http.StatusInternalServerError}, status: http.StatusServiceUnavailable, wantAttempts: 1},
+		{name: "4xx codes ignored", codes: []int{http.StatusTooManyRequests}, status: http.StatusTooManyRequests, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flapping := &flappingServer{failures: 100, status: tt.status}
+			srv := flapping.start(t)
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":              srv.URL,
+				"maxRetries":           2,
+				"retryBackoffMs":       1,
+				"retryableStatusCodes": tt.codes,
+			}, nil)
+			if res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}); res.Error == nil {
+				t.Fatal("expected the query to fail")
+			}
+			if got := atomic.LoadInt32(&flapping.attempts); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNetworkErrorRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		disable      bool
+		wantAttempts int32
+	}{
+		{name: "retried by default", wantAttempts: 3},
+		{name: "disabled", disable: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				// Drop the connection without a response
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":                  srv.URL,
+				"maxRetries":               2,
+				"retryBackoffMs":           1,
+				"disableNetworkErrorRetry": tt.disable,
+			}, nil)
+			if res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}); res.Error == nil {
+				t.Fatal("expected the query to fail")
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptsAgainstFlappingServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        map[string]interface{}
+		failures     int32
+		status       int
+		maxRetries   int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "Prometheus recovers", model: map[string]interface{}{"queryType": "prometheus", "promQL": "up"}, failures: 2, status: http.StatusServiceUnavailable, maxRetries: 3, wantAttempts: 3},
+		{name: "Loki recovers", model: map[string]interface{}{"queryType": "loki", "logQL": `{job="a"}`}, failures: 2, status: http.StatusBadGateway, maxRetries: 3, wantAttempts: 3},
+		{name: "REST recovers", model: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}, failures: 1, status: http.StatusGatewayTimeout, maxRetries: 3, wantAttempts: 2},
+		{name: "retries exhausted", model: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}, failures: 5, status: http.StatusServiceUnavailable, maxRetries: 2, wantAttempts: 3, wantErr: true},
+		{name: "retries disabled", model: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}, failures: 1, status: http.StatusServiceUnavailable, maxRetries: 0, wantAttempts: 1, wantErr: true},
+		{name: "4xx not retried", model: map[string]interface{}{"queryType": "prometheus", "promQL": "up"}, failures: 1, status: http.StatusBadRequest, maxRetries: 3, wantAttempts: 1, wantErr: true},
+		{name: "POST not retried", model: map[string]interface{}{"queryType": "rest", "restEndpoint": "/data", "restMethod": "POST", "restBody": `{}`}, failures: 1, status: http.StatusServiceUnavailable, maxRetries: 3, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flapping := &flappingServer{failures: tt.failures, status: tt.status}
+			srv := flapping.start(t)
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"prometheusUrl":  srv.URL,
+				"lokiUrl":        srv.URL,
+				"restUrl":        srv.URL,
+				"maxRetries":     tt.maxRetries,
+				"retryBackoffMs": 1,
+			}, nil)
+			res := runQuery(t, ds, tt.model)
+
+			if (res.Error != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", res.Error, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&flapping.attempts); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSuccessfulErrorPayloadNotRetried(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"error","errorType":"execution","error":"query timed out"}`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL, "maxRetries": 3, "retryBackoffMs": 1}, nil)
+	if res := runQuery(t, ds, map[string]interface{}{"queryType": "prometheus", "promQL": "up"}); res.Error == nil {
+		t.Fatal("expected the error payload to fail the query")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"math"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// roundFrameValues rounds float fields to the given number of decimals,
+// half away from zero. A nil or negative setting leaves values untouched.
+func roundFrameValues(frames data.Frames, decimals *int) {
+	if decimals == nil || *decimals < 0 {
+		return
+	}
+
+	scale := math.Pow(10, float64(*decimals))
+	round := func(v float64) float64 {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return v
+		}
+		return math.Round(v*scale) / scale
+	}
+
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			switch field.Type() {
+			case data.FieldTypeFloat64:
+				for i := 0; i < field.Len(); i++ {
+					field.Set(i, round(field.At(i).(float64)))
+				}
+			case data.FieldTypeNullableFloat64:
+				for i := 0; i < field.Len(); i++ {
+					if v := field.At(i).(*float64); v != nil {
+						r := round(*v)
+						field.Set(i, &r)
+					}
+				}
+			}
+		}
+	}
+}
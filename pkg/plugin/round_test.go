@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestRoundFrameValues(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	floatPtr := func(f float64) *float64 { return &f }
+	values := []float64{1.23456, -1.23556, 2.5, -2.5, 0.125, math.NaN(), math.Inf(1)}
+
+	tests := []struct {
+		name     string
+		decimals *int
+		want     []float64
+	}{
+		{name: "unset", want: values},
+		{name: "negative", decimals: intPtr(-1), want: values},
+		{name: "zero", decimals: intPtr(0), want: []float64{1, -1, 3, -3, 0, math.NaN(), math.Inf(1)}},
+		{name: "two", decimals: intPtr(2), want: []float64{1.23, -1.24, 2.5, -2.5, 0.13, math.NaN(), math.Inf(1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := append([]float64(nil), values...)
+			nullable := make([]*float64, len(values)+1)
+			for i, v := range values {
+				nullable[i] = floatPtr(v)
+			}
+			frame := data.NewFrame("",
+				data.NewField("plain", nil, plain),
+				data.NewField("nullable", nil, nullable),
+				data.NewField("text", nil, make([]string, len(values)+1)),
+			)
+
+			roundFrameValues(data.Frames{frame}, tt.decimals)
+
+			for i, want := range tt.want {
+				got := frame.Fields[0].At(i).(float64)
+				gotNullable := *frame.Fields[1].At(i).(*float64)
+				if !sameFloat(got, want) || !sameFloat(gotNullable, want) {
+					t.Errorf("value %d = %v and %v, want %v", i, got, gotNullable, want)
+				}
+			}
+			if v := frame.Fields[1].At(len(values)).(*float64); v != nil {
+				t.Errorf("null value = %v, want null", *v)
+			}
+		})
+	}
+}
+
+// sameFloat compares floats treating NaN as equal to itself
+func sameFloat(a, b float64) bool {
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
+
+func TestRoundDecimalsPerSource(t *testing.T) {
+	const matrix = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1704103200,"-3.14159"]]}]}}`
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T) backend.DataResponse
+	}{
+		{name: "prometheus", run: func(t *testing.T) backend.DataResponse {
+			return servePrometheus(t, matrix, map[string]interface{}{"roundDecimals": 2})
+		}},
+		{name: "loki matrix", run: func(t *testing.T) backend.DataResponse {
+			_, res := serveLoki(t, nil, matrix, testQuery(t, "A", map[string]interface{}{
+				"queryType":     "loki",
+				"logQL":         `sum(rate({job="a"}[1m]))`,
+				"roundDecimals": 2,
+			}))
+			return res
+		}},
+		{name: "rest", run: func(t *testing.T) backend.DataResponse {
+			return serveREST(t, "application/json", `[{"value": -3.14159}]`, map[string]interface{}{"roundDecimals": 2})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.run(t)
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(res.Frames))
+			}
+
+			var got []float64
+			for _, field := range res.Frames[0].Fields {
+				for i := 0; i < field.Len(); i++ {
+					switch v := field.At(i).(type) {
+					case float64:
+						got = append(got, v)
+					case *float64:
+						got = append(got, *v)
+					}
+				}
+			}
+			if len(got) != 1 || got[0] != -3.14 {
+				t.Errorf("values = %v, want [-3.14]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultStaleCacheMaxAge bounds how old served stale data may be
+const defaultStaleCacheMaxAge = 10 * time.Minute
+
+// staleEntry is the last successful result of a query
+type staleEntry struct {
+	frames data.Frames
+	stored time.Time
+}
+
+// staleCache keeps the last good frames per query so a failing backend can
+// be answered with stale data instead of an error
+type staleCache struct {
+	size   int
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]staleEntry
+	order   []string
+}
+
+// newStaleCache creates a cache of at most size queries, or nil when size
+// is zero and the cache is disabled
+func newStaleCache(size int, maxAge time.Duration) *staleCache {
+	if size <= 0 {
+		return nil
+	}
+	if maxAge <= 0 {
+		maxAge = defaultStaleCacheMaxAge
+	}
+	return &staleCache{
+		size:    size,
+		maxAge:  maxAge,
+		entries: make(map[string]staleEntry),
+	}
+}
+
+// apply stores successful responses and replaces failed ones with the last
+// good frames for the same query, when they are recent enough
+func (c *staleCache) apply(query backend.DataQuery, res backend.DataResponse) backend.DataResponse {
+	if c == nil {
+		return res
+	}
+
+	key := string(query.JSON)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if res.Error == nil {
+		if _, exists := c.entries[key]; !exists {
+			c.order = append(c.order, key)
+			if len(c.order) > c.size {
+				delete(c.entries, c.order[0])
+				c.order = c.order[1:]
+			}
+		}
+		c.entries[key] = staleEntry{frames: res.Frames, stored: time.Now()}
+		return res
+	}
+
+	entry, ok := c.entries[key]
+	age := time.Since(entry.stored)
+	if !ok || age > c.maxAge {
+		return res
+	}
+
+	frames := copyFramesMeta(entry.frames)
+	addNotice(frames, data.NoticeSeverityWarning,
+		fmt.Sprintf("Serving stale data (age %ds) because the backend failed: %v", int(age.Seconds()), res.Error))
+	return backend.DataResponse{Frames: frames}
+}
+
+// copyFramesMeta shallow-copies frames with their own metadata, so notices
+// added to a served copy don't accumulate on the cached frames
+func copyFramesMeta(frames data.Frames) data.Frames {
+	copied := make(data.Frames, len(frames))
+	for i, frame := range frames {
+		f := *frame
+		if frame.Meta != nil {
+			meta := *frame.Meta
+			meta.Notices = append([]data.Notice(nil), frame.Meta.Notices...)
+			f.Meta = &meta
+		}
+		copied[i] = &f
+	}
+	return copied
+}
@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestStaleCache(t *testing.T) {
+	restQuery := map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}
+
+	tests := []struct {
+		name      string
+		settings  map[string]interface{}
+		retry     map[string]interface{}
+		wantStale bool
+	}{
+		{name: "serves stale data", settings: map[string]interface{}{"staleCacheSize": 10}, retry: restQuery, wantStale: true},
+		{name: "disabled by default", retry: restQuery},
+		{name: "other queries fail", settings: map[string]interface{}{"staleCacheSize": 10}, retry: map[string]interface{}{"queryType": "rest", "restEndpoint": "/other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var down int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&down) == 1 {
+					http.Error(w, "maintenance", http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `[{"value": 1}]`)
+			}))
+			defer srv.Close()
+
+			settings := map[string]interface{}{"restUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, nil)
+
+			if res := runQuery(t, ds, restQuery); res.Error != nil {
+				t.Fatalf("first query failed: %v", res.Error)
+			}
+			atomic.StoreInt32(&down, 1)
+
+			// Serving twice checks notices don't pile up on the cached frames
+			for i := 0; i < 2; i++ {
+				res := runQuery(t, ds, tt.retry)
+				if !tt.wantStale {
+					if res.Error == nil {
+						t.Fatal("query succeeded, want the backend error")
+					}
+					return
+				}
+				if res.Error != nil {
+					t.Fatalf("query %d failed: %v", i, res.Error)
+				}
+				if len(res.Frames) != 1 || res.Frames[0].Meta == nil || len(res.Frames[0].Meta.Notices) != 1 {
+					t.Fatalf("frames = %v, want the cached frame with one notice", res.Frames)
+				}
+				if text := res.Frames[0].Meta.Notices[0].Text; !strings.HasPrefix(text, "Serving stale data (age 0s) because the backend failed") {
+					t.Errorf("notice = %q, want the stale data notice", text)
+				}
+			}
+		})
+	}
+}
+
+func TestStaleCacheLimits(t *testing.T) {
+	good := backend.DataResponse{Frames: data.Frames{data.NewFrame("result")}}
+	failed := backend.DataResponse{Error: fmt.Errorf("connection refused")}
+	query := func(endpoint string) backend.DataQuery {
+		return testQuery(t, "A", map[string]interface{}{"queryType": "rest", "restEndpoint": endpoint})
+	}
+
+	if cache := newStaleCache(0, time.Minute); cache != nil {
+		t.Error("cache of size 0 is enabled")
+	}
+
+	t.Run("max age", func(t *testing.T) {
+		cache := newStaleCache(10, 50*time.Millisecond)
+		cache.apply(query("/a"), good)
+		if res := cache.apply(query("/a"), failed); res.Error != nil {
+			t.Fatalf("fresh entry not served: %v", res.Error)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if res := cache.apply(query("/a"), failed); res.Error == nil {
+			t.Error("entry older than the max age was served")
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		cache := newStaleCache(2, time.Minute)
+		for _, endpoint := range []string{"/a", "/b", "/c"} {
+			cache.apply(query(endpoint), good)
+		}
+		if len(cache.entries) != 2 {
+			t.Fatalf("kept %d results, want 2", len(cache.entries))
+		}
+		if res := cache.apply(query("/a"), failed); res.Error == nil {
+			t.Error("oldest entry was not evicted")
+		}
+		if res := cache.apply(query("/c"), failed); res.Error != nil {
+			t.Errorf("newest entry not served: %v", res.Error)
+		}
+	})
+}
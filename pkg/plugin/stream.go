@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// streamPathPrometheus prefixes live channel paths for Prometheus streams
+	streamPathPrometheus = "prometheus/"
+
+	// defaultStreamInterval is used when a stream doesn't request an interval
+	defaultStreamInterval = 5 * time.Second
+
+	// minStreamInterval protects the backend from overly eager streams
+	minStreamInterval = time.Second
+)
+
+// streamQuery is the subscription payload of a Prometheus stream
+type streamQuery struct {
+	PromQL     string            `json:"promQL"`
+	IntervalMs int64             `json:"intervalMs"`
+	Variables  map[string]string `json:"variables,omitempty"`
+}
+
+// interval returns the refresh interval, clamped to the minimum
+func (q streamQuery) interval() time.Duration {
+	interval := time.Duration(q.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		return defaultStreamInterval
+	}
+	if interval < minStreamInterval {
+		return minStreamInterval
+	}
+	return interval
+}
+
+// parseStreamQuery validates a stream path and decodes its payload
+func (d *Datasource) parseStreamQuery(path string, raw json.RawMessage) (*streamQuery, error) {
+	if !strings.HasPrefix(path, streamPathPrometheus) {
+		return nil, fmt.Errorf("unsupported stream path: %s", path)
+	}
+	if d.config.PrometheusURL == "" {
+		return nil, fmt.Errorf("Prometheus URL not configured")
+	}
+
+	var q streamQuery
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return nil, fmt.Errorf("failed to parse stream query: %w", err)
+	}
+	if q.PromQL == "" {
+		return nil, fmt.Errorf("PromQL query is required")
+	}
+	return &q, nil
+}
+
+// SubscribeStream accepts subscriptions to Prometheus live channels
+func (d *Datasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if _, err := d.parseStreamQuery(req.Path, req.Data); err != nil {
+		d.logger.Warn("Rejecting stream subscription", "path", req.Path, "error", err)
+		return &backend.SubscribeStreamResponse{
+			Status: backend.SubscribeStreamStatusNotFound,
+		}, nil
+	}
+
+	return &backend.SubscribeStreamResponse{
+		Status: backend.SubscribeStreamStatusOK,
+	}, nil
+}
+
+// PublishStream rejects publishing, streams are produced by the plugin only
+func (d *Datasource) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{
+		Status: backend.PublishStreamStatusPermissionDenied,
+	}, nil
+}
+
+// RunStream re-runs a Prometheus instant query on the requested interval and
+// pushes the resulting frames until the last subscriber leaves
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	q, err := d.parseStreamQuery(req.Path, req.Data)
+	if err != nil {
+		return err
+	}
+
+	handler := &PrometheusHandler{
+		config: d.config,
+		logger: d.logger,
+		client: d.client,
+	}
+	queryModel := &models.QueryModel{
+		QueryType: models.QueryTypePrometheus,
+		QueryKind: models.QueryKindInstant,
+		PromQL:    interpolateQueryVariables(q.PromQL, q.Variables),
+	}
+
+	d.logger.Debug("Starting stream", "path", req.Path, "interval", q.interval())
+
+	ticker := time.NewTicker(q.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := d.pushStreamFrames(ctx, handler, queryModel, sender); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			d.logger.Debug("Stopping stream", "path", req.Path)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushStreamFrames runs one instant query and sends its frames. Query errors
+// are logged and skipped so a transient failure doesn't end the stream.
+func (d *Datasource) pushStreamFrames(ctx context.Context, handler *PrometheusHandler, queryModel *models.QueryModel, sender *backend.StreamSender) error {
+	now := time.Now()
+	res := classifyErrorResponse(limitFrames(handler.executeQuery(ctx, backend.DataQuery{
+		TimeRange: backend.TimeRange{From: now, To: now},
+	}, queryModel), d.config.MaxFramesPerQuery))
+	if res.Error != nil {
+		d.logger.Warn("Stream query failed", "error", res.Error, "errorSource", res.ErrorSource, "status", res.Status)
+		return nil
+	}
+
+	for _, frame := range res.Frames {
+		if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// packetRecorder reports when each stream packet is sent
+type packetRecorder struct {
+	sent chan time.Time
+}
+
+func (p *packetRecorder) Send(*backend.StreamPacket) error {
+	p.sent <- time.Now()
+	return nil
+}
+
+func TestStreamQueryInterval(t *testing.T) {
+	tests := []struct {
+		intervalMs int64
+		want       time.Duration
+	}{
+		{intervalMs: 0, want: defaultStreamInterval},
+		{intervalMs: 100, want: minStreamInterval},
+		{intervalMs: 30000, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := (streamQuery{IntervalMs: tt.intervalMs}).interval(); got != tt.want {
+			t.Errorf("interval(%d) = %s, want %s", tt.intervalMs, got, tt.want)
+		}
+	}
+}
+
+// streamServer answers instant queries with a single series
+func streamServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("path = %s, want /api/v1/query", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1704103200,"1"]}]}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunStreamPollsOnInterval(t *testing.T) {
+	srv := streamServer(t)
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+
+	payload, _ := json.Marshal(streamQuery{PromQL: "up", IntervalMs: 1000})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &packetRecorder{sent: make(chan time.Time, 10)}
+	done := make(chan error, 1)
+	go func() {
+		done <- ds.RunStream(ctx, &backend.RunStreamRequest{Path: "prometheus/up", Data: payload}, backend.NewStreamSender(sender))
+	}()
+
+	var times []time.Time
+	for len(times) < 3 {
+		select {
+		case at := <-sender.sent:
+			times = append(times, at)
+		case err := <-done:
+			t.Fatalf("stream ended early: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d packets, want 3", len(times))
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunStream = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after cancellation")
+	}
+
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 900*time.Millisecond {
+			t.Errorf("packet %d came %s after the previous one, want about 1s", i, gap)
+		}
+	}
+}
+
+func TestRunStreamCancelled(t *testing.T) {
+	srv := streamServer(t)
+	ds := newTestDatasource(t, map[string]interface{}{"prometheusUrl": srv.URL}, nil)
+
+	payload, _ := json.Marshal(streamQuery{PromQL: "up", IntervalMs: 1000})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sender := &packetRecorder{sent: make(chan time.Time, 10)}
+	start := time.Now()
+	err := ds.RunStream(ctx, &backend.RunStreamRequest{Path: "prometheus/up", Data: payload}, backend.NewStreamSender(sender))
+	if err != nil {
+		t.Errorf("RunStream = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("RunStream took %s after cancellation", elapsed)
+	}
+	if got := len(sender.sent); got != 0 {
+		t.Errorf("sent %d packets, want 0", got)
+	}
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// parseMaxRange parses a per-source maximum query range; empty means no limit
+func parseMaxRange(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid maximum query range %q", value)
+	}
+	return d, nil
+}
+
+// withMaxRange runs a query after enforcing the source's maximum range.
+// Over-limit ranges are rejected, or in clamp mode shortened to end at the
+// original To with a notice on the result.
+func (d *Datasource) withMaxRange(query backend.DataQuery, maxRange string, run func(backend.DataQuery) backend.DataResponse) backend.DataResponse {
+	limit, err := parseMaxRange(maxRange)
+	if err != nil || limit == 0 {
+		return run(query)
+	}
+
+	requested := query.TimeRange.To.Sub(query.TimeRange.From)
+	if requested <= limit {
+		return run(query)
+	}
+
+	if d.config.MaxRangeMode != models.MaxRangeModeClamp {
+		return backend.DataResponse{
+			Error:       fmt.Errorf("query range %s exceeds the maximum of %s for this source", requested, limit),
+			Status:      backend.StatusBadRequest,
+			ErrorSource: backend.ErrorSourcePlugin,
+		}
+	}
+
+	query.TimeRange.From = query.TimeRange.To.Add(-limit)
+	res := run(query)
+	if res.Error == nil {
+		addNotice(res.Frames, data.NoticeSeverityWarning,
+			fmt.Sprintf("Query range %s was clamped to the maximum of %s for this source", requested, limit))
+	}
+	return res
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxQueryRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]interface{}
+		model      map[string]interface{}
+		wantErr    string
+		wantStart  time.Time
+		wantNotice string
+	}{
+		{
+			name:      "Prometheus within the limit",
+			settings:  map[string]interface{}{"prometheusMaxRange": "2h"},
+			model:     map[string]interface{}{"queryType": "prometheus", "promQL": "up"},
+			wantStart: testTimeRange.From,
+		},
+		{
+			name:     "Prometheus rejected",
+			settings: map[string]interface{}{"prometheusMaxRange": "30m"},
+			model:    map[string]interface{}{"queryType": "prometheus", "promQL": "up"},
+			wantErr:  "query range 1h0m0s exceeds the maximum of 30m0s",
+		},
+		{
+			name:       "Prometheus clamped",
+			settings:   map[string]interface{}{"prometheusMaxRange": "30m", "maxRangeMode": "clamp"},
+			model:      map[string]interface{}{"queryType": "prometheus", "promQL": "up"},
+			wantStart:  testTimeRange.To.Add(-30 * time.Minute),
+			wantNotice: "clamped to the maximum of 30m0s",
+		},
+		{
+			name:     "Loki rejected",
+			settings: map[string]interface{}{"lokiMaxRange": "15m"},
+			model:    map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`},
+			wantErr:  "exceeds the maximum of 15m0s",
+		},
+		{
+			name:       "Loki clamped",
+			settings:   map[string]interface{}{"lokiMaxRange": "15m", "maxRangeMode": "clamp"},
+			model:      map[string]interface{}{"queryType": "loki", "logQL": `{job="api"}`},
+			wantStart:  testTimeRange.To.Add(-15 * time.Minute),
+			wantNotice: "clamped to the maximum of 15m0s",
+		},
+		{
+			name:      "limit of the other source ignored",
+			settings:  map[string]interface{}{"lokiMaxRange": "15m"},
+			model:     map[string]interface{}{"queryType": "prometheus", "promQL": "up"},
+			wantStart: testTimeRange.From,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				starts []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				starts = append(starts, r.URL.Query().Get("start"))
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				if strings.HasPrefix(r.URL.Path, "/loki/") {
+					fmt.Fprint(w, lokiStreamsResponse)
+					return
+				}
+				fmt.Fprint(w, prometheusMatrix(1))
+			}))
+			defer srv.Close()
+
+			settings := map[string]interface{}{"prometheusUrl": srv.URL, "lokiUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, nil)
+			res := runQuery(t, ds, tt.model)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantErr != "" {
+				if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", res.Error, tt.wantErr)
+				}
+				if len(starts) != 0 {
+					t.Errorf("rejected query reached the backend")
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+
+			want := strconv.FormatInt(tt.wantStart.Unix(), 10)
+			if tt.model["queryType"] == "loki" {
+				want = strconv.FormatInt(tt.wantStart.UnixNano(), 10)
+			}
+			if len(starts) != 1 || starts[0] != want {
+				t.Errorf("start = %v, want %s", starts, want)
+			}
+
+			for _, frame := range res.Frames {
+				if got := hasNotice(frame, "clamped"); got != (tt.wantNotice != "") {
+					t.Errorf("frame %q clamp notice = %v, want %v", frame.Name, got, tt.wantNotice != "")
+				} else if tt.wantNotice != "" && !hasNotice(frame, tt.wantNotice) {
+					t.Errorf("frame %q lacks notice %q", frame.Name, tt.wantNotice)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMaxRangeRejectsInvalid(t *testing.T) {
+	for _, value := range []string{"forever", "-1h", "0s"} {
+		if _, err := parseMaxRange(value); err == nil {
+			t.Errorf("parseMaxRange(%q) accepted an invalid range", value)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	// defaultHTTPTimeout bounds each backend request unless configured
+	defaultHTTPTimeout = 30 * time.Second
+
+	// defaultHealthTimeout bounds each health probe unless configured
+	defaultHealthTimeout = 5 * time.Second
+
+	// maxSensibleTimeout is the timeout above which a warning is logged
+	maxSensibleTimeout = 10 * time.Minute
+
+	// maxIdleConnsPerHost keeps enough idle connections for concurrent panels
+	maxIdleConnsPerHost = 32
+)
+
+// requestTimeout returns the configured backend request timeout
+func requestTimeout(config *models.DataSourceConfig) time.Duration {
+	if config.TimeoutSeconds > 0 {
+		return time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	return defaultHTTPTimeout
+}
+
+// healthTimeout returns the configured health probe timeout
+func healthTimeout(config *models.DataSourceConfig) time.Duration {
+	if config.HealthTimeoutSeconds > 0 {
+		return time.Duration(config.HealthTimeoutSeconds) * time.Second
+	}
+	return defaultHealthTimeout
+}
+
+// tlsVersions maps the accepted version strings to their tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsRenegotiation maps the accepted renegotiation policies
+var tlsRenegotiation = map[string]tls.RenegotiationSupport{
+	"":       tls.RenegotiateNever,
+	"never":  tls.RenegotiateNever,
+	"once":   tls.RenegotiateOnceAsClient,
+	"freely": tls.RenegotiateFreelyAsClient,
+}
+
+// newTransport builds the transport shared by all backend requests
+func newTransport(config *models.DataSourceConfig, logger log.Logger) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = loadRootCAs(config.TLSCACert, logger)
+
+	// The default transport already enables keep-alives and HTTP/2
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	// An explicit proxy wins over HTTP(S)_PROXY from the environment
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", config.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// Cache DNS resolutions when a TTL is configured
+	if config.DNSCacheTTL != "" {
+		ttl, err := time.ParseDuration(config.DNSCacheTTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid DNS cache TTL %q", config.DNSCacheTTL)
+		}
+		transport.DialContext = newDNSCache(ttl).dialContext
+	}
+
+	return transport, nil
+}
+
+// loadRootCAs builds a pool from the configured PEM CA bundle. It returns
+// nil, meaning the system pool, when no bundle is set or it can't be parsed.
+func loadRootCAs(pemCerts string, logger log.Logger) *x509.CertPool {
+	if pemCerts == "" {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pemCerts)) {
+		logger.Error("Failed to parse TLS CA certificate, falling back to the system pool")
+		return nil
+	}
+	return pool
+}
+
+// newTLSConfig applies the configured TLS version range and renegotiation
+// policy, defaulting to a TLS 1.2 minimum and rejecting versions below it
+func newTLSConfig(config *models.DataSourceConfig) (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if config.TLSMinVersion != "" {
+		v, ok := tlsVersions[config.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS min version %q", config.TLSMinVersion)
+		}
+		minVersion = v
+	}
+	if minVersion < tls.VersionTLS12 {
+		return nil, fmt.Errorf("TLS min version %s is insecure; use 1.2 or later", config.TLSMinVersion)
+	}
+
+	var maxVersion uint16
+	if config.TLSMaxVersion != "" {
+		v, ok := tlsVersions[config.TLSMaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS max version %q", config.TLSMaxVersion)
+		}
+		if v < minVersion {
+			return nil, fmt.Errorf("TLS max version %s is below the min version", config.TLSMaxVersion)
+		}
+		maxVersion = v
+	}
+
+	renegotiation, ok := tlsRenegotiation[config.TLSRenegotiation]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS renegotiation policy %q", config.TLSRenegotiation)
+	}
+
+	return &tls.Config{
+		MinVersion:    minVersion,
+		MaxVersion:    maxVersion,
+		Renegotiation: renegotiation,
+		// Self-signed backends can opt out of certificate verification
+		InsecureSkipVerify: config.TLSSkipVerify,
+	}, nil
+}
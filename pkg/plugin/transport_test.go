@@ -0,0 +1,302 @@
+package plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sameersah/GrafanaConnect/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// tlsBackend serves Prometheus, Loki and REST responses over a self-signed
+// certificate
+func tlsBackend(t *testing.T) *httptest.Server {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/label/"):
+			fmt.Fprint(w, `{"status":"success","data":["up"]}`)
+		case strings.HasPrefix(r.URL.Path, "/api/v1/"):
+			fmt.Fprint(w, prometheusMatrix(1))
+		case strings.HasPrefix(r.URL.Path, "/loki/"):
+			fmt.Fprint(w, lokiStreamsResponse)
+		default:
+			fmt.Fprint(w, `[{"value": 1}]`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTLSSkipVerify(t *testing.T) {
+	srv := tlsBackend(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	tests := []struct {
+		name      string
+		settings  map[string]interface{}
+		secure    map[string]string
+		wantValid bool
+	}{
+		{name: "self-signed rejected by default", wantValid: false},
+		{name: "skip verify", settings: map[string]interface{}{"tlsSkipVerify": true}, wantValid: true},
+		{name: "trusted CA", secure: map[string]string{"tlsCACert": caPEM}, wantValid: true},
+	}
+
+	queries := []map[string]interface{}{
+		{"queryType": "prometheus", "promQL": "up"},
+		{"queryType": "loki", "logQL": `{job="api"}`},
+		{"queryType": "rest", "restEndpoint": "/data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]interface{}{"prometheusUrl": srv.URL, "lokiUrl": srv.URL, "restUrl": srv.URL}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			ds := newTestDatasource(t, settings, tt.secure)
+
+			for _, model := range queries {
+				res := runQuery(t, ds, model)
+				if valid := res.Error == nil; valid != tt.wantValid {
+					t.Errorf("%s query error = %v, want success %v", model["queryType"], res.Error, tt.wantValid)
+				}
+			}
+
+			resp := callResource(t, ds, &backend.CallResourceRequest{Path: "prometheus/metrics", Method: "GET", URL: "prometheus/metrics"}).responses[0]
+			if valid := resp.Status == http.StatusOK; valid != tt.wantValid {
+				t.Errorf("metric names resource status = %d, want success %v: %s", resp.Status, tt.wantValid, resp.Body)
+			}
+		})
+	}
+}
+
+func TestTLSVersions(t *testing.T) {
+	tests := []struct {
+		name              string
+		settings          map[string]interface{}
+		wantMin, wantMax  uint16
+		wantRenegotiation tls.RenegotiationSupport
+		wantErr           string
+	}{
+		{name: "defaults to a 1.2 minimum", wantMin: tls.VersionTLS12, wantRenegotiation: tls.RenegotiateNever},
+		{name: "min 1.3", settings: map[string]interface{}{"tlsMinVersion": "1.3"}, wantMin: tls.VersionTLS13, wantRenegotiation: tls.RenegotiateNever},
+		{name: "pinned range", settings: map[string]interface{}{"tlsMinVersion": "1.2", "tlsMaxVersion": "1.2"}, wantMin: tls.VersionTLS12, wantMax: tls.VersionTLS12, wantRenegotiation: tls.RenegotiateNever},
+		{name: "renegotiate once", settings: map[string]interface{}{"tlsRenegotiation": "once"}, wantMin: tls.VersionTLS12, wantRenegotiation: tls.RenegotiateOnceAsClient},
+		{name: "renegotiate freely", settings: map[string]interface{}{"tlsRenegotiation": "freely"}, wantMin: tls.VersionTLS12, wantRenegotiation: tls.RenegotiateFreelyAsClient},
+		{name: "insecure min", settings: map[string]interface{}{"tlsMinVersion": "1.1"}, wantErr: "TLS min version 1.1 is insecure"},
+		{name: "unknown min", settings: map[string]interface{}{"tlsMinVersion": "TLS1.2"}, wantErr: `invalid TLS min version "TLS1.2"`},
+		{name: "unknown max", settings: map[string]interface{}{"tlsMaxVersion": "2.0"}, wantErr: `invalid TLS max version "2.0"`},
+		{name: "max below min", settings: map[string]interface{}{"tlsMinVersion": "1.3", "tlsMaxVersion": "1.2"}, wantErr: "TLS max version 1.2 is below the min version"},
+		{name: "unknown renegotiation", settings: map[string]interface{}{"tlsRenegotiation": "always"}, wantErr: `invalid TLS renegotiation policy "always"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.settings)
+			if err != nil {
+				t.Fatalf("marshal settings: %v", err)
+			}
+			instance, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{JSONData: raw})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDatasource: %v", err)
+			}
+			ds := instance.(*Datasource)
+			defer ds.Dispose()
+
+			cfg := ds.transport.TLSClientConfig
+			if cfg.MinVersion != tt.wantMin || cfg.MaxVersion != tt.wantMax {
+				t.Errorf("versions = %x-%x, want %x-%x", cfg.MinVersion, cfg.MaxVersion, tt.wantMin, tt.wantMax)
+			}
+			if cfg.Renegotiation != tt.wantRenegotiation {
+				t.Errorf("renegotiation = %v, want %v", cfg.Renegotiation, tt.wantRenegotiation)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersionEnforced(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	for _, minVersion := range []string{"1.2", "1.3"} {
+		t.Run(minVersion, func(t *testing.T) {
+			ds := newTestDatasource(t, map[string]interface{}{
+				"restUrl":       srv.URL,
+				"tlsSkipVerify": true,
+				"tlsMinVersion": minVersion,
+			}, nil)
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+
+			// The server only speaks TLS 1.2, so a 1.3 minimum can't connect
+			if ok := res.Error == nil; ok != (minVersion == "1.2") {
+				t.Errorf("query error = %v with min version %s", res.Error, minVersion)
+			}
+		})
+	}
+}
+
+// selfSignedPEM generates an unrelated self-signed CA certificate
+func selfSignedPEM(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Unrelated CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestTLSCACert(t *testing.T) {
+	srv := tlsBackend(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	tests := []struct {
+		name      string
+		caCert    string
+		wantPool  bool
+		wantValid bool
+	}{
+		{name: "server's CA", caCert: caPEM, wantPool: true, wantValid: true},
+		{name: "unrelated CA", caCert: selfSignedPEM(t), wantPool: true, wantValid: false},
+		{name: "invalid PEM falls back to the system pool", caCert: "not a certificate", wantPool: false, wantValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, map[string]string{"tlsCACert": tt.caCert})
+
+			if hasPool := ds.transport.TLSClientConfig.RootCAs != nil; hasPool != tt.wantPool {
+				t.Errorf("custom root pool = %v, want %v", hasPool, tt.wantPool)
+			}
+			res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"})
+			if valid := res.Error == nil; valid != tt.wantValid {
+				t.Errorf("query error = %v, want success %v", res.Error, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	backendTLS := tlsBackend(t)
+
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Method+" "+r.Host+r.URL.Path)
+		mu.Unlock()
+
+		if r.Method == http.MethodConnect {
+			// Tunnel HTTPS to the TLS backend whatever host was asked for
+			upstream, err := net.Dial("tcp", backendTLS.Listener.Addr().String())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				upstream.Close()
+				return
+			}
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			io.Copy(conn, upstream)
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name      string
+		restURL   string
+		wantHosts []string
+	}{
+		{name: "http", restURL: "http://backend.invalid", wantHosts: []string{"GET backend.invalid/data", "GET backend.invalid/rest"}},
+		{name: "https", restURL: "https://backend.invalid", wantHosts: []string{"CONNECT backend.invalid:443", "CONNECT backend.invalid:443"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			hosts = nil
+			mu.Unlock()
+
+			// The backend host doesn't resolve, so only the proxy can answer
+			ds := newTestDatasource(t, map[string]interface{}{"restUrl": tt.restURL, "proxyUrl": proxy.URL, "tlsSkipVerify": true}, nil)
+
+			if res := runQuery(t, ds, map[string]interface{}{"queryType": "rest", "restEndpoint": "/data"}); res.Error != nil {
+				t.Fatalf("query failed: %v", res.Error)
+			}
+			// A new connection makes the resource call tunnel on its own
+			ds.transport.CloseIdleConnections()
+			if resp := callResource(t, ds, &backend.CallResourceRequest{Path: "rest", URL: "rest", Method: "GET"}).responses[0]; resp.Status != http.StatusOK {
+				t.Fatalf("resource status = %d: %s", resp.Status, resp.Body)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(hosts, ",") != strings.Join(tt.wantHosts, ",") {
+				t.Errorf("proxied requests = %v, want %v", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestInvalidProxyURL(t *testing.T) {
+	for _, proxyURL := range []string{"::not a url", "proxy.internal:3128"} {
+		_, err := newTransport(&models.DataSourceConfig{ProxyURL: proxyURL}, nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid proxy URL") {
+			t.Errorf("proxy %q: error = %v, want invalid proxy URL", proxyURL, err)
+		}
+	}
+}
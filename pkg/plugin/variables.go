@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// variablePattern matches ${name} and ${name:format} placeholders
+var variablePattern = regexp.MustCompile(`\$\{(\w+)(?::(\w+))?\}`)
+
+// interpolateQueryVariables substitutes ${name} placeholders in a PromQL or
+// LogQL query. Values inside quoted label matchers are escaped so quotes and
+// backslashes can't break out of the string, and regex metacharacters are
+// escaped for =~ and !~ matchers. ${name:raw} inserts the value unchanged.
+// Unknown variables are left in place.
+func interpolateQueryVariables(query string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return query
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range variablePattern.FindAllStringSubmatchIndex(query, -1) {
+		start, end := m[0], m[1]
+		name := query[m[2]:m[3]]
+		format := ""
+		if m[4] >= 0 {
+			format = query[m[4]:m[5]]
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+
+		b.WriteString(query[last:start])
+		last = end
+
+		if format == "raw" {
+			b.WriteString(value)
+			continue
+		}
+
+		inString, regexMatcher := matcherContext(query[:start])
+		if !inString {
+			b.WriteString(value)
+			continue
+		}
+		if regexMatcher {
+			value = regexp.QuoteMeta(value)
+		}
+		b.WriteString(escapeQueryString(value))
+	}
+	b.WriteString(query[last:])
+
+	return b.String()
+}
+
+// matcherContext reports whether the end of prefix lies inside a double-quoted
+// string, and whether that string is the value of a regex matcher (=~ or !~)
+func matcherContext(prefix string) (inString bool, regexMatcher bool) {
+	open := -1
+	for i := 0; i < len(prefix); i++ {
+		switch prefix[i] {
+		case '\\':
+			if open >= 0 {
+				i++
+			}
+		case '"':
+			if open >= 0 {
+				open = -1
+			} else {
+				open = i
+			}
+		}
+	}
+	if open < 0 {
+		return false, false
+	}
+
+	op := strings.TrimRight(prefix[:open], " \t")
+	return true, strings.HasSuffix(op, "=~") || strings.HasSuffix(op, "!~")
+}
+
+// escapeQueryString escapes backslashes and double quotes for a PromQL/LogQL
+// double-quoted string literal
+func escapeQueryString(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+// bodyVariablePattern matches $name and ${name} placeholders in a JSON body
+var bodyVariablePattern = regexp.MustCompile(`^\$(?:\{(\w+)\}|(\w+))`)
+
+// interpolateJSONBody substitutes $name and ${name} placeholders in a JSON
+// request body while keeping it valid JSON. Inside a string the value is
+// escaped in place, with multi-values joined by commas. A multi-value that
+// is a whole array element, quoted or not, expands to one string element per
+// value, and an unquoted placeholder elsewhere becomes a JSON string or, for
+// multi-values, an array. Unknown variables are left in place.
+func interpolateJSONBody(body string, vars map[string]string, multi map[string][]string) string {
+	if len(vars) == 0 && len(multi) == 0 {
+		return body
+	}
+
+	out := make([]byte, 0, len(body))
+	var stack []byte
+	inString := false
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+
+		if c == '$' {
+			if m := bodyVariablePattern.FindStringSubmatch(body[i:]); m != nil {
+				name := m[1] + m[2]
+				values, isMulti := multi[name]
+				if !isMulti {
+					if v, ok := vars[name]; ok {
+						values = []string{v}
+					}
+				}
+
+				if values != nil || isMulti {
+					end := i + len(m[0])
+					inArray := len(stack) > 0 && stack[len(stack)-1] == '['
+
+					switch {
+					case inString && isMulti && inArray && body[i-1] == '"' && end < len(body) && body[end] == '"':
+						// The placeholder is the whole element: replace the quoted string
+						out = append(out[:len(out)-1], jsonStringList(values)...)
+						inString = false
+						end++
+					case inString:
+						out = append(out, jsonEscape(strings.Join(values, ","))...)
+					case isMulti && inArray:
+						out = append(out, jsonStringList(values)...)
+					case isMulti:
+						out = append(out, '[')
+						out = append(out, jsonStringList(values)...)
+						out = append(out, ']')
+					default:
+						out = append(out, '"')
+						out = append(out, jsonEscape(values[0])...)
+						out = append(out, '"')
+					}
+
+					i = end - 1
+					continue
+				}
+			}
+		}
+
+		out = append(out, c)
+		switch {
+		case inString && c == '\\' && i+1 < len(body):
+			i++
+			out = append(out, body[i])
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[' || c == '{':
+			stack = append(stack, c)
+		case (c == ']' || c == '}') && len(stack) > 0:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return string(out)
+}
+
+// jsonEscape escapes a value for use inside a JSON string literal
+func jsonEscape(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded[1 : len(encoded)-1])
+}
+
+// jsonStringList renders values as comma-separated JSON strings
+func jsonStringList(values []string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = `"` + jsonEscape(v) + `"`
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInterpolateQueryVariables(t *testing.T) {
+	vars := map[string]string{
+		"job":   "api",
+		"path":  `C:\logs "prod"`,
+		"host":  "web-1.example.com",
+		"range": "5m",
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "equality matcher", query: `up{job="${job}"}`, want: `up{job="api"}`},
+		{name: "quotes and backslashes escaped", query: `{file="${path}"}`, want: `{file="C:\\logs \"prod\""}`},
+		{name: "regex matcher quotes metacharacters", query: `up{instance=~"${host}"}`, want: `up{instance=~"web-1\\.example\\.com"}`},
+		{name: "negative regex matcher", query: `up{instance!~ "${host}"}`, want: `up{instance!~ "web-1\\.example\\.com"}`},
+		{name: "raw format", query: `up{instance=~"${host:raw}|db"}`, want: `up{instance=~"web-1.example.com|db"}`},
+		{name: "outside a string", query: `rate(up[${range}])`, want: `rate(up[5m])`},
+		{name: "after an escaped quote", query: `{msg="say \"hi\" ${job}"}`, want: `{msg="say \"hi\" api"}`},
+		{name: "unknown variable kept", query: `up{job="${missing}"}`, want: `up{job="${missing}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpolateQueryVariables(tt.query, vars); got != tt.want {
+				t.Errorf("interpolateQueryVariables() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateJSONBody(t *testing.T) {
+	vars := map[string]string{
+		"namespace": "prod",
+		"quote":     `say "hi"\n`,
+	}
+	multi := map[string][]string{
+		"hosts": {"web-1", `web-"2"`},
+		"none":  {},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "scalar in string", body: `{"namespace": "$namespace"}`, want: `{"namespace": "prod"}`},
+		{name: "braced scalar within text", body: `{"q": "ns=${namespace}-x"}`, want: `{"q": "ns=prod-x"}`},
+		{name: "scalar is escaped", body: `{"q": "$quote"}`, want: `{"q": "say \"hi\"\\n"}`},
+		{name: "bare scalar becomes a string", body: `{"namespace": $namespace}`, want: `{"namespace": "prod"}`},
+		{name: "quoted multi-value element", body: `{"hosts": ["$hosts"]}`, want: `{"hosts": ["web-1","web-\"2\""]}`},
+		{name: "bare multi-value element", body: `{"hosts": ["db", $hosts]}`, want: `{"hosts": ["db", "web-1","web-\"2\""]}`},
+		{name: "bare multi-value becomes an array", body: `{"hosts": $hosts}`, want: `{"hosts": ["web-1","web-\"2\""]}`},
+		{name: "multi-value inside text is joined", body: `{"q": "host=~$hosts"}`, want: `{"q": "host=~web-1,web-\"2\""}`},
+		{name: "empty multi-value", body: `{"hosts": $none}`, want: `{"hosts": []}`},
+		{name: "unknown variable is kept", body: `{"q": "$missing"}`, want: `{"q": "$missing"}`},
+		{name: "escaped quote doesn't end the string", body: `{"q": "a\"$namespace"}`, want: `{"q": "a\"prod"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := interpolateJSONBody(tt.body, vars, multi)
+			if got != tt.want {
+				t.Errorf("interpolateJSONBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("result %s is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestRESTBodyVariables(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"value": 1}]`)
+	}))
+	defer srv.Close()
+
+	ds := newTestDatasource(t, map[string]interface{}{"restUrl": srv.URL}, nil)
+	res := runQuery(t, ds, map[string]interface{}{
+		"queryType":      "rest",
+		"restEndpoint":   "/_search",
+		"restMethod":     "POST",
+		"restBody":       `{"namespace": "$namespace", "hosts": ["$hosts"]}`,
+		"variables":      map[string]string{"namespace": "prod"},
+		"multiVariables": map[string][]string{"hosts": {"a", "b"}},
+	})
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if want := `{"namespace": "prod", "hosts": ["a","b"]}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
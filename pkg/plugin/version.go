@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Version and Commit identify the plugin build; they are injected with
+// -ldflags "-X github.com/Sameersah/GrafanaConnect/pkg/plugin.Version=..."
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// backendVersionTTL is how long detected backend versions are reused
+const backendVersionTTL = time.Minute
+
+// backendVersion is the build information reported by one backend
+type backendVersion struct {
+	Source   string `json:"source"`
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// versionCache keeps the last backend version lookup for a short while so
+// the version endpoint doesn't hit the backends on every call
+type versionCache struct {
+	mu       sync.Mutex
+	fetched  time.Time
+	backends []backendVersion
+}
+
+// get returns the cached versions, refreshing them with fetch once stale.
+// The lock isn't held while fetching, so a slow backend doesn't block other
+// callers; concurrent refreshes may both fetch and the last one is kept.
+func (c *versionCache) get(fetch func() []backendVersion) []backendVersion {
+	c.mu.Lock()
+	if c.backends != nil && time.Since(c.fetched) <= backendVersionTTL {
+		backends := c.backends
+		c.mu.Unlock()
+		return backends
+	}
+	c.mu.Unlock()
+
+	backends := fetch()
+
+	c.mu.Lock()
+	c.backends = backends
+	c.fetched = time.Now()
+	c.mu.Unlock()
+	return backends
+}
+
+// detectBackendVersions queries the buildinfo endpoint of each configured
+// Prometheus and Loki backend
+func (d *Datasource) detectBackendVersions(ctx context.Context) []backendVersion {
+	versions := []backendVersion{}
+
+	if d.config.PrometheusURL != "" {
+		v := backendVersion{Source: "Prometheus"}
+		// Prometheus wraps the build information in the API envelope
+		var envelope struct {
+			Data struct {
+				Version  string `json:"version"`
+				Revision string `json:"revision"`
+			} `json:"data"`
+		}
+		if err := d.fetchBuildInfo(ctx, strings.TrimSuffix(d.config.PrometheusURL, "/")+"/api/v1/status/buildinfo", d.config.PrometheusHeaders, &envelope); err != nil {
+			v.Error = err.Error()
+		} else {
+			v.Version, v.Revision = envelope.Data.Version, envelope.Data.Revision
+		}
+		versions = append(versions, v)
+	}
+
+	if d.config.LokiURL != "" {
+		v := backendVersion{Source: "Loki"}
+		var info struct {
+			Version  string `json:"version"`
+			Revision string `json:"revision"`
+		}
+		buildInfoURL, err := lokiAPIURL(d.config, "status", "buildinfo")
+		if err == nil {
+			err = d.fetchBuildInfo(ctx, buildInfoURL, d.config.LokiHeaders, &info)
+		}
+		if err != nil {
+			v.Error = err.Error()
+		} else {
+			v.Version, v.Revision = info.Version, info.Revision
+		}
+		versions = append(versions, v)
+	}
+
+	return versions
+}
+
+// fetchBuildInfo GETs a buildinfo endpoint with the source's headers and
+// authentication and decodes the JSON response into out
+func (d *Datasource) fetchBuildInfo(ctx context.Context, buildInfoURL string, headers map[string]string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout(d.config))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", buildInfoURL, nil)
+	if err != nil {
+		return err
+	}
+	setConfigHeaders(req, headers, d.config.Headers)
+	setAuthHeaders(req, d.config)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("buildinfo returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// handleVersionResource reports the plugin build and the detected backend
+// versions
+func (d *Datasource) handleVersionResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	backends := d.versions.get(func() []backendVersion {
+		return d.detectBackendVersions(ctx)
+	})
+
+	body, err := json.Marshal(map[string]interface{}{
+		"version":  Version,
+		"commit":   Commit,
+		"backends": backends,
+	})
+	if err != nil {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: 500,
+			Body:   []byte(fmt.Sprintf(`{"error": "%v"}`, err)),
+		})
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  200,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+}
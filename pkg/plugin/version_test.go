@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestVersionResource(t *testing.T) {
+	tests := []struct {
+		name      string
+		trailing  string
+		lokiFails bool
+		want      []backendVersion
+	}{
+		{
+			name: "both reachable",
+			want: []backendVersion{{Source: "Prometheus", Version: "2.50.0", Revision: "abc"}, {Source: "Loki", Version: "3.0.0", Revision: "def"}},
+		},
+		{
+			name:     "trailing slashes",
+			trailing: "/",
+			want:     []backendVersion{{Source: "Prometheus", Version: "2.50.0", Revision: "abc"}, {Source: "Loki", Version: "3.0.0", Revision: "def"}},
+		},
+		{
+			name:      "Loki unreachable",
+			lokiFails: true,
+			want:      []backendVersion{{Source: "Prometheus", Version: "2.50.0", Revision: "abc"}, {Source: "Loki", Error: "buildinfo returned status 503"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v1/status/buildinfo":
+					fmt.Fprint(w, `{"status":"success","data":{"version":"2.50.0","revision":"abc"}}`)
+				case "/loki/api/v1/status/buildinfo":
+					if tt.lokiFails {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					fmt.Fprint(w, `{"version":"3.0.0","revision":"def"}`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			ds := newTestDatasource(t, map[string]interface{}{
+				"prometheusUrl": srv.URL + tt.trailing,
+				"lokiUrl":       srv.URL + tt.trailing,
+			}, nil)
+			resp := callResource(t, ds, &backend.CallResourceRequest{Path: "version", Method: "GET"}).responses[0]
+			if resp.Status != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Status, resp.Body)
+			}
+
+			var body struct {
+				Version  string           `json:"version"`
+				Backends []backendVersion `json:"backends"`
+			}
+			if err := json.Unmarshal(resp.Body, &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Version != Version {
+				t.Errorf("version = %q, want %q", body.Version, Version)
+			}
+			if fmt.Sprint(body.Backends) != fmt.Sprint(tt.want) {
+				t.Errorf("backends = %+v, want %+v", body.Backends, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionCacheFetchesWithoutLock(t *testing.T) {
+	cache := &versionCache{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go cache.get(func() []backendVersion {
+		close(started)
+		<-release
+		return []backendVersion{{Source: "Prometheus"}}
+	})
+	<-started
+
+	// A second caller must not wait for the slow fetch to finish
+	done := make(chan []backendVersion)
+	go func() {
+		done <- cache.get(func() []backendVersion { return []backendVersion{{Source: "Loki"}} })
+	}()
+	select {
+	case got := <-done:
+		if len(got) != 1 || got[0].Source != "Loki" {
+			t.Errorf("got %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("get blocked on a concurrent fetch")
+	}
+	close(release)
+
+	// Fresh results are served from the cache
+	fetched := false
+	cache.get(func() []backendVersion {
+		fetched = true
+		return nil
+	})
+	if fetched {
+		t.Error("fresh versions were fetched again")
+	}
+}
@@ -8,33 +8,146 @@ export enum QueryType {
 
 export interface GrafanaConnectQuery extends DataQuery {
   queryType: QueryType;
+  queryKind?: 'instant' | 'range';
   
   // Prometheus fields
   promQL?: string;
+  seriesMatchers?: string[];
+  legendFormat?: string;
+  format?: 'time_series' | 'heatmap';
+  groupByLabels?: string[];
+  promoteLabels?: string[];
+  step?: string;
+  steps?: string[];
+  timestampPolicy?: 'dedupLast' | 'dropDuplicates' | 'sort';
+  min?: number;
+  max?: number;
+  unit?: string;
+  unitFromLabel?: string;
+  thresholds?: Array<{ value: number | null; color: string }>;
+  includeRaw?: boolean;
   
   // Loki fields
   logQL?: string;
+  limit?: number;
+  direction?: 'backward' | 'forward';
+  withCount?: boolean;
+  detectLevel?: boolean;
+  emitLabelsField?: boolean;
+  metricsLinkTemplate?: string;
   
   // REST API fields
   restEndpoint?: string;
   restMethod?: string;
   restHeaders?: Record<string, string>;
   restBody?: string;
+  restParams?: Record<string, string>;
+  restRawQuery?: string;
+  restGraphQL?: boolean;
+  graphQLQuery?: string;
+  graphQLVariables?: Record<string, unknown>;
+  timeField?: string;
+  timeFieldCandidates?: string[];
+  autoDetectEpochTime?: boolean;
+  timeFormats?: string[];
+  maxWaitMs?: number;
+  responseFormat?: 'json' | 'ndjson' | 'csv' | 'xml' | 'raw';
+  csvDelimiter?: string;
+  csvNoHeader?: boolean;
+  responseShape?: 'objectSeries';
+  restDataPath?: string;
+  metaPath?: string;
+  forceValueType?: 'auto' | 'string' | 'number' | 'bool';
+  maxRows?: number;
+  sampleMode?: 'head' | 'tail' | 'even';
+  fieldExpressions?: Record<string, string>;
+  fanOut?: string[];
+  fanOutMerge?: boolean;
+
+  // Common fields
+  baseUrlOverride?: string;
+  authProfile?: string;
+  nocache?: boolean;
+  roundDecimals?: number;
+  compress?: boolean;
+  variables?: Record<string, string>;
+  multiVariables?: Record<string, string[]>;
 }
 
 export interface GrafanaConnectDataSourceOptions extends DataSourceJsonData {
   prometheusUrl?: string;
   lokiUrl?: string;
   restUrl?: string;
+  authMode?: 'none' | 'apiKey' | 'basic' | 'bearer' | 'oauth2';
   apiKey?: string;
   basicAuthUser?: string;
   bearerToken?: string;
+  oauth2TokenUrl?: string;
+  oauth2ClientId?: string;
+  oauth2Scopes?: string[];
+  headers?: Record<string, string>;
+  prometheusHeaders?: Record<string, string>;
+  lokiHeaders?: Record<string, string>;
   restHeaders?: Record<string, string>;
+  hmacHeader?: string;
+  hmacTimestampHeader?: string;
+  hmacTemplate?: string;
+  restHealthEndpoint?: string;
+  restHealthExpectedStatus?: number;
+  restHealthExpectedBody?: string;
+  prometheusHealthQuery?: string;
+  scrapeInterval?: string;
+  healthMode?: 'all' | 'any';
+  prometheusMaxRange?: string;
+  lokiMaxRange?: string;
+  maxRangeMode?: 'reject' | 'clamp';
+  tlsMinVersion?: '1.2' | '1.3';
+  tlsMaxVersion?: '1.2' | '1.3';
+  tlsRenegotiation?: 'never' | 'once' | 'freely';
+  tlsSkipVerify?: boolean;
+  lokiMinStepSeconds?: number;
+  lokiApiPrefix?: string;
+  lokiMaxLines?: number;
+  lokiDerivedFields?: Array<{ name: string; matcherRegex: string; url?: string }>;
+  seriesNameLabel?: string;
+  strictQueryTypes?: boolean;
+  clockSkewCheck?: boolean;
+  clockSkewThresholdSeconds?: number;
+  proxyUrl?: string;
+  dnsCacheTtl?: string;
+  compress?: boolean;
+  timeoutSeconds?: number;
+  healthTimeoutSeconds?: number;
+  staleCacheSize?: number;
+  staleCacheMaxAgeSeconds?: number;
+  cacheTTLSeconds?: number;
+  cacheMaxEntries?: number;
+  allowUrlOverride?: boolean;
+  allowedBaseUrls?: string[];
+  authProfiles?: Array<{
+    name: string;
+    authMode: 'none' | 'apiKey' | 'basic' | 'bearer';
+    basicAuthUser?: string;
+    allowedUrls: string[];
+  }>;
+  queryConcurrency?: number;
+  maxConcurrentRequests?: number;
+  maxFramesPerQuery?: number;
+  maxResponseBytes?: number;
+  maxRetries?: number;
+  retryBackoffMs?: number;
+  retryableStatusCodes?: number[];
+  disableNetworkErrorRetry?: boolean;
 }
 
 export interface GrafanaConnectSecureJsonData {
   apiKey?: string;
   basicAuthPass?: string;
   bearerToken?: string;
+  hmacSecret?: string;
+  tlsCACert?: string;
+  oauth2ClientSecret?: string;
+  // Auth profile secrets, e.g. authProfile.eu.bearerToken
+  [profileSecret: `authProfile.${string}`]: string | undefined;
 }
 